@@ -24,7 +24,7 @@ import (
 
 const PORT = "50051"
 
-func Server(chonkSize int, dbpath string, key []byte) error {
+func Server(chonkSize int, dbpath string, password []byte) error {
 	var err error
 
 	printInfoBox("DUES Server", []string{
@@ -33,24 +33,18 @@ func Server(chonkSize int, dbpath string, key []byte) error {
 		fmt.Sprintf("Port: %s", PORT),
 	})
 
-	if err = ensureUploadDir(); err != nil {
-		printErrorBox("Server startup failed", []string{fmt.Sprintf("Failed to prepare uploads dir: %v", err)})
-		return err
-	}
-	printSuccessBox("Startup check", []string{fmt.Sprintf("Uploads dir ready: %s", cnst.UploadsDir)})
-
-	cnst.DB, _, err = cli.Common(chonkSize, dbpath, key)
+	cnst.DB, dbpath, err = cli.Common(chonkSize, dbpath, password)
 	if err != nil {
 		printErrorBox("Server startup failed", []string{fmt.Sprintf("Database connection failed: %v", err)})
 		return err
 	}
 	defer cnst.DB.Close()
-	err = util.EnsureBlobPath(cnst.DefaultDBPath)
+	err = util.EnsureBlobPath(dbpath)
 	if err != nil {
 		printErrorBox("Server startup failed", []string{fmt.Sprintf("Blob path check failed: %v", err)})
 		return err
 	}
-	printSuccessBox("Database ready", []string{fmt.Sprintf("DB path: %s", cnst.DefaultDBPath)})
+	printSuccessBox("Database ready", []string{fmt.Sprintf("DB path: %s", dbpath)})
 
 	// Create Connect handler (supports gRPC, gRPC-Web, and Connect protocols)
 	mux := http.NewServeMux()
@@ -217,11 +211,3 @@ func corsInterceptor() connect.UnaryInterceptorFunc {
 		}
 	}
 }
-
-func ensureUploadDir() error {
-	_, err := os.Stat(cnst.UploadsDir)
-	if os.IsNotExist(err) {
-		return os.MkdirAll(cnst.UploadsDir, os.ModeDir)
-	}
-	return nil
-}
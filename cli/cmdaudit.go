@@ -0,0 +1,18 @@
+package cli
+
+import (
+	"indicer/lib/audit"
+)
+
+func AuditData(chonkSize int, dbpath string, password []byte) error {
+	db, _, err := Common(chonkSize, dbpath, password)
+	if err != nil {
+		return err
+	}
+	aerr := audit.Show(db)
+	err = db.Close()
+	if aerr != nil {
+		return aerr
+	}
+	return err
+}
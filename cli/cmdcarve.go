@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"fmt"
+	"indicer/lib/audit"
+	"indicer/lib/carve"
+	"indicer/lib/cnst"
+	"time"
+)
+
+func CarveData(chonkSize int, dbpath, chash string, password []byte) error {
+	start := time.Now()
+
+	db, _, err := Common(chonkSize, dbpath, password)
+	if err != nil {
+		return err
+	}
+
+	entry := audit.Begin(cnst.CmdCarve, chash)
+	hashes, cerr := carve.Carve(chash, db)
+	cerr = audit.Record(db, entry, cerr, hashes...)
+	err = db.Close()
+	if cerr != nil {
+		return cerr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println("Carved in: ", time.Since(start))
+	return nil
+}
@@ -0,0 +1,21 @@
+package cli
+
+import (
+	"indicer/lib/audit"
+	"indicer/lib/cnst"
+	"indicer/lib/store"
+)
+
+func CompactData(chonkSize int, dbpath string, threshold float64, password []byte) error {
+	db, _, err := Common(chonkSize, dbpath, password)
+	if err != nil {
+		return err
+	}
+	entry := audit.Begin(cnst.CmdCompact, "")
+	err = store.Compact(db, threshold)
+	err = audit.Record(db, entry, err)
+	if err != nil {
+		return err
+	}
+	return db.Close()
+}
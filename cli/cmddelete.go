@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"fmt"
+	"indicer/lib/audit"
+	"indicer/lib/cnst"
+	"indicer/lib/store"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+func DeleteData(chonkSize int, dbpath, fhash string, password []byte) error {
+	if fhash == "" {
+		return cnst.ErrHashNotFound
+	}
+
+	color.Red("WARNING! This command will DELETE the evidence file %s and everything indexed from it.", fhash)
+	fmt.Printf("Are you sure about this? [y/N] ")
+
+	var in string
+	fmt.Scanln(&in)
+	in = strings.ToLower(in)
+
+	if in != "y" {
+		color.Blue("Your data is SAFE!")
+		return nil
+	}
+
+	db, _, err := Common(chonkSize, dbpath, password)
+	if err != nil {
+		return err
+	}
+	entry := audit.Begin(cnst.CmdDelete, "")
+	err = store.Delete(fhash, db)
+	err = audit.Record(db, entry, err, fhash)
+	if err != nil {
+		return err
+	}
+	return db.Close()
+}
@@ -0,0 +1,21 @@
+package cli
+
+import (
+	"indicer/lib/audit"
+	"indicer/lib/cnst"
+	"indicer/lib/store"
+)
+
+func GCData(chonkSize int, dbpath string, password []byte) error {
+	db, _, err := Common(chonkSize, dbpath, password)
+	if err != nil {
+		return err
+	}
+	entry := audit.Begin(cnst.CmdGC, "")
+	err = store.GC(db)
+	err = audit.Record(db, entry, err)
+	if err != nil {
+		return err
+	}
+	return db.Close()
+}
@@ -4,8 +4,8 @@ import (
 	"indicer/lib/store"
 )
 
-func ListData(chonkSize int, dbpath string, key []byte) error {
-	db, _, err := Common(chonkSize, dbpath, key)
+func ListData(chonkSize int, dbpath string, password []byte) error {
+	db, _, err := Common(chonkSize, dbpath, password)
 	if err != nil {
 		return err
 	}
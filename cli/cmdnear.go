@@ -1,23 +1,34 @@
 package cli
 
 import (
+	"indicer/lib/audit"
+	"indicer/lib/cnst"
 	"indicer/lib/near"
+	"path/filepath"
 )
 
-func NearInData(deep bool, chonkSize int, dbpath, inhash string, key []byte) error {
-	db, _, err := Common(chonkSize, dbpath, key)
+func NearInData(deep bool, chonkSize int, dbpath, inhash string, password []byte) error {
+	db, _, err := Common(chonkSize, dbpath, password)
 	if err != nil {
 		return err
 	}
-	return near.NearInFile(inhash, db, deep)
+	entry := audit.Begin(cnst.CmdNear+" "+cnst.SubCmdIn, "")
+	err = near.NearInFile(inhash, db, deep)
+	return audit.Record(db, entry, err, inhash)
 }
 
-func NearOutData(chonkSize int, dbpath, outpath string, key []byte) error {
-	db, _, err := Common(chonkSize, dbpath, key)
+func NearOutData(chonkSize int, dbpath, outpath string, password []byte) error {
+	db, _, err := Common(chonkSize, dbpath, password)
 	if err != nil {
 		return err
 	}
+	input, err := filepath.Abs(outpath)
+	if err != nil {
+		return err
+	}
+	entry := audit.Begin(cnst.CmdNear+" "+cnst.SubCmdOut, input)
 	err = near.NearOutFile(outpath, db)
+	err = audit.Record(db, entry, err)
 	if err != nil {
 		return err
 	}
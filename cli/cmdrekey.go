@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"indicer/lib/audit"
+	"indicer/lib/cnst"
+	"indicer/lib/dbio"
+
+	"github.com/fatih/color"
+)
+
+func RekeyData(chonkSize int, dbpath string, password, newPassword []byte) error {
+	db, dbpath, err := Common(chonkSize, dbpath, password)
+	if err != nil {
+		return err
+	}
+
+	entry := audit.Begin(cnst.CmdRekey, "")
+	err = dbio.Rekey(dbpath, password, newPassword)
+	err = audit.Record(db, entry, err)
+	if err != nil {
+		return err
+	}
+	err = db.Close()
+	if err != nil {
+		return err
+	}
+
+	color.Green("Password changed, stored evidence is untouched")
+	return nil
+}
@@ -2,6 +2,8 @@ package cli
 
 import (
 	"fmt"
+	"indicer/lib/audit"
+	"indicer/lib/cnst"
 	"indicer/lib/util"
 	"os"
 	"strings"
@@ -9,7 +11,7 @@ import (
 	"github.com/fatih/color"
 )
 
-func ResetData(dbpath string) error {
+func ResetData(chonkSize int, dbpath string, password []byte) error {
 	var err error
 
 	if dbpath == "" {
@@ -31,6 +33,32 @@ func ResetData(dbpath string) error {
 		return nil
 	}
 
+	exportAuditLog(chonkSize, dbpath, password)
+
 	color.Red("Deleting ALL data!")
 	return os.RemoveAll(dbpath)
 }
+
+// exportAuditLog records the reset and saves the audit chain outside the database,
+// a database that cannot be opened is still reset, only without its audit chain
+func exportAuditLog(chonkSize int, dbpath string, password []byte) {
+	db, _, err := Common(chonkSize, dbpath, password)
+	if err != nil {
+		color.Yellow("⚠️  could not open the database to export its audit log: %v", err)
+		return
+	}
+	defer db.Close()
+
+	entry := audit.Begin(cnst.CmdReset, dbpath)
+	err = audit.Record(db, entry, nil)
+	if err != nil {
+		color.Yellow("⚠️  could not record the reset in the audit log: %v", err)
+	}
+
+	fpath, err := audit.Export(db)
+	if err != nil {
+		color.Yellow("⚠️  could not export the audit log: %v", err)
+		return
+	}
+	color.Blue("Audit log exported to %s", fpath)
+}
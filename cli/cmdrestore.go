@@ -2,26 +2,33 @@ package cli
 
 import (
 	"fmt"
+	"indicer/lib/audit"
+	"indicer/lib/cnst"
 	"indicer/lib/store"
 	"os"
+	"path/filepath"
 	"time"
 )
 
-func RestoreData(chonkSize int, dbpath, rhash, rpath string, key []byte) error {
+func RestoreData(chonkSize int, dbpath, rhash, rpath string, password []byte) error {
 	start := time.Now()
 
-	db, _, err := Common(chonkSize, dbpath, key)
+	db, _, err := Common(chonkSize, dbpath, password)
 	if err != nil {
 		return err
 	}
 
-	fhandle, err := os.Create(rpath)
+	rpath, err = filepath.Abs(rpath)
 	if err != nil {
 		return err
 	}
-
-	fmt.Println("Restoring file ...")
-	err = store.Restore(rhash, fhandle, db)
+	entry := audit.Begin(cnst.CmdRestore, rpath)
+	fhandle, err := os.Create(rpath)
+	if err == nil {
+		fmt.Println("Restoring file ...")
+		err = store.Restore(rhash, fhandle, db)
+	}
+	err = audit.Record(db, entry, err, rhash)
 	if err != nil {
 		return err
 	}
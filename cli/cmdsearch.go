@@ -1,19 +1,22 @@
 package cli
 
 import (
+	"indicer/lib/audit"
 	"indicer/lib/cnst"
 	"indicer/lib/search"
 	"strings"
 )
 
-func SearchCmd(chonkSize int, query, dbpath string, key []byte) error {
+func SearchCmd(chonkSize int, query, dbpath string, password []byte) error {
 	if len(query) < 2 {
 		return cnst.ErrSmallQuery
 	}
-	db, _, err := Common(chonkSize, dbpath, key)
+	db, _, err := Common(chonkSize, dbpath, password)
 	if err != nil {
 		return err
 	}
 	query = strings.ToLower(query)
-	return search.Search(query, db)
+	entry := audit.Begin(cnst.CmdSearch, query)
+	err = search.Search(query, db)
+	return audit.Record(db, entry, err)
 }
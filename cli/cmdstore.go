@@ -1,14 +1,18 @@
 package cli
 
 import (
-	"errors"
+	"bytes"
 	"fmt"
+	"indicer/lib/audit"
 	"indicer/lib/cnst"
 	"indicer/lib/dbio"
+	"indicer/lib/digest"
+	"indicer/lib/ewf"
 	"indicer/lib/parser"
 	"indicer/lib/store"
 	"indicer/lib/structs"
 	"indicer/lib/util"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -16,11 +20,11 @@ import (
 
 	"github.com/dgraph-io/badger/v4"
 	"github.com/edsrzf/mmap-go"
-	"golang.org/x/crypto/sha3"
+	"github.com/fatih/color"
 )
 
-func StoreData(chonkSize int, dbpath, evipath string, key []byte, syncIndex, noIndex bool) error {
-	db, dbpath, err := Common(chonkSize, dbpath, key)
+func StoreData(chonkSize int, dbpath, evipath, streamName string, password []byte, noIndex bool, acq structs.Acquisition) error {
+	db, dbpath, err := Common(chonkSize, dbpath, password)
 	if err != nil {
 		return err
 	}
@@ -29,6 +33,14 @@ func StoreData(chonkSize int, dbpath, evipath string, key []byte, syncIndex, noI
 		return err
 	}
 
+	if evipath == cnst.StdinPath {
+		_, err = StoreStream(os.Stdin, streamName, nil, noIndex, acq, db)
+		if err != nil {
+			return err
+		}
+		return db.Close()
+	}
+
 	finfo, err := os.Stat(evipath)
 	if err != nil {
 		return err
@@ -36,12 +48,12 @@ func StoreData(chonkSize int, dbpath, evipath string, key []byte, syncIndex, noI
 
 	if finfo.IsDir() {
 		fmt.Println("Storing Entire Folder")
-		err = StoreFolder(chonkSize, evipath, key, syncIndex, noIndex, db)
+		err = StoreFolder(chonkSize, evipath, noIndex, acq, db)
 		if err != nil {
 			return err
 		}
 	}
-	err = StoreFile(chonkSize, evipath, key, syncIndex, noIndex, db)
+	err = StoreFile(chonkSize, evipath, noIndex, acq, db)
 	if err != nil {
 		return err
 	}
@@ -53,7 +65,7 @@ func StoreData(chonkSize int, dbpath, evipath string, key []byte, syncIndex, noI
 	return nil
 }
 
-func StoreFolder(chonkSize int, evidir string, key []byte, syncIndex, noIndex bool, db *badger.DB) error {
+func StoreFolder(chonkSize int, evidir string, noIndex bool, acq structs.Acquisition, db *badger.DB) error {
 	start := time.Now()
 
 	err := filepath.Walk(evidir, func(path string, info fs.FileInfo, err error) error {
@@ -61,11 +73,12 @@ func StoreFolder(chonkSize int, evidir string, key []byte, syncIndex, noIndex bo
 			return err
 		}
 
-		if info.IsDir() {
+		// the other segments of an E01 are read along with its first one
+		if info.IsDir() || ewf.SegmentNumber(path) > 1 {
 			return nil
 		}
 
-		return StoreFile(chonkSize, path, key, syncIndex, noIndex, db)
+		return StoreFile(chonkSize, path, noIndex, acq, db)
 	})
 
 	if err != nil {
@@ -77,7 +90,7 @@ func StoreFolder(chonkSize int, evidir string, key []byte, syncIndex, noIndex bo
 	return nil
 }
 
-func StoreFile(chonkSize int, evipath string, key []byte, syncIndex, noIndex bool, db *badger.DB) error {
+func StoreFile(chonkSize int, evipath string, noIndex bool, acq structs.Acquisition, db *badger.DB) error {
 	start := time.Now()
 
 	info, err := os.Stat(evipath)
@@ -88,125 +101,281 @@ func StoreFile(chonkSize int, evipath string, key []byte, syncIndex, noIndex boo
 		return nil
 	}
 
-	fmt.Println("Pre-store checks....")
-	eviFile, err := initEvidenceFile(evipath, db)
+	input, err := filepath.Abs(evipath)
 	if err != nil {
 		return err
 	}
-	err = store.EvidenceFilePreStoreCheck(eviFile)
-	if err != nil && err != badger.ErrKeyNotFound && err != cnst.ErrIncompleteFile {
+	entry := audit.Begin(cnst.CmdStore, input)
+	err = storeFile(evipath, noIndex, acq, &entry, db)
+	err = audit.Record(db, entry, err)
+	if err != nil {
 		return err
 	}
-	if err == nil {
-		return nil
+	fmt.Printf("\nStored in: %v\n\n", time.Since(start))
+	return nil
+}
+
+// StoreStream stores evidence read front to back from r, such as stdin or an upload, under name
+// and returns its hash. Partition tables and file systems need random access, so they are
+// indexed from the stored chonks once the stream is stored. When expected is set, a stream
+// that hashes to anything else is not kept
+func StoreStream(r io.Reader, name string, expected []byte, noIndex bool, acq structs.Acquisition, db *badger.DB) ([]byte, error) {
+	start := time.Now()
+
+	entry := audit.Begin(cnst.CmdStore, name)
+	ehash, err := storeStream(r, name, expected, noIndex, acq, &entry, db)
+	err = audit.Record(db, entry, err)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("\nStored in: %v\n\n", time.Since(start))
+	return ehash, nil
+}
+
+func storeStream(r io.Reader, name string, expected []byte, noIndex bool, acq structs.Acquisition, entry *structs.AuditEntry, db *badger.DB) ([]byte, error) {
+	err := dbio.EnsureManifest(db)
+	if err != nil {
+		return nil, err
 	}
 
-	var active int
-	idxChan := make(chan error)
+	provisionalHash, err := store.RandomProvisionalHash()
+	if err != nil {
+		return nil, err
+	}
+	eviFile := structs.NewInputFile(db, nil, nil, name, cnst.EviFileNamespace, provisionalHash, 0, 0)
+
+	fmt.Printf("\nSaving Evidence Stream: %s\n", name)
+	err = store.IngestStream(&eviFile, r, expected)
+	if err != nil {
+		return nil, err
+	}
+
+	ehash, err := eviFile.GetEncodedHash()
+	if err != nil {
+		return nil, err
+	}
+	entry.Hashes = append(entry.Hashes, string(ehash))
+	err = finishEvidenceFile(&eviFile, nil, acq)
+	if err != nil || noIndex {
+		return eviFile.GetHash(), err
+	}
+	return eviFile.GetHash(), indexStoredEvidence(&eviFile)
+}
+
+// indexStoredEvidence finds the partitions and files of an evidence file that was stored
+// without random access, reading it back from its chonks
+func indexStoredEvidence(eviFile *structs.InputFile) error {
+	eviFile.SetReader(store.NewStoredReader(eviFile.GetHash(), eviFile.GetSize(), eviFile.GetDB()))
+	plans, ranges, err := planPartitions(*eviFile)
+	if err != nil {
+		return err
+	}
+	err = store.HashStoredRanges(eviFile.GetHash(), eviFile.GetSize(), ranges, eviFile.GetDB())
+	if err != nil {
+		return err
+	}
+
+	for index, plan := range plans {
+		err = storePartition(eviFile, index, plan)
+		if err != nil {
+			return err
+		}
+	}
+	return store.AddInternalObjects(eviFile.GetID(), eviFile.GetInternalObjects(), eviFile.GetDB())
+}
+
+func storeFile(evipath string, noIndex bool, acq structs.Acquisition, entry *structs.AuditEntry, db *badger.DB) error {
+	err := dbio.EnsureManifest(db)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Pre-store checks....")
+	eviFile, err := initEvidenceFile(evipath, db)
+	if err != nil {
+		return err
+	}
+	image, isImage := eviFile.GetReader().(*ewf.Image)
+	if isImage {
+		// what was given on the command line wins over what the acquisition tool recorded
+		ewfAcq := image.Acquisition()
+		ewfAcq.Merge(acq)
+		acq = ewfAcq
+	}
+
+	var plans []partitionPlan
+	var ranges []*structs.IngestRange
 	if !noIndex {
-		partitions := parser.GetPartitions(eviFile.GetSize(), eviFile.GetHandle())
-		// not limiting goroutines here because max number of partitions will be 4 or less
-		for index, partition := range partitions {
-			phash := eviFile.GetHash()
-			if partition.Start != 0 && partition.Size != eviFile.GetSize() {
-				phash, err = util.GetLogicalFileHash(eviFile.GetHandle(), sha3.New256(), partition.Start, partition.Size, true)
-				if err != nil {
-					return err
-				}
-			}
-			eviFile.UpdateInternalObjects(partition.Start, partition.Size, phash)
-
-			ehash, err := eviFile.GetEncodedHash()
-			if err != nil {
-				return err
-			}
-			pname := string(util.AppendToBytesSlice(ehash, cnst.DataSeperator, eviFile.GetName(), "_", cnst.PartitionIndexPrefix, index))
-			pfile := structs.NewInputFile(
-				db,
-				eviFile.GetHandle(),
-				eviFile.GetMappedFile(),
-				pname,
-				cnst.PartiFileNamespace,
-				phash,
-				partition.Size,
-				partition.Start,
-			)
-
-			go parser.IndexEXFAT(pfile, idxChan)
-			if !syncIndex {
-				active++
-			}
-			if syncIndex {
-				idxChan <- nil
-				err = <-idxChan
-				if errors.Is(err, cnst.ErrIncompatibleFile) {
-					continue
-				}
-				if err != nil {
-					return err
-				}
-			}
-			if err != nil {
-				return err
-			}
+		plans, ranges, err = planPartitions(eviFile)
+		if err != nil {
+			return err
 		}
 	}
 
 	eviname := filepath.Base(evipath)
 	fmt.Printf("\nSaving Evidence File: %s\n", eviname)
-	if !syncIndex && active > 0 {
-		fmt.Println("(indexer running async)")
+	err = store.Ingest(&eviFile, ranges)
+	if err != nil {
+		return err
+	}
+
+	ehash, err := eviFile.GetEncodedHash()
+	if err != nil {
+		return err
+	}
+	entry.Hashes = append(entry.Hashes, string(ehash))
+	if isImage {
+		acq.DigestMismatch = checkImageDigests(acq.Digests, eviFile.GetDigests())
+	}
+	err = finishEvidenceFile(&eviFile, plans, acq)
+	if err != nil {
+		return err
+	}
+	return closeEvidenceFile(eviFile)
+}
+
+// checkImageDigests warns when the media does not hash to what the acquisition tool recorded,
+// the digests that disagree are returned to be kept with the acquisition metadata
+func checkImageDigests(recorded, computed digest.Digests) []string {
+	if len(recorded.MD5) > 0 && !bytes.Equal(recorded.MD5, computed.MD5) {
+		color.Red("⚠️  MD5 recorded in the image is %x, the media hashes to %x", recorded.MD5, computed.MD5)
+	}
+	if len(recorded.SHA1) > 0 && !bytes.Equal(recorded.SHA1, computed.SHA1) {
+		color.Red("⚠️  SHA-1 recorded in the image is %x, the media hashes to %x", recorded.SHA1, computed.SHA1)
+	}
+	return recorded.Mismatches(computed)
+}
+
+// finishEvidenceFile creates the objects of an ingested evidence file and marks it complete,
+// an evidence file that was already stored only gets the new name and acquisition metadata
+func finishEvidenceFile(eviFile *structs.InputFile, plans []partitionPlan, acq structs.Acquisition) error {
+	err := store.EvidenceFilePreStoreCheck(*eviFile)
+	if err != nil && err != badger.ErrKeyNotFound && err != cnst.ErrIncompleteFile {
+		return err
+	}
+	stored := err == nil
+
+	for index, plan := range plans {
+		err = storePartition(eviFile, index, plan)
+		if err != nil {
+			return err
+		}
+	}
+	if stored {
+		// an image first stored from a stream or with --no-index gets its partitions now
+		err = store.AddInternalObjects(eviFile.GetID(), eviFile.GetInternalObjects(), eviFile.GetDB())
+		if err != nil {
+			return err
+		}
+		return store.SetAcquisition(eviFile.GetID(), acq, eviFile.GetDB())
 	}
 
 	echan := make(chan error)
-	go store.Store(eviFile, echan)
+	go store.Store(*eviFile, echan)
 	err = <-echan
 	if err != nil {
 		return err
 	}
 
-	if !syncIndex {
-		for active > 0 {
+	err = store.CompleteEvidenceFile(*eviFile)
+	if err != nil {
+		return err
+	}
+	return store.SetAcquisition(eviFile.GetID(), acq, eviFile.GetDB())
+}
+
+// partitionPlan is a partition found before the ingest pass along with the files
+// the pass has to hash for it, whole is set when the partition spans the evidence file
+type partitionPlan struct {
+	partition structs.IngestRange
+	table     structs.PartitionTableEntry
+	whole     bool
+	entries   []*structs.IngestRange
+}
+
+func planPartitions(eviFile structs.InputFile) ([]partitionPlan, []*structs.IngestRange, error) {
+	var plans []partitionPlan
+	var ranges []*structs.IngestRange
+
+	partitions := parser.GetPartitions(eviFile.GetSize(), eviFile.GetReader())
+	for _, partition := range partitions {
+		plan := partitionPlan{
+			partition: structs.IngestRange{Start: partition.Start, Size: partition.Size},
+			table:     partition.Table,
+			whole:     partition.Start == 0 && partition.Size == eviFile.GetSize(),
+		}
 
-			select {
-			case err = <-idxChan:
-				if err != nil && err != cnst.ErrIncompatibleFileSystem {
-					return err
-				}
-			default:
-				fmt.Println()
-				idxChan <- nil
-				err = <-idxChan
-				if err != nil && err != cnst.ErrIncompatibleFileSystem {
-					return err
-				}
-			}
+		entries, err := parser.ListFiles(eviFile.GetReader(), partition)
+		if err != nil && err != cnst.ErrIncompatibleFileSystem {
+			return nil, nil, err
+		}
+		plan.entries = entries
+		plans = append(plans, plan)
+	}
+
+	for index := range plans {
+		if !plans[index].whole {
+			ranges = append(ranges, &plans[index].partition)
+		}
+		ranges = append(ranges, plans[index].entries...)
+	}
+	return plans, ranges, nil
+}
 
-			active--
+func storePartition(eviFile *structs.InputFile, index int, plan partitionPlan) error {
+	phash := eviFile.GetHash()
+	pdigests := eviFile.GetDigests()
+	if !plan.whole {
+		if !plan.partition.Hashed() {
+			return nil
 		}
+		phash = plan.partition.Hash
+		pdigests = plan.partition.Digests
 	}
+	eviFile.UpdateInternalObjects(plan.partition.Start, plan.partition.Size, phash)
 
-	eviNode, err := dbio.GetEvidenceFile(eviFile.GetID(), eviFile.GetDB())
+	ehash, err := eviFile.GetEncodedHash()
 	if err != nil {
 		return err
 	}
-	eviNode.Completed = true
-	err = dbio.SetFile(eviFile.GetID(), eviNode, eviFile.GetDB())
+	pname := string(util.AppendToBytesSlice(ehash, cnst.DataSeperator, eviFile.GetName(), "_", cnst.PartitionIndexPrefix, index))
+	pfile := structs.NewInputFile(
+		eviFile.GetDB(),
+		eviFile.GetHandle(),
+		eviFile.GetMappedFile(),
+		pname,
+		cnst.PartiFileNamespace,
+		phash,
+		plan.partition.Size,
+		plan.partition.Start,
+	)
+	pfile.SetDigests(pdigests)
+	pfile.SetReader(eviFile.GetReader())
+	pfile.SetPartitionTable(plan.table)
+
+	err = parser.IndexFiles(&pfile, plan.entries)
 	if err != nil {
 		return err
 	}
 
+	pchan := make(chan error)
+	go store.Store(pfile, pchan)
+	return <-pchan
+}
+
+func closeEvidenceFile(eviFile structs.InputFile) error {
 	mappedFile := eviFile.GetMappedFile()
-	err = mappedFile.Unmap()
-	if err != nil {
-		return err
+	if mappedFile == nil {
+		if closer, ok := eviFile.GetReader().(io.Closer); ok {
+			return closer.Close()
+		}
+		return nil
 	}
-	err = eviFile.GetHandle().Close()
+	err := mappedFile.Unmap()
 	if err != nil {
 		return err
 	}
-	fmt.Printf("\nStored in: %v\n\n", time.Since(start))
-	return nil
+	return eviFile.GetHandle().Close()
 }
 
 func initEvidenceFile(evifilepath string, db *badger.DB) (structs.InputFile, error) {
@@ -217,12 +386,28 @@ func initEvidenceFile(evifilepath string, db *badger.DB) (structs.InputFile, err
 		return eviFile, err
 	}
 	eviSize := eviInfo.Size()
-	eviHandle, err := os.Open(evifilepath)
+	eviFileName := filepath.Base(evifilepath)
+	provisionalHash, err := store.ProvisionalHash(evifilepath, eviInfo)
 	if err != nil {
 		return eviFile, err
 	}
-	eviFileName := filepath.Base(evifilepath)
-	eviFileHash, err := util.GetFileHash(eviHandle, sha3.New256())
+
+	// an E01 is stored as the media it holds, read through its chunk tables
+	segment := ewf.SegmentNumber(evifilepath)
+	if segment > 1 {
+		return eviFile, fmt.Errorf(cnst.ErrEWFNotFirstSegment.Error(), eviFileName, segment)
+	}
+	if segment == 1 {
+		image, err := ewf.Open(evifilepath)
+		if err != nil {
+			return eviFile, err
+		}
+		eviFile = structs.NewInputFile(db, nil, nil, eviFileName, cnst.EviFileNamespace, provisionalHash, image.Size(), 0)
+		eviFile.SetReader(image)
+		return eviFile, nil
+	}
+
+	eviHandle, err := os.Open(evifilepath)
 	if err != nil {
 		return eviFile, err
 	}
@@ -238,10 +423,38 @@ func initEvidenceFile(evifilepath string, db *badger.DB) (structs.InputFile, err
 		mappedFile,
 		eviFileName,
 		cnst.EviFileNamespace,
-		eviFileHash,
+		provisionalHash,
 		eviSize,
 		0,
 	)
 
 	return eviFile, nil
 }
+
+// ParseAcquisition builds the acquisition metadata given on the command line,
+// acquired can be a plain date or a full RFC 3339 timestamp
+func ParseAcquisition(caseNumber, exhibitID, examiner, acquired, serial, notes string, tags map[string]string) (structs.Acquisition, error) {
+	acq := structs.Acquisition{
+		CaseNumber:   caseNumber,
+		ExhibitID:    exhibitID,
+		Examiner:     examiner,
+		DeviceSerial: serial,
+		Notes:        notes,
+	}
+	if len(tags) > 0 {
+		acq.Tags = tags
+	}
+	if acquired == "" {
+		return acq, nil
+	}
+
+	acquiredAt, err := time.Parse(time.RFC3339, acquired)
+	if err != nil {
+		acquiredAt, err = time.Parse(cnst.AcquiredAtDateLayout, acquired)
+	}
+	if err != nil {
+		return acq, cnst.ErrAcquiredAt
+	}
+	acq.AcquiredAt = acquiredAt.UTC()
+	return acq, nil
+}
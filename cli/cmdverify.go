@@ -0,0 +1,22 @@
+package cli
+
+import (
+	"indicer/lib/audit"
+	"indicer/lib/cnst"
+	"indicer/lib/store"
+)
+
+func VerifyData(chonkSize int, dbpath string, password []byte) error {
+	db, _, err := Common(chonkSize, dbpath, password)
+	if err != nil {
+		return err
+	}
+	entry := audit.Begin(cnst.CmdVerify, "")
+	verr := store.Verify(db)
+	verr = audit.Record(db, entry, verr)
+	err = db.Close()
+	if verr != nil {
+		return verr
+	}
+	return err
+}
@@ -1,16 +1,18 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"indicer/lib/cnst"
 	"indicer/lib/dbio"
 	"indicer/lib/util"
+	"os"
 
 	"github.com/dgraph-io/badger/v4"
 	"github.com/fatih/color"
 )
 
-func Common(chonkSize int, dbpath string, key []byte) (*badger.DB, string, error) {
+func Common(chonkSize int, dbpath string, password []byte) (*badger.DB, string, error) {
 	var err error
 	if dbpath == "" {
 		dbpath, err = util.GetDBPath()
@@ -20,14 +22,68 @@ func Common(chonkSize int, dbpath string, key []byte) (*badger.DB, string, error
 	}
 	util.SetChonkSize(chonkSize)
 
+	err = applyManifest(dbpath)
+	if err != nil {
+		return nil, "", err
+	}
+
 	// Recommend container mode for small chunk sizes
-	if chonkSize < 128 && !cnst.CONTAINERMODE {
-		color.Yellow("\n⚠️  RECOMMENDATION: You're using a chunk size of %dKB (< 128KB).", chonkSize)
+	if cnst.ChonkSize < 128*cnst.KB && !cnst.CONTAINERMODE {
+		color.Yellow("\n⚠️  RECOMMENDATION: You're using a chunk size of %dKB (< 128KB).", cnst.ChonkSize/cnst.KB)
 		color.Yellow("   Consider using --container flag for better filesystem efficiency.")
 		color.Yellow("   Container mode packs multiple small chunks into 1GB compressed files.\n")
 		fmt.Println()
 	}
 
-	db, err := dbio.ConnectDB(dbpath, key)
-	return db, dbpath, err
+	db, err := dbio.UnlockDB(dbpath, password)
+	if err != nil {
+		return db, dbpath, err
+	}
+
+	// without a manifest the flags decide how relations are read, which is only
+	// safe once they are checked against what a legacy database was written with
+	err = dbio.AdoptManifest(db)
+	if err != nil {
+		return nil, "", errors.Join(err, db.Close())
+	}
+	return db, dbpath, nil
+}
+
+// applyManifest makes the database's own storage settings win over the cli flags
+// relation offsets are only meaningful with the chonk size they were written with
+func applyManifest(dbpath string) error {
+	manifest, err := dbio.LoadManifest(dbpath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if manifest.Version > cnst.ManifestVersion {
+		return fmt.Errorf(cnst.ErrManifestVersion.Error(), manifest.Version, cnst.ManifestVersion)
+	}
+
+	if manifest.ChonkSize != cnst.ChonkSize {
+		color.Yellow("⚠️  database was created with %dKB chunks, ignoring --%s %dKB", manifest.ChonkSize/cnst.KB, cnst.FlagChonkSize, cnst.ChonkSize/cnst.KB)
+		cnst.ChonkSize = manifest.ChonkSize
+	}
+	if manifest.QuickMode != cnst.QUICKOPT {
+		color.Yellow("⚠️  database was created with --%s=%v, using that instead", cnst.FlagFastMode, manifest.QuickMode)
+		cnst.QUICKOPT = manifest.QuickMode
+	}
+	if manifest.ContainerMode != cnst.CONTAINERMODE {
+		color.Yellow("⚠️  database was created with --%s=%v, using that instead", cnst.FlagContainerMode, manifest.ContainerMode)
+		cnst.CONTAINERMODE = manifest.ContainerMode
+	}
+	if manifest.HierarchicalIndex != cnst.HIERARCHICALINDEX {
+		color.Yellow("⚠️  database was created with --%s=%v, using that instead", cnst.FlagHierarchicalIndex, manifest.HierarchicalIndex)
+		cnst.HIERARCHICALINDEX = manifest.HierarchicalIndex
+	}
+	if manifest.CDCMode != cnst.CDCMODE {
+		color.Yellow("⚠️  database was created with --%s=%v, using that instead", cnst.FlagCDCMode, manifest.CDCMode)
+		cnst.CDCMODE = manifest.CDCMode
+	}
+
+	return nil
 }
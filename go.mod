@@ -8,11 +8,9 @@ require (
 	github.com/aoiflux/libxfat v1.0.2
 	github.com/cheggaaa/pb/v3 v3.1.7
 	github.com/dgraph-io/badger/v4 v4.8.0
-	github.com/diskfs/go-diskfs v1.7.0
 	github.com/dustin/go-humanize v1.0.1
 	github.com/edsrzf/mmap-go v1.2.0
 	github.com/fatih/color v1.18.0
-	github.com/google/uuid v1.6.0
 	github.com/klauspost/compress v1.18.0
 	github.com/schollz/progressbar/v3 v3.18.0
 	github.com/shirou/gopsutil/v3 v3.24.5
@@ -0,0 +1,134 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"indicer/lib/cnst"
+	"indicer/lib/dbio"
+	"indicer/lib/structs"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/fatih/color"
+)
+
+// Begin starts an entry for operation, the operator and host are the local
+// user and machine running DUES
+func Begin(operation, input string) structs.AuditEntry {
+	var entry structs.AuditEntry
+	entry.Operation = operation
+	entry.Input = input
+	entry.StartedAt = time.Now().UTC()
+	entry.ToolVersion = cnst.ToolVersion
+	entry.Operator = cnst.AuditUnknownUser
+	if current, err := user.Current(); err == nil {
+		entry.Operator = current.Username
+	}
+	entry.Host, _ = os.Hostname()
+	return entry
+}
+
+// Record closes entry with the outcome of opErr and appends it to the chain.
+// opErr is handed back as is, an audit failure is only returned when the operation itself succeeded
+func Record(db *badger.DB, entry structs.AuditEntry, opErr error, hashes ...string) error {
+	entry.FinishedAt = time.Now().UTC()
+	entry.Hashes = append(entry.Hashes, hashes...)
+	entry.Outcome = cnst.AuditOutcomeOK
+	if opErr != nil {
+		entry.Outcome = opErr.Error()
+	}
+
+	err := dbio.AppendAuditEntry(entry, db)
+	if opErr != nil {
+		return opErr
+	}
+	return err
+}
+
+// Show prints every entry in the chain and checks the links between them
+func Show(db *badger.DB) error {
+	entries, err := dbio.GetAuditEntries(db)
+	if err != nil {
+		return err
+	}
+
+	broken := 0
+	var prev structs.AuditEntry
+	for i, entry := range entries {
+		printEntry(entry)
+		problems, err := checkEntry(i, entry, prev)
+		if err != nil {
+			return err
+		}
+		for _, problem := range problems {
+			color.Red("\t✘ %s", problem)
+		}
+		if len(problems) > 0 {
+			broken++
+		}
+		prev = entry
+	}
+
+	fmt.Printf("\n%d audit entries, %d broken\n\n", len(entries), broken)
+	if broken > 0 {
+		return cnst.ErrAuditChainBroken
+	}
+	color.Green("✔ audit chain verified")
+	return nil
+}
+
+// Export writes the chain to a json file outside the database and returns its path
+func Export(db *badger.DB) (string, error) {
+	entries, err := dbio.GetAuditEntries(db)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(entries, "", "\t")
+	if err != nil {
+		return "", err
+	}
+
+	fpath := fmt.Sprintf(cnst.AuditExportFile, time.Now().Unix())
+	return fpath, os.WriteFile(fpath, data, os.ModePerm)
+}
+
+func checkEntry(index int, entry, prev structs.AuditEntry) ([]string, error) {
+	var problems []string
+
+	if entry.Seq != uint64(index+1) {
+		problems = append(problems, fmt.Sprintf("sequence %d found where %d was expected", entry.Seq, index+1))
+	}
+	if !bytes.Equal(entry.PrevHash, prev.Hash) {
+		problems = append(problems, "previous hash does not match the entry before it")
+	}
+	hash, err := dbio.AuditEntryHash(entry)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(hash, entry.Hash) {
+		problems = append(problems, "entry hash does not match its contents")
+	}
+
+	return problems, nil
+}
+
+func printEntry(entry structs.AuditEntry) {
+	fmt.Printf("#%d %s %s\n", entry.Seq, entry.StartedAt.Local().Format(time.RFC3339), entry.Operation)
+	fmt.Printf("\tOperator: %s@%s\n", entry.Operator, entry.Host)
+	if entry.Peer != "" {
+		fmt.Printf("\tPeer: %s\n", entry.Peer)
+	}
+	if entry.Input != "" {
+		fmt.Printf("\tInput: %s\n", entry.Input)
+	}
+	for _, hash := range entry.Hashes {
+		fmt.Printf("\tHash: %s\n", hash)
+	}
+	fmt.Printf("\tFinished: %s (%s)\n", entry.FinishedAt.Local().Format(time.RFC3339), entry.ToolVersion)
+	fmt.Printf("\tOutcome: %s\n", entry.Outcome)
+	fmt.Printf("\tEntry Hash: %s\n", base64.StdEncoding.EncodeToString(entry.Hash))
+}
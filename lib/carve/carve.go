@@ -0,0 +1,216 @@
+package carve
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"indicer/lib/cnst"
+	"indicer/lib/dbio"
+	"indicer/lib/parser"
+	"indicer/lib/store"
+	"indicer/lib/structs"
+	"indicer/lib/util"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/schollz/progressbar/v3"
+)
+
+// hit is a header found at offset of the evidence file
+type hit struct {
+	offset int64
+	sig    signature
+}
+
+// Carve scans an evidence file or partition for file signatures and stores every file it
+// can find the end of as an indexed file of that partition. Carving a whole evidence file
+// puts the files in a partition spanning it. The hashes of the carved files are returned
+func Carve(fhash string, db *badger.DB) ([]string, error) {
+	fid, err := dbio.GuessFileType(fhash, db)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(fid, []byte(cnst.IdxFileNamespace)) {
+		return nil, fmt.Errorf(cnst.ErrCarveIndexedFile.Error(), fhash)
+	}
+	meta, err := store.GetFileMeta(fid, db)
+	if err != nil {
+		return nil, err
+	}
+	eid := util.GetEvidenceFileID(meta.EviHash)
+	efile, err := dbio.GetEvidenceFile(eid, db)
+	if err != nil {
+		return nil, err
+	}
+
+	r := store.NewStoredReader(meta.EviHash, efile.Size, db)
+	ranges, err := scan(meta, r, db)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Carved %d files\n", len(ranges))
+	if len(ranges) == 0 {
+		return nil, nil
+	}
+	err = store.HashStoredRanges(meta.EviHash, efile.Size, ranges, db)
+	if err != nil {
+		return nil, err
+	}
+
+	pfile, err := carveParent(fid, meta.EviHash, efile, db)
+	if err != nil {
+		return nil, err
+	}
+	err = parser.IndexFiles(&pfile, ranges)
+	if err != nil {
+		return nil, err
+	}
+	pchan := make(chan error)
+	go store.Store(pfile, pchan)
+	err = <-pchan
+	if err != nil {
+		return nil, err
+	}
+	err = store.AddPartitionInternalObjects(pfile.GetID(), pfile.GetInternalObjects(), db)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(fid, []byte(cnst.EviFileNamespace)) {
+		whole := map[string]structs.InternalOffset{base64.StdEncoding.EncodeToString(meta.EviHash): {Start: 0, End: efile.Size - 1}}
+		err = store.AddInternalObjects(eid, whole, db)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var hashes []string
+	for ohash := range pfile.GetInternalObjects() {
+		hashes = append(hashes, ohash)
+	}
+	return hashes, nil
+}
+
+// scan reads the object described by meta chonk by chonk through its relations and carves
+// a file at every header whose end can be found. Headers inside a file carved before are skipped
+func scan(meta structs.FileMeta, r io.ReaderAt, db *badger.DB) ([]*structs.IngestRange, error) {
+	end := meta.Start + meta.Size
+	indices, err := dbio.GetRelationIndices(meta.EviHash, meta.Start, end, db)
+	if err != nil {
+		return nil, err
+	}
+
+	bar := progressbar.DefaultBytes(meta.Size, "carving")
+	var ranges []*structs.IngestRange
+	var carry []byte
+	next := meta.Start
+	for _, index := range indices {
+		relKey := util.AppendToBytesSlice(cnst.RelationNamespace, meta.EviHash, cnst.DataSeperator, index)
+		chash, err := dbio.GetNode(relKey, db)
+		if err != nil {
+			return nil, err
+		}
+		data, err := dbio.GetChonkData(index, meta.Start, end, util.AppendToBytesSlice(cnst.ChonkNamespace, chash), db)
+		if err != nil {
+			return nil, err
+		}
+		bar.Add(len(data))
+
+		// the tail of the last chonk is kept for headers cut by the chonk boundary
+		window := append(append([]byte{}, carry...), data...)
+		windowStart := max(index, meta.Start) - int64(len(carry))
+		var carved []*structs.IngestRange
+		carved, next, err = carveHits(r, findHeaders(window, windowStart), meta.Start, end, next)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, carved...)
+		carry = window[max(len(window)-(maxHeader-1), 0):]
+	}
+	bar.Finish()
+	return ranges, bar.Close()
+}
+
+// carveHits carves a file at every hit from next on whose end can be found before end,
+// files are named by their offset from start. It returns where the next file can begin
+func carveHits(r io.ReaderAt, hits []hit, start, end, next int64) ([]*structs.IngestRange, int64, error) {
+	var ranges []*structs.IngestRange
+	for _, found := range hits {
+		if found.offset < next {
+			continue
+		}
+		fileEnd, ok, err := found.sig.end(r, found.offset, min(end, found.offset+found.sig.maxSize))
+		if err != nil {
+			return nil, next, err
+		}
+		if !ok {
+			next = found.offset + 1
+			continue
+		}
+
+		ext := found.sig.ext
+		if found.sig.kind != nil {
+			ext = cmp.Or(found.sig.kind(r, found.offset, fileEnd), ext)
+		}
+		name := fmt.Sprintf(cnst.CarvedName, found.offset-start, ext)
+		ranges = append(ranges, &structs.IngestRange{Name: name, Start: found.offset, Size: fileEnd - found.offset})
+		next = fileEnd
+	}
+	return ranges, next, nil
+}
+
+// findHeaders returns the headers in data in the order they appear, data starts at offset
+func findHeaders(data []byte, offset int64) []hit {
+	var hits []hit
+	for _, sig := range signatures {
+		for index := 0; ; {
+			found := bytes.Index(data[index:], sig.header)
+			if found < 0 {
+				break
+			}
+			hits = append(hits, hit{offset: offset + int64(index+found), sig: sig})
+			index += found + 1
+		}
+	}
+	slices.SortFunc(hits, func(a, b hit) int {
+		return cmp.Compare(a.offset, b.offset)
+	})
+	return hits
+}
+
+// carveParent returns the partition carved files are stored in, named as it is in the
+// evidence file ehash. A whole evidence file gets a partition spanning it, unless it already has one
+func carveParent(fid, ehash []byte, efile structs.EvidenceFile, db *badger.DB) (structs.InputFile, error) {
+	encodedEhash := base64.StdEncoding.EncodeToString(ehash)
+	pid := fid
+	if bytes.HasPrefix(fid, []byte(cnst.EviFileNamespace)) {
+		pid = util.AppendToBytesSlice(cnst.PartiFileNamespace, ehash)
+	}
+	phash := bytes.Split(pid, []byte(cnst.NamespaceSeperator))[1]
+
+	pfile, err := dbio.GetPartitionFile(pid, db)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		pname := string(util.AppendToBytesSlice(encodedEhash, cnst.DataSeperator, util.GetArbitratyMapKey(efile.Names), cnst.CarvedPartitionSuffix))
+		infile := structs.NewInputFile(db, nil, nil, pname, cnst.PartiFileNamespace, phash, efile.Size, 0)
+		infile.SetDigests(efile.Digests)
+		return infile, nil
+	}
+	if err != nil {
+		return structs.InputFile{}, err
+	}
+
+	var pname string
+	for name := range pfile.Names {
+		if strings.HasPrefix(name, encodedEhash+cnst.DataSeperator) {
+			pname = name
+			break
+		}
+	}
+	infile := structs.NewInputFile(db, nil, nil, pname, cnst.PartiFileNamespace, phash, pfile.Size, pfile.Start)
+	infile.SetDigests(pfile.Digests)
+	infile.SetPartitionTable(pfile.Table)
+	return infile, nil
+}
@@ -0,0 +1,168 @@
+package carve
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"indicer/lib/cnst"
+	"testing"
+)
+
+// testJPEG has a thumbnail with its own start and end of image markers in an APP1 segment
+func testJPEG() []byte {
+	thumbnail := []byte{0xFF, 0xD8, 0xFF, 0xDB, 0x00, 0x02, 0xFF, 0xD9}
+	data := []byte{0xFF, 0xD8, 0xFF, 0xE1}
+	data = binary.BigEndian.AppendUint16(data, uint16(2+len(thumbnail)))
+	data = append(data, thumbnail...)
+	data = append(data, 0xFF, 0xDA, 0x00, 0x08, 1, 2, 3, 4, 5, 6)
+	// entropy coded data escapes its 0xFF bytes with a 0x00
+	data = append(data, 0x12, 0xFF, 0x00, 0x34, 0xFF, 0xD0, 0x56)
+	return append(data, 0xFF, 0xD9)
+}
+
+func testPNGChunk(ctype string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, ctype...)
+	chunk = append(chunk, data...)
+	return append(chunk, 0, 0, 0, 0)
+}
+
+func testPNG() []byte {
+	data := []byte("\x89PNG\r\n\x1a\n")
+	data = append(data, testPNGChunk("IHDR", make([]byte, 13))...)
+	data = append(data, testPNGChunk("IDAT", []byte{1, 2, 3})...)
+	return append(data, testPNGChunk("IEND", nil)...)
+}
+
+// testGIF has a global color table, a graphic control extension and one image
+func testGIF() []byte {
+	data := []byte("GIF89a")
+	data = append(data, 1, 0, 1, 0, 0x80, 0, 0)
+	data = append(data, make([]byte, 6)...)
+	data = append(data, 0x21, 0xF9, 4, 0, 0, 0, 0, 0)
+	data = append(data, 0x2C, 0, 0, 0, 0, 1, 0, 1, 0, 0)
+	data = append(data, 2, 2, 0x4C, 0x01, 0)
+	return append(data, 0x3B)
+}
+
+func testPDF() []byte {
+	return []byte("%PDF-1.4\n1 0 obj\n<< >>\nendobj\ntrailer\n<< >>\n%%EOF\r\n")
+}
+
+// testZIP is a single entry archive, an OOXML part name in its central directory makes it a document
+func testZIP(part string) []byte {
+	local := append([]byte("PK\x03\x04"), make([]byte, 26)...)
+	binary.LittleEndian.PutUint16(local[26:], uint16(len(part)))
+	local = append(local, part...)
+
+	central := append([]byte("PK\x01\x02"), make([]byte, 42)...)
+	binary.LittleEndian.PutUint16(central[28:], uint16(len(part)))
+	central = append(central, part...)
+
+	record := append([]byte("PK\x05\x06"), make([]byte, 18)...)
+	binary.LittleEndian.PutUint32(record[12:], uint32(len(central)))
+	binary.LittleEndian.PutUint32(record[16:], uint32(len(local)))
+	return append(append(local, central...), record...)
+}
+
+func testSQLite(pages uint32) []byte {
+	data := make([]byte, 512*pages)
+	copy(data, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(data[16:], 512)
+	binary.BigEndian.PutUint32(data[24:], 7)
+	binary.BigEndian.PutUint32(data[28:], pages)
+	binary.BigEndian.PutUint32(data[92:], 7)
+	return data
+}
+
+// testJunk holds no header of any signature
+var testJunk = bytes.Repeat([]byte("junk"), 25)
+
+func TestCarveHits(t *testing.T) {
+	stale := testSQLite(2)
+	binary.BigEndian.PutUint32(stale[92:], 6)
+	badZIP := testZIP("a.txt")
+	binary.LittleEndian.PutUint32(badZIP[len(badZIP)-6:], 1)
+	png := testPNG()
+
+	tests := []struct {
+		name string
+		file []byte
+		ext  string
+		// size is the size of the carved file, 0 when nothing is carved
+		size int
+	}{
+		{name: "jpeg with a thumbnail", file: testJPEG(), ext: "jpg", size: len(testJPEG())},
+		{name: "png", file: png, ext: "png", size: len(png)},
+		{name: "png cut before IEND", file: png[:len(png)-12]},
+		{name: "gif", file: testGIF(), ext: "gif", size: len(testGIF())},
+		{name: "gif without a trailer", file: testGIF()[:len(testGIF())-1]},
+		// the line break after the end of file marker belongs to the PDF
+		{name: "pdf", file: testPDF(), ext: "pdf", size: len(testPDF())},
+		{name: "zip", file: testZIP("a.txt"), ext: "zip", size: len(testZIP("a.txt"))},
+		{name: "docx", file: testZIP("word/document.xml"), ext: "docx", size: len(testZIP("word/document.xml"))},
+		{name: "zip whose end record points elsewhere", file: badZIP},
+		{name: "sqlite", file: testSQLite(2), ext: "sqlite", size: 1024},
+		{name: "sqlite with a stale page count", file: stale},
+		{name: "sqlite running past the evidence", file: testSQLite(2)[:600]},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := append(append(append([]byte{}, testJunk...), test.file...), testJunk...)
+			if test.size == 0 {
+				data = append([]byte{}, data[:len(testJunk)+len(test.file)]...)
+			}
+			ranges, _, err := carveHits(bytes.NewReader(data), findHeaders(data, 0), 0, int64(len(data)), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.size == 0 {
+				if len(ranges) != 0 {
+					t.Fatalf("carved %s at %d", ranges[0].Name, ranges[0].Start)
+				}
+				return
+			}
+			if len(ranges) != 1 {
+				t.Fatalf("carved %d files, want 1", len(ranges))
+			}
+			want := fmt.Sprintf(cnst.CarvedName, len(testJunk), test.ext)
+			if ranges[0].Name != want || ranges[0].Start != int64(len(testJunk)) || ranges[0].Size != int64(test.size) {
+				t.Errorf("got %s at %d of %d bytes, want %s of %d bytes", ranges[0].Name, ranges[0].Start, ranges[0].Size, want, test.size)
+			}
+		})
+	}
+}
+
+func TestCarveHitsNext(t *testing.T) {
+	data := append(append(testJPEG(), testPNG()...), testGIF()...)
+	ranges, next, err := carveHits(bytes.NewReader(data), findHeaders(data, 0), 0, int64(len(data)), int64(len(testJPEG())+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranges) != 1 || ranges[0].Start != int64(len(testJPEG())+len(testPNG())) {
+		t.Fatalf("got %d files, want only the gif after next", len(ranges))
+	}
+	if next != int64(len(data)) {
+		t.Errorf("got next %d, want %d", next, len(data))
+	}
+}
+
+func FuzzCarveHits(f *testing.F) {
+	for _, seed := range [][]byte{testJPEG(), testPNG(), testGIF(), testPDF(), testZIP("word/document.xml"), testSQLite(2)} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ranges, _, err := carveHits(bytes.NewReader(data), findHeaders(data, 0), 0, int64(len(data)), 0)
+		if err != nil {
+			return
+		}
+		var next int64
+		for _, carved := range ranges {
+			if carved.Start < next || carved.Size <= 0 || carved.Start+carved.Size > int64(len(data)) {
+				t.Fatalf("carved %d bytes at %d after %d of %d", carved.Size, carved.Start, next, len(data))
+			}
+			next = carved.Start + carved.Size
+		}
+	})
+}
@@ -0,0 +1,282 @@
+package carve
+
+import (
+	"bytes"
+	"encoding/binary"
+	"indicer/lib/cnst"
+	"io"
+)
+
+// footerBlock is how much is read at a time while looking for a footer
+const footerBlock = cnst.MB
+
+// signature finds one kind of file by its header, end returns where a file starting at
+// start ends, ok is false when it does not end before limit
+type signature struct {
+	ext     string
+	header  []byte
+	maxSize int64
+	end     func(r io.ReaderAt, start, limit int64) (end int64, ok bool, err error)
+	// kind names files whose extension depends on what is inside them, like OOXML documents
+	kind func(r io.ReaderAt, start, end int64) string
+}
+
+var signatures = []signature{
+	{ext: "jpg", header: []byte{0xFF, 0xD8, 0xFF}, maxSize: 64 * cnst.MB, end: jpegEnd},
+	{ext: "png", header: []byte("\x89PNG\r\n\x1a\n"), maxSize: 64 * cnst.MB, end: pngEnd},
+	{ext: "gif", header: []byte("GIF87a"), maxSize: 64 * cnst.MB, end: gifEnd},
+	{ext: "gif", header: []byte("GIF89a"), maxSize: 64 * cnst.MB, end: gifEnd},
+	{ext: "pdf", header: []byte("%PDF-"), maxSize: 256 * cnst.MB, end: pdfEnd},
+	{ext: "zip", header: []byte("PK\x03\x04"), maxSize: cnst.GB, end: zipEnd, kind: zipKind},
+	{ext: "sqlite", header: []byte("SQLite format 3\x00"), maxSize: 4 * cnst.GB, end: sqliteEnd},
+}
+
+// maxHeader is the longest header, a header can start this many bytes minus one before a chonk ends
+var maxHeader = func() int {
+	var longest int
+	for _, sig := range signatures {
+		longest = max(longest, len(sig.header))
+	}
+	return longest
+}()
+
+// findFooter returns the offset of the first footer from start on that valid accepts
+func findFooter(r io.ReaderAt, footer []byte, start, limit int64, valid func(offset int64) (bool, error)) (int64, bool, error) {
+	block := make([]byte, footerBlock)
+	for offset := start; offset < limit; {
+		n, err := r.ReadAt(block[:min(int64(len(block)), limit-offset)], offset)
+		if err != nil && err != io.EOF {
+			return 0, false, err
+		}
+		if n < len(footer) {
+			return 0, false, nil
+		}
+
+		data := block[:n]
+		for index := bytes.Index(data, footer); index >= 0; {
+			ok, err := valid(offset + int64(index))
+			if err != nil {
+				return 0, false, err
+			}
+			if ok {
+				return offset + int64(index), true, nil
+			}
+			next := bytes.Index(data[index+1:], footer)
+			if next < 0 {
+				break
+			}
+			index += next + 1
+		}
+		// a footer can be cut by the end of a block
+		offset += int64(n - len(footer) + 1)
+		if err == io.EOF {
+			return 0, false, nil
+		}
+	}
+	return 0, false, nil
+}
+
+func anyFooter(int64) (bool, error) {
+	return true, nil
+}
+
+func readAt(r io.ReaderAt, size int, offset int64) ([]byte, error) {
+	data := make([]byte, size)
+	_, err := r.ReadAt(data, offset)
+	return data, err
+}
+
+// jpegEnd walks the segments up to the start of scan, then looks for the end of image
+// marker. Thumbnails are kept inside segments, so their end markers are skipped over
+func jpegEnd(r io.ReaderAt, start, limit int64) (int64, bool, error) {
+	for offset := start + 2; offset+4 <= limit; {
+		marker, err := readAt(r, 4, offset)
+		if err != nil {
+			return 0, false, err
+		}
+		if marker[0] != 0xFF {
+			return 0, false, nil
+		}
+		switch {
+		case marker[1] == 0xFF:
+			offset++
+		case marker[1] == 0xD9:
+			return offset + 2, true, nil
+		case marker[1] == 0x01 || (marker[1] >= 0xD0 && marker[1] <= 0xD8):
+			offset += 2
+		case marker[1] == 0xDA:
+			// entropy coded data escapes 0xFF, so the next end of image marker ends the file
+			eoi, ok, err := findFooter(r, []byte{0xFF, 0xD9}, offset+2, limit, anyFooter)
+			return eoi + 2, ok, err
+		default:
+			length := int64(binary.BigEndian.Uint16(marker[2:]))
+			if length < 2 {
+				return 0, false, nil
+			}
+			offset += 2 + length
+		}
+	}
+	return 0, false, nil
+}
+
+// pngEnd walks the chunks up to IEND
+func pngEnd(r io.ReaderAt, start, limit int64) (int64, bool, error) {
+	for offset := start + 8; offset+12 <= limit; {
+		chunk, err := readAt(r, 8, offset)
+		if err != nil {
+			return 0, false, err
+		}
+		length := int64(binary.BigEndian.Uint32(chunk))
+		for _, char := range chunk[4:] {
+			if (char < 'A' || char > 'Z') && (char < 'a' || char > 'z') {
+				return 0, false, nil
+			}
+		}
+		offset += 12 + length
+		if string(chunk[4:]) == "IEND" {
+			return offset, offset <= limit, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// gifEnd walks the extension and image blocks up to the trailer
+func gifEnd(r io.ReaderAt, start, limit int64) (int64, bool, error) {
+	if start+13 > limit {
+		return 0, false, nil
+	}
+	screen, err := readAt(r, 7, start+6)
+	if err != nil {
+		return 0, false, err
+	}
+	offset := start + 13 + colorTableSize(screen[4])
+
+	for offset < limit {
+		block, err := readAt(r, 10, offset)
+		if err != nil && err != io.EOF {
+			return 0, false, err
+		}
+		switch block[0] {
+		case 0x3B:
+			return offset + 1, true, nil
+		case 0x21:
+			offset += 2
+		case 0x2C:
+			offset += 10 + colorTableSize(block[9]) + 1
+		default:
+			return 0, false, nil
+		}
+		// both kinds of block end in data sub-blocks, ended by an empty one
+		for offset < limit {
+			size, err := readAt(r, 1, offset)
+			if err != nil {
+				return 0, false, err
+			}
+			offset += 1 + int64(size[0])
+			if size[0] == 0 {
+				break
+			}
+		}
+	}
+	return 0, false, nil
+}
+
+func colorTableSize(flags byte) int64 {
+	if flags&0x80 == 0 {
+		return 0
+	}
+	return 3 << (flags&0x07 + 1)
+}
+
+// pdfEnd ends a PDF at its first end of file marker along with the line break after it.
+// Later incremental updates are not followed
+func pdfEnd(r io.ReaderAt, start, limit int64) (int64, bool, error) {
+	marker := []byte("%%EOF")
+	eof, ok, err := findFooter(r, marker, start, limit, anyFooter)
+	if !ok || err != nil {
+		return 0, ok, err
+	}
+	end := eof + int64(len(marker))
+	tail, err := readAt(r, 2, end)
+	if err != nil && err != io.EOF {
+		return 0, false, err
+	}
+	switch {
+	case tail[0] == '\r' && tail[1] == '\n':
+		end += 2
+	case tail[0] == '\r' || tail[0] == '\n':
+		end++
+	}
+	return min(end, limit), true, nil
+}
+
+// zipEnd ends an archive at the end of central directory record that points back at it,
+// records of archives stored inside it point elsewhere
+func zipEnd(r io.ReaderAt, start, limit int64) (int64, bool, error) {
+	var end int64
+	eocd, ok, err := findFooter(r, []byte("PK\x05\x06"), start, limit, func(offset int64) (bool, error) {
+		record, err := readAt(r, 22, offset)
+		if err != nil {
+			return false, nil
+		}
+		size := int64(binary.LittleEndian.Uint32(record[12:]))
+		directory := int64(binary.LittleEndian.Uint32(record[16:]))
+		if start+directory+size != offset {
+			return false, nil
+		}
+		end = offset + 22 + int64(binary.LittleEndian.Uint16(record[20:]))
+		return end <= limit, nil
+	})
+	if !ok || err != nil {
+		return 0, false, err
+	}
+	return end, eocd+22 <= end, nil
+}
+
+// zipKind tells OOXML documents apart from plain archives by the parts in their central directory
+func zipKind(r io.ReaderAt, start, end int64) string {
+	// the record is followed by a comment of up to 64K
+	tail, err := readAt(r, int(min(end-start, 22+0xFFFF)), end-min(end-start, 22+0xFFFF))
+	if err != nil {
+		return ""
+	}
+	index := bytes.LastIndex(tail, []byte("PK\x05\x06"))
+	if index < 0 || index+22 > len(tail) {
+		return ""
+	}
+	record := tail[index:]
+	size := int64(binary.LittleEndian.Uint32(record[12:]))
+	directory, err := readAt(r, int(min(size, 16*cnst.MB)), start+int64(binary.LittleEndian.Uint32(record[16:])))
+	if err != nil {
+		return ""
+	}
+	for part, ext := range map[string]string{"word/document.xml": "docx", "xl/workbook.xml": "xlsx", "ppt/presentation.xml": "pptx"} {
+		if bytes.Contains(directory, []byte(part)) {
+			return ext
+		}
+	}
+	return ""
+}
+
+// sqliteEnd sizes a database from its header, the page count is only trusted while
+// the header says it was written by a version that keeps it up to date
+func sqliteEnd(r io.ReaderAt, start, limit int64) (int64, bool, error) {
+	if start+100 > limit {
+		return 0, false, nil
+	}
+	header, err := readAt(r, 100, start)
+	if err != nil {
+		return 0, false, err
+	}
+	pageSize := int64(binary.BigEndian.Uint16(header[16:]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	pages := int64(binary.BigEndian.Uint32(header[28:]))
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 || pages == 0 ||
+		binary.BigEndian.Uint32(header[24:]) != binary.BigEndian.Uint32(header[92:]) {
+		return 0, false, nil
+	}
+	end := start + pageSize*pages
+	return end, end <= limit, nil
+}
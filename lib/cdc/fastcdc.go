@@ -0,0 +1,99 @@
+package cdc
+
+// FastCDC content-defined chunker
+// Cut points are picked with a gear rolling hash, so an inserted or removed
+// byte only moves the boundaries around it instead of every later boundary.
+// Normalised chunking uses a harder mask below the average size and an easier
+// one above it, which keeps chunk sizes close to the average.
+
+import "math/bits"
+
+const (
+	MinDivisor = 4          // min chunk size = avg / MinDivisor
+	MaxFactor  = 4          // max chunk size = avg * MaxFactor
+	gearSeed   = 0x44554553 // "DUES", must never change or stored boundaries stop matching
+)
+
+var gear [256]uint64
+
+func init() {
+	// splitmix64, deterministic so every run produces identical boundaries
+	state := uint64(gearSeed)
+	for i := range gear {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		gear[i] = z ^ (z >> 31)
+	}
+}
+
+// Chunker holds the size limits and masks derived from an average chunk size
+type Chunker struct {
+	min   int64
+	avg   int64
+	max   int64
+	maskS uint64
+	maskL uint64
+}
+
+// New returns a chunker whose chunks average avg bytes
+func New(avg int64) Chunker {
+	if avg < MinDivisor {
+		avg = MinDivisor
+	}
+	n := bits.Len64(uint64(avg)) - 1
+	return Chunker{
+		min:   avg / MinDivisor,
+		avg:   avg,
+		max:   avg * MaxFactor,
+		maskS: topMask(n + 1),
+		maskL: topMask(n - 1),
+	}
+}
+
+func (c Chunker) Min() int64 { return c.min }
+func (c Chunker) Avg() int64 { return c.avg }
+func (c Chunker) Max() int64 { return c.max }
+
+// Cut returns the length of the first chunk in data
+func (c Chunker) Cut(data []byte) int64 {
+	n := int64(len(data))
+	if n <= c.min {
+		return n
+	}
+	if n > c.max {
+		n = c.max
+	}
+	normal := c.avg
+	if n < normal {
+		normal = n
+	}
+
+	var fp uint64
+	i := c.min
+	for ; i < normal; i++ {
+		fp = (fp << 1) + gear[data[i]]
+		if fp&c.maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		fp = (fp << 1) + gear[data[i]]
+		if fp&c.maskL == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
+
+// the high bits of the gear hash have seen the most input bytes, so masks use them
+func topMask(n int) uint64 {
+	if n <= 0 {
+		return 0
+	}
+	if n >= 64 {
+		return ^uint64(0)
+	}
+	return ((uint64(1) << n) - 1) << (64 - n)
+}
@@ -13,7 +13,7 @@ const (
 	FILE_EXISTS   = "EXISTS"
 	FILE_APPENDED = "APPENDED"
 	DefaultDBPath = "./data"
-	UploadsDir    = "./uploads"
+	ToolVersion   = "DUES v0.36"
 )
 
 const (
@@ -26,6 +26,8 @@ const (
 
 const (
 	CacheLimit              = GB
+	CheckpointSize          = 64 * MB
+	RelationWindow          = 64 * 1024
 	SectorSize       uint64 = 512
 	DefaultChonkSize        = 256 * KB
 	KeySize                 = 32
@@ -36,6 +38,9 @@ var MEMOPT bool
 var QUICKOPT bool
 var CONTAINERMODE bool
 var HIERARCHICALINDEX bool
+var CDCMODE bool
+var DELETEDOPT bool
+var ADOPTOPT bool
 var DB *badger.DB
 
 const (
@@ -45,10 +50,21 @@ const (
 	RelationNamespace        = "R|||:"
 	ReverseRelationNamespace = "Я|||:"
 	ChonkNamespace           = "C|||:"
+	AuditNamespace           = "A|||:"
+	DigestNamespace          = "H|||:"
+	PendingNamespace         = "W|||:"
 	NamespaceSeperator       = "|||:"
 	RangeSeperator           = "-"
 	DataSeperator            = "|||"
 	PartitionIndexPrefix     = "p"
+	ProvisionalHashPrefix    = "pending"
+)
+
+// kinds of store kept under PendingNamespace until their relations are committed,
+// only a file store can be picked up again
+const (
+	PendingFile   = "file"
+	PendingStream = "stream"
 )
 
 const (
@@ -58,6 +74,72 @@ const (
 	FileNameLen = 25
 )
 
+// SealVersion tags chonks sealed with a random nonce stored in front of the ciphertext,
+// chonks sealed before it have no header and use a nonce derived from the key
+const SealVersion byte = 1
+
+const (
+	ManifestFile    = "MANIFEST.json"
+	ManifestVersion = 1
+)
+
+// Argon2id parameters for new key slots, existing slots keep the ones they were written with
+const (
+	KeySlotFile    = "KEYSLOT.json"
+	KeySlotVersion = 1
+	KDFArgon2id    = "argon2id"
+	KDFTime        = 3
+	KDFMemory      = 64 * 1024
+	KDFThreads     = 4
+	SaltSize       = 16
+)
+
+const AcquiredAtDateLayout = "2006-01-02"
+
+// spaces of a partition that hold no file, they are indexed as synthetic files under these names
+const (
+	SpaceUnallocated = "unallocated"
+	SpaceSlack       = "slack"
+	SpaceVolumeSlack = "volume slack"
+	SlackName        = "slack of %s"
+)
+
+// carved files are named by their offset into what they were carved from, carving a whole
+// evidence file puts them in a partition spanning it
+const (
+	CarvedName            = "$Carved/%d.%s"
+	CarvedPartitionSuffix = "_carved"
+)
+
+// StdinPath as the store operand reads evidence from stdin, it is stored under DefaultStreamName unless named
+const (
+	StdinPath         = "-"
+	DefaultStreamName = "stdin"
+)
+
+const (
+	AuditOutcomeOK   = "ok"
+	AuditExportFile  = "audit_%d.json"
+	AuditRPCPrefix   = "rpc:"
+	AuditUnknownUser = "unknown"
+)
+
+const (
+	VerifyReportFile = "verify_report.json"
+
+	IssueIncompleteFile     = "incomplete_file"
+	IssueMissingObject      = "missing_object"
+	IssueMissingChonk       = "missing_chonk"
+	IssueMissingBlob        = "missing_blob"
+	IssueUnreadableChonk    = "unreadable_chonk"
+	IssueChonkHashMismatch  = "chonk_hash_mismatch"
+	IssueFileHashMismatch   = "file_hash_mismatch"
+	IssueDanglingContainer  = "dangling_container_reference"
+	IssueCorruptContainer   = "corrupt_container"
+	IssueDanglingBlockEntry = "dangling_block_entry"
+	IssueOrphanedRelations  = "orphaned_relations"
+)
+
 var (
 	ErrHashNotFound           = errors.New("must provide file hash")
 	ErrFileNotFound           = errors.New("must provide a file to save")
@@ -70,12 +152,50 @@ var (
 	ErrNilBatch               = errors.New("call SetBatch first, batch is nil. cannot work with nil batch")
 	ErrSmallQuery             = errors.New("search query too small. query requires at least 2 characters")
 	ErrTooManySplits          = errors.New("too many splits: %v")
+	ErrManifestVersion        = errors.New("database manifest version %d is newer than supported version %d, please upgrade DUES")
+	ErrNoManifest             = errors.New("database holds evidence but has no manifest, run again with the chunk size and modes it was created with and --%s to record them")
+	ErrLegacyChonkSize        = errors.New("stored relations were not cut into %dKB chunks, the database was created with a different chunk size or mode")
+	ErrVerifyFailed           = errors.New("verification failed")
+	ErrCorruptContainer       = errors.New("corrupt container")
+	ErrInvalidThreshold       = errors.New("threshold must be greater than 0 and at most 1")
+	ErrNotEvidenceFile        = errors.New("only evidence files can be deleted, their partitions and indexed files are deleted with them")
+	ErrWrongPassword          = errors.New("wrong password")
+	ErrUnknownKDF             = errors.New("unknown key derivation function %q in key slot")
+	ErrKeySlotVersion         = errors.New("key slot version %d is newer than supported version %d, please upgrade DUES")
+	ErrUnknownHashFormat      = errors.New("hash must be base64 or hex encoded")
+	ErrAcquiredAt             = errors.New("acquisition date must look like 2006-01-02 or 2006-01-02T15:04:05Z07:00")
+	ErrAuditChainBroken       = errors.New("audit chain is broken")
+	ErrStreamHashMismatch     = errors.New("streamed data hashes to %s, not to %s given in the file metadata, it was not kept")
+	ErrQuickModeRekey         = errors.New("quick mode databases are not encrypted, there is no password to rotate")
+	ErrEWFSignature           = errors.New("not an EWF segment, signature mismatch")
+	ErrEWFSegment             = errors.New("EWF segment number is %d, expected %d")
+	ErrEWFSection             = errors.New("corrupt EWF section descriptor")
+	ErrEWFVolume              = errors.New("corrupt EWF volume section")
+	ErrEWFTable               = errors.New("corrupt EWF chunk table")
+	ErrEWFChunk               = errors.New("unable to read EWF chunk %d: %v")
+	ErrEWFIncomplete          = errors.New("EWF image is incomplete, only %d chunks found, missing segment files?")
+	ErrEWFOffset              = errors.New("negative offset into EWF image")
+	ErrEWFHeader              = errors.New("corrupt EWF header section")
+	ErrMBRSignature           = errors.New("no valid MBR partition table")
+	ErrGPTHeader              = errors.New("invalid GPT header")
+	ErrGPTEntries             = errors.New("GPT partition entries do not match their CRC")
+	ErrEWFNotFirstSegment     = errors.New("%s is segment %d of an EWF image, store the .E01 segment instead")
+	ErrCarveIndexedFile       = errors.New("%s is an indexed file, carve its evidence file or partition instead")
 )
 
 const (
 	EXFAT = 0x07
 )
 
+// MBR partition type bytes DUES treats specially, every other type is stored as is
+const (
+	MBRTypeEmpty         = 0x00
+	MBRTypeExtended      = 0x05
+	MBRTypeExtendedLBA   = 0x0F
+	MBRTypeExtendedLinux = 0x85
+	MBRTypeProtective    = 0xEE
+)
+
 const (
 	CmdStore   = "store"
 	CmdList    = "list"
@@ -86,6 +206,13 @@ const (
 	SubCmdOut  = "out"
 	CmdSearch  = "search"
 	CmdServer  = "server"
+	CmdVerify  = "verify"
+	CmdDelete  = "delete"
+	CmdGC      = "gc"
+	CmdCompact = "compact"
+	CmdRekey   = "rekey"
+	CmdAudit   = "audit"
+	CmdCarve   = "carve"
 
 	FlagDBPath               = "dbpath"
 	FlagDBPathShort          = 'd'
@@ -107,10 +234,26 @@ const (
 	FlagContainerModeShort   = 'x'
 	FlagHierarchicalIndex    = "hierarchical"
 	FlagHierarchicalShort    = 'h'
+	FlagCDCMode              = "cdc"
+	FlagCDCModeShort         = 'r'
 	FlagSyncIndex            = "sync"
 	FlagSyncIndexShort       = 's'
 	FlagNoIndex              = "no-index"
 	FlagNoIndexShort         = 'n'
+	FlagDeleted              = "deleted"
+	FlagAdopt                = "adopt"
+	FlagThreshold            = "threshold"
+	FlagThresholdShort       = 't'
+	FlagCaseNumber           = "case"
+	FlagExhibitID            = "exhibit"
+	FlagExaminer             = "examiner"
+	FlagAcquiredAt           = "acquired"
+	FlagDeviceSerial         = "serial"
+	FlagNotes                = "notes"
+	FlagTag                  = "tag"
+	FlagNewPassword          = "new-password"
+	FlagNewPasswordShort     = 'w'
+	FlagStreamName           = "name"
 
 	OperandFile  = "FILE"
 	OperandHash  = "HASH"
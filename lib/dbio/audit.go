@@ -0,0 +1,119 @@
+package dbio
+
+import (
+	"crypto/sha3"
+	"fmt"
+	"indicer/lib/cnst"
+	"indicer/lib/structs"
+	"sync"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// serialises appends from concurrent server rpcs, badger would otherwise
+// reject all but one of the transactions that read the same chain head
+var auditLock sync.Mutex
+
+// AppendAuditEntry links entry to the last entry in the chain and stores it, entries
+// are only ever added so there is no way to update or delete one through DUES
+func AppendAuditEntry(entry structs.AuditEntry, db *badger.DB) error {
+	auditLock.Lock()
+	defer auditLock.Unlock()
+
+	return db.Update(func(txn *badger.Txn) error {
+		last, found, err := getLastAuditEntry(txn)
+		if err != nil {
+			return err
+		}
+
+		entry.Seq = 1
+		entry.PrevHash = nil
+		if found {
+			entry.Seq = last.Seq + 1
+			entry.PrevHash = last.Hash
+		}
+		entry.Hash, err = AuditEntryHash(entry)
+		if err != nil {
+			return err
+		}
+
+		data, err := msgpack.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if !cnst.QUICKOPT {
+			data = cnst.ENCODER.EncodeAll(data, make([]byte, 0, len(data)))
+		}
+		return txn.Set(getAuditKey(entry.Seq), data)
+	})
+}
+
+// AuditEntryHash is the SHA3-256 of the entry with its own hash left out
+func AuditEntryHash(entry structs.AuditEntry) ([]byte, error) {
+	entry.Hash = nil
+	data, err := msgpack.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha3.Sum256(data)
+	return hash[:], nil
+}
+
+// GetAuditEntries returns the whole chain in the order it was written
+func GetAuditEntries(db *badger.DB) ([]structs.AuditEntry, error) {
+	var entries []structs.AuditEntry
+
+	err := db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte(cnst.AuditNamespace)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			entry, err := decodeAuditEntry(it.Item())
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+func getLastAuditEntry(txn *badger.Txn) (structs.AuditEntry, bool, error) {
+	opts := badger.DefaultIteratorOptions
+	opts.Reverse = true
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	prefix := []byte(cnst.AuditNamespace)
+	it.Seek(append(prefix, 0xFF))
+	if !it.ValidForPrefix(prefix) {
+		return structs.AuditEntry{}, false, nil
+	}
+	entry, err := decodeAuditEntry(it.Item())
+	return entry, err == nil, err
+}
+
+func decodeAuditEntry(item *badger.Item) (structs.AuditEntry, error) {
+	var entry structs.AuditEntry
+
+	data, err := item.ValueCopy(nil)
+	if err != nil {
+		return entry, err
+	}
+	decoded, err := cnst.DECODER.DecodeAll(data, nil)
+	if err == nil {
+		data = decoded
+	}
+
+	err = msgpack.Unmarshal(data, &entry)
+	return entry, err
+}
+
+// zero padded so the keys sort in chain order
+func getAuditKey(seq uint64) []byte {
+	return []byte(fmt.Sprintf("%s%020d", cnst.AuditNamespace, seq))
+}
@@ -1,7 +1,6 @@
 package dbio
 
 import (
-	"encoding/base64"
 	"fmt"
 	"indicer/lib/cnst"
 	"indicer/lib/fio"
@@ -51,6 +50,13 @@ func SetFile[T structs.FileTypes](id []byte, filenode T, db *badger.DB) error {
 	}
 	return SetNode(id, data, db)
 }
+func SetBatchFile[T structs.FileTypes](id []byte, filenode T, batch *badger.WriteBatch) error {
+	data, err := msgpack.Marshal(filenode)
+	if err != nil {
+		return err
+	}
+	return SetBatchNode(id, data, batch)
+}
 func SetIndexedFile(id []byte, filenode structs.IndexedFile, batch *badger.WriteBatch) error {
 	data, err := msgpack.Marshal(filenode)
 	if err != nil {
@@ -127,8 +133,7 @@ func SetBatchChonkNode(key, data []byte, db *badger.DB, batch *badger.WriteBatch
 		}
 
 		// Regular container mode: store metadata in DB
-		metadata := []byte(fmt.Sprintf("%s|%d|%d", containerPath, offset, size))
-		return SetBatchNode(key, metadata, batch)
+		return SetBatchChonkLocation(key, containerPath, offset, size, batch)
 	}
 
 	// Original mode: one file per chunk
@@ -139,6 +144,12 @@ func SetBatchChonkNode(key, data []byte, db *badger.DB, batch *badger.WriteBatch
 	return SetBatchNode(key, cfpath, batch)
 }
 
+// SetBatchChonkLocation records where in a container a chonk is kept
+func SetBatchChonkLocation(key []byte, containerPath string, offset, size int64, batch *badger.WriteBatch) error {
+	metadata := []byte(fmt.Sprintf("%s|%d|%d", containerPath, offset, size))
+	return SetBatchNode(key, metadata, batch)
+}
+
 func SetBatchNode(key, data []byte, batch *badger.WriteBatch) error {
 	if !cnst.QUICKOPT {
 		data = cnst.ENCODER.EncodeAll(data, make([]byte, 0, len(data)))
@@ -159,27 +170,47 @@ func PingNode(key []byte, db *badger.DB) error {
 		return err
 	})
 }
-func GetChonkData(restoreIndex, start, size, dbstart, end int64, key []byte, db *badger.DB) ([]byte, error) {
+
+// ChonkExists checks the db first and then the hierarchical block index of blockMgr for chonk metadata,
+// blockMgr is nil when the hierarchical index is not used
+func ChonkExists(key []byte, blockMgr *fio.BlockManager, db *badger.DB) (bool, error) {
+	err := PingNode(key, db)
+	if err == nil {
+		return true, nil
+	}
+	if err != badger.ErrKeyNotFound {
+		return false, err
+	}
+
+	if blockMgr == nil {
+		return false, nil
+	}
+	return blockMgr.HasChunk(key)
+}
+func GetChonkData(restoreIndex, start, end int64, key []byte, db *badger.DB) ([]byte, error) {
 	data, err := GetChonkNode(key, db)
 	if err != nil {
 		return nil, err
 	}
+	return TrimChonkData(data, restoreIndex, start, end), nil
+}
 
-	if restoreIndex == dbstart {
-		actualStart := start - restoreIndex
-		data = data[actualStart:]
+// TrimChonkData cuts a chonk starting at restoreIndex down to the byte range [start, end)
+func TrimChonkData(data []byte, restoreIndex, start, end int64) []byte {
+	// chonks can be of any size in CDC mode, so trim using the actual chonk length
+	var actualStart int64
+	if start > restoreIndex {
+		actualStart = start - restoreIndex
 	}
-	if size < int64(len(data)) {
-		data = data[:size]
-	} else if (restoreIndex + cnst.ChonkSize) > end {
-		actualEnd := end - restoreIndex
-		data = data[:actualEnd]
+	actualEnd := int64(len(data))
+	if restoreIndex+actualEnd > end {
+		actualEnd = end - restoreIndex
 	}
 
-	return data, nil
+	return data[actualStart:actualEnd]
 }
-func GetChonkSize(restoreIndex, start, size, dbstart, end int64, key []byte, db *badger.DB) (int64, error) {
-	data, err := GetChonkData(restoreIndex, start, size, dbstart, end, key, db)
+func GetChonkSize(restoreIndex, start, end int64, key []byte, db *badger.DB) (int64, error) {
+	data, err := GetChonkData(restoreIndex, start, end, key, db)
 	if err != nil {
 		return -1, err
 	}
@@ -192,38 +223,54 @@ func GetChonkSize(restoreIndex, start, size, dbstart, end int64, key []byte, db
 	return int64(len(data)), nil
 }
 func GetChonkNode(key []byte, db *badger.DB) ([]byte, error) {
+	location, err := GetChonkLocation(key, db)
+	if err != nil {
+		return nil, err
+	}
+
+	if location.ContainerPath == "" {
+		return fio.ReadChonk([]byte(location.BlobPath), db.Opts().EncryptionKey)
+	}
+	return fio.ReadChunkFromContainer(location.ContainerPath, location.Offset, location.Size, db.Opts().EncryptionKey)
+}
+
+// GetChonkLocation resolves where a chonk is kept without reading its payload
+func GetChonkLocation(key []byte, db *badger.DB) (structs.ChonkLocation, error) {
+	var location structs.ChonkLocation
+
 	// Try to get from database first (backward compatibility or non-hierarchical mode)
 	metadata, err := GetNode(key, db)
 	if err == nil {
-		// Found in DB, parse and return
 		// Parse metadata: "path|offset|size"
 		parts := strings.Split(string(metadata), "|")
 		if len(parts) != 3 {
 			// Fallback to old format (direct file path) for backward compatibility
-			return fio.ReadChonk(metadata, db.Opts().EncryptionKey)
+			location.BlobPath = string(metadata)
+			return location, nil
 		}
 
-		containerPath := parts[0]
-		offset, err := strconv.ParseInt(parts[1], 10, 64)
+		location.ContainerPath = parts[0]
+		location.Offset, err = strconv.ParseInt(parts[1], 10, 64)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse offset: %w", err)
+			return location, fmt.Errorf("failed to parse offset: %w", err)
 		}
-		size, err := strconv.ParseInt(parts[2], 10, 64)
+		location.Size, err = strconv.ParseInt(parts[2], 10, 64)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse size: %w", err)
+			return location, fmt.Errorf("failed to parse size: %w", err)
 		}
-
-		return fio.ReadChunkFromContainer(containerPath, offset, size, db.Opts().EncryptionKey)
+		return location, nil
+	}
+	if err != badger.ErrKeyNotFound {
+		return location, err
 	}
 
 	// Not found in DB, try hierarchical block index
 	blockMgr := fio.NewBlockManager(db.Opts().Dir, nil)
-	containerPath, offset, size, err := blockMgr.GetChunkMetadata(key)
+	location.ContainerPath, location.Offset, location.Size, err = blockMgr.GetChunkMetadata(key)
 	if err != nil {
-		return nil, fmt.Errorf("chunk not found in DB or block index: %w", err)
+		return location, fmt.Errorf("chunk not found in DB or block index: %w", err)
 	}
-
-	return fio.ReadChunkFromContainer(containerPath, offset, size, db.Opts().EncryptionKey)
+	return location, nil
 }
 func GetNode(key []byte, db *badger.DB) ([]byte, error) {
 	var data []byte
@@ -245,21 +292,44 @@ func GetNode(key []byte, db *badger.DB) ([]byte, error) {
 		return nil, err
 	}
 
+	return DecodeNode(data), nil
+}
+
+// DecodeNode undoes the compression SetNode applies, data that was stored as is comes back unchanged
+func DecodeNode(data []byte) []byte {
 	decoded, err := cnst.DECODER.DecodeAll(data, nil)
-	if err == nil {
-		data = decoded
+	if err != nil {
+		return data
 	}
-	return data, nil
+	return decoded
 }
 
+// GuessFileType finds the object a hash belongs to, the hash can be the SHA3-256
+// hash DUES keys objects by or the MD5, SHA-1 or SHA-256 digest of the object
 func GuessFileType(encodedHash string, db *badger.DB) ([]byte, error) {
-	fhash, err := base64.StdEncoding.DecodeString(encodedHash)
+	fhash, err := util.DecodeHash(encodedHash)
 	if err != nil {
 		return nil, err
 	}
 
+	fid, err := guessFileType(fhash, db)
+	if err != badger.ErrKeyNotFound {
+		return fid, err
+	}
+
+	sha3Hash, derr := ResolveDigest(fhash, db)
+	if derr == badger.ErrKeyNotFound {
+		return fid, err
+	}
+	if derr != nil {
+		return nil, derr
+	}
+	return guessFileType(sha3Hash, db)
+}
+
+func guessFileType(fhash []byte, db *badger.DB) ([]byte, error) {
 	fid := util.AppendToBytesSlice(cnst.IdxFileNamespace, fhash)
-	err = PingNode(fid, db)
+	err := PingNode(fid, db)
 	if err != nil && err != badger.ErrKeyNotFound {
 		return nil, err
 	}
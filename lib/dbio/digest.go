@@ -0,0 +1,53 @@
+package dbio
+
+import (
+	"indicer/lib/cnst"
+	"indicer/lib/digest"
+	"indicer/lib/util"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// The digest index maps MD5, SHA-1 and SHA-256 digests to the SHA3-256 hash
+// objects are keyed by. Digest lengths differ so one namespace holds all three.
+
+func SetBatchDigestIndex(digests digest.Digests, fhash []byte, batch *badger.WriteBatch) error {
+	for _, sum := range digests.All() {
+		err := SetBatchNode(getDigestKey(sum), fhash, batch)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func SetDigestIndex(digests digest.Digests, fhash []byte, db *badger.DB) error {
+	batch, err := util.InitBatch(db)
+	if err != nil {
+		return err
+	}
+	err = SetBatchDigestIndex(digests, fhash, batch)
+	if err != nil {
+		batch.Cancel()
+		return err
+	}
+	return batch.Flush()
+}
+
+func DeleteBatchDigestIndex(digests digest.Digests, batch *badger.WriteBatch) error {
+	for _, sum := range digests.All() {
+		err := batch.Delete(getDigestKey(sum))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResolveDigest returns the SHA3-256 hash of the object with the given MD5, SHA-1 or SHA-256 digest
+func ResolveDigest(sum []byte, db *badger.DB) ([]byte, error) {
+	return GetNode(getDigestKey(sum), db)
+}
+
+func getDigestKey(sum []byte) []byte {
+	return util.AppendToBytesSlice(cnst.DigestNamespace, sum)
+}
@@ -0,0 +1,173 @@
+package dbio
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"indicer/lib/cnst"
+	"indicer/lib/structs"
+	"indicer/lib/util"
+	"os"
+	"path/filepath"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// The key slot lives next to the manifest, badger needs the master key to open
+// its own key registry so the slot cannot be stored inside badger
+
+func LoadKeySlot(dbpath string) (structs.KeySlot, error) {
+	var slot structs.KeySlot
+
+	data, err := os.ReadFile(filepath.Join(dbpath, cnst.KeySlotFile))
+	if err != nil {
+		return slot, err
+	}
+	err = json.Unmarshal(data, &slot)
+	return slot, err
+}
+
+func WriteKeySlot(dbpath string, slot structs.KeySlot) error {
+	data, err := json.MarshalIndent(slot, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	spath := filepath.Join(dbpath, cnst.KeySlotFile)
+	tempPath := spath + ".tmp"
+	err = os.WriteFile(tempPath, data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tempPath, spath)
+}
+
+// NewKeySlot seals masterKey with a key derived from password and a fresh salt
+func NewKeySlot(password, masterKey []byte) (structs.KeySlot, error) {
+	var slot structs.KeySlot
+	slot.Version = cnst.KeySlotVersion
+	slot.KDF = cnst.KDFArgon2id
+	slot.Time = cnst.KDFTime
+	slot.Memory = cnst.KDFMemory
+	slot.Threads = cnst.KDFThreads
+	slot.Salt = make([]byte, cnst.SaltSize)
+	if _, err := rand.Read(slot.Salt); err != nil {
+		return slot, err
+	}
+
+	var err error
+	kek := util.DeriveKey(password, slot.Salt, slot.Time, slot.Memory, slot.Threads)
+	slot.SealedKey, err = util.SealAES(kek, masterKey)
+	return slot, err
+}
+
+// OpenKeySlot returns the master key sealed in slot, the GCM tag on the sealed key
+// doubles as the key check so a wrong password is caught before badger is opened
+func OpenKeySlot(slot structs.KeySlot, password []byte) ([]byte, error) {
+	if slot.Version > cnst.KeySlotVersion {
+		return nil, fmt.Errorf(cnst.ErrKeySlotVersion.Error(), slot.Version, cnst.KeySlotVersion)
+	}
+	if slot.KDF != cnst.KDFArgon2id {
+		return nil, fmt.Errorf(cnst.ErrUnknownKDF.Error(), slot.KDF)
+	}
+
+	kek := util.DeriveKey(password, slot.Salt, slot.Time, slot.Memory, slot.Threads)
+	masterKey, err := util.UnsealAES(kek, slot.SealedKey)
+	if err != nil {
+		return nil, cnst.ErrWrongPassword
+	}
+	return masterKey, nil
+}
+
+// UnlockDB opens the database at dbpath with the master key unlocked by password.
+// A new database gets a random master key, a database created before key slots
+// keeps the unsalted password hash as its master key and gets a slot for it.
+func UnlockDB(dbpath string, password []byte) (*badger.DB, error) {
+	if cnst.QUICKOPT {
+		return ConnectDB(dbpath, nil)
+	}
+
+	slot, err := LoadKeySlot(dbpath)
+	if err == nil {
+		masterKey, err := OpenKeySlot(slot, password)
+		if err != nil {
+			return nil, err
+		}
+		return ConnectDB(dbpath, masterKey)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	_, err = os.Stat(filepath.Join(dbpath, badger.KeyRegistryFileName))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		return upgradeLegacyDB(dbpath, password)
+	}
+
+	masterKey := make([]byte, cnst.KeySize)
+	if _, err = rand.Read(masterKey); err != nil {
+		return nil, err
+	}
+	slot, err = NewKeySlot(password, masterKey)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(dbpath, 0700)
+	if err != nil {
+		return nil, err
+	}
+	err = WriteKeySlot(dbpath, slot)
+	if err != nil {
+		return nil, err
+	}
+	return ConnectDB(dbpath, masterKey)
+}
+
+func upgradeLegacyDB(dbpath string, password []byte) (*badger.DB, error) {
+	masterKey := util.HashPassword(password)
+	db, err := ConnectDB(dbpath, masterKey)
+	if errors.Is(err, badger.ErrEncryptionKeyMismatch) {
+		return nil, cnst.ErrWrongPassword
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	slot, err := NewKeySlot(password, masterKey)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	err = WriteKeySlot(dbpath, slot)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Rekey reseals the master key under newPassword, nothing stored with the key changes
+func Rekey(dbpath string, oldPassword, newPassword []byte) error {
+	if cnst.QUICKOPT {
+		return cnst.ErrQuickModeRekey
+	}
+
+	slot, err := LoadKeySlot(dbpath)
+	if err != nil {
+		return err
+	}
+	masterKey, err := OpenKeySlot(slot, oldPassword)
+	if err != nil {
+		return err
+	}
+
+	slot, err = NewKeySlot(newPassword, masterKey)
+	if err != nil {
+		return err
+	}
+	return WriteKeySlot(dbpath, slot)
+}
@@ -0,0 +1,153 @@
+package dbio
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"indicer/lib/cnst"
+	"indicer/lib/structs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// The manifest lives next to BLOBS/ instead of inside badger, it has to be
+// readable before the database is opened because quick mode changes how
+// badger itself is opened
+
+func LoadManifest(dbpath string) (structs.Manifest, error) {
+	var manifest structs.Manifest
+
+	data, err := os.ReadFile(filepath.Join(dbpath, cnst.ManifestFile))
+	if err != nil {
+		return manifest, err
+	}
+	err = json.Unmarshal(data, &manifest)
+	return manifest, err
+}
+
+func WriteManifest(dbpath string, manifest structs.Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	mpath := filepath.Join(dbpath, cnst.ManifestFile)
+	tempPath := mpath + ".tmp"
+	err = os.WriteFile(tempPath, data, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tempPath, mpath)
+}
+
+// AdoptManifest records the settings of a database that holds evidence but no manifest.
+// Such a database was written by a release without manifests, its settings are only
+// recorded once the user vouches for them with cnst.ADOPTOPT. Databases without
+// evidence are left for the first store to record
+func AdoptManifest(db *badger.DB) error {
+	dbpath := db.Opts().Dir
+	_, err := LoadManifest(dbpath)
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	found, ehash, size, err := firstEvidenceFile(db)
+	if err != nil || !found {
+		return err
+	}
+	if !cnst.ADOPTOPT {
+		return fmt.Errorf(cnst.ErrNoManifest.Error(), cnst.FlagAdopt)
+	}
+	if ehash != nil {
+		err = checkLegacyChonkSize(ehash, size, db)
+		if err != nil {
+			return err
+		}
+	}
+	return WriteManifest(dbpath, currentManifest())
+}
+
+// EnsureManifest records the current storage settings if the database has no manifest yet
+func EnsureManifest(db *badger.DB) error {
+	err := AdoptManifest(db)
+	if err != nil {
+		return err
+	}
+	dbpath := db.Opts().Dir
+	_, err = LoadManifest(dbpath)
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return WriteManifest(dbpath, currentManifest())
+}
+
+func currentManifest() structs.Manifest {
+	var manifest structs.Manifest
+	manifest.Version = cnst.ManifestVersion
+	manifest.ToolVersion = cnst.ToolVersion
+	manifest.CreatedAt = time.Now().UTC()
+	manifest.ChonkSize = cnst.ChonkSize
+	manifest.ContainerMode = cnst.CONTAINERMODE
+	manifest.HierarchicalIndex = cnst.HIERARCHICALINDEX
+	manifest.QuickMode = cnst.QUICKOPT
+	manifest.CDCMode = cnst.CDCMODE
+	return manifest
+}
+
+// firstEvidenceFile tells whether the database holds any evidence file, and returns the
+// hash and size of a completed one, nil if none has completed
+func firstEvidenceFile(db *badger.DB) (bool, []byte, int64, error) {
+	var ids [][]byte
+	err := db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte(cnst.EviFileNamespace)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			ids = append(ids, it.Item().KeyCopy(nil))
+		}
+		return nil
+	})
+	if err != nil {
+		return false, nil, 0, err
+	}
+
+	for _, id := range ids {
+		efile, err := GetEvidenceFile(id, db)
+		if err != nil {
+			return false, nil, 0, err
+		}
+		if efile.Completed {
+			return true, bytes.TrimPrefix(id, []byte(cnst.EviFileNamespace)), efile.Size, nil
+		}
+	}
+	return len(ids) > 0, nil, 0, nil
+}
+
+// checkLegacyChonkSize makes sure the relations of evidence file ehash were cut with the
+// chonk size given on the command line, fixed size relations start at every multiple of it
+func checkLegacyChonkSize(ehash []byte, size int64, db *badger.DB) error {
+	if cnst.CDCMODE {
+		return nil
+	}
+	indices, err := getAllRelationIndices(ehash, db)
+	if err != nil {
+		return err
+	}
+	want := (size + cnst.ChonkSize - 1) / cnst.ChonkSize
+	if int64(len(indices)) != want {
+		return fmt.Errorf(cnst.ErrLegacyChonkSize.Error(), cnst.ChonkSize/cnst.KB)
+	}
+	for i, index := range indices {
+		if index != int64(i)*cnst.ChonkSize {
+			return fmt.Errorf(cnst.ErrLegacyChonkSize.Error(), cnst.ChonkSize/cnst.KB)
+		}
+	}
+	return nil
+}
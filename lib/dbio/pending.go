@@ -0,0 +1,47 @@
+package dbio
+
+import (
+	"indicer/lib/cnst"
+	"indicer/lib/util"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// A pending marker is kept for every provisional hash relations are written under,
+// from the start of an ingest until they are committed. Relations whose owner has
+// neither an evidence file nor a marker of a store that can be resumed are orphans.
+
+// SetPendingStore marks provisional as the hash of a store in progress, kind is cnst.PendingFile or cnst.PendingStream
+func SetPendingStore(provisional []byte, kind string, db *badger.DB) error {
+	return SetNode(getPendingKey(provisional), []byte(kind), db)
+}
+
+func DeletePendingStore(provisional []byte, db *badger.DB) error {
+	return db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(getPendingKey(provisional))
+	})
+}
+
+// GetPendingStores returns the kind of every store that was never committed, keyed by provisional hash
+func GetPendingStores(db *badger.DB) (map[string]string, error) {
+	pending := map[string]string{}
+	err := db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte(cnst.PendingNamespace)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			data, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			pending[string(it.Item().Key()[len(prefix):])] = string(DecodeNode(data))
+		}
+		return nil
+	})
+	return pending, err
+}
+
+func getPendingKey(provisional []byte) []byte {
+	return util.AppendToBytesSlice(cnst.PendingNamespace, provisional)
+}
@@ -0,0 +1,104 @@
+package dbio
+
+import (
+	"indicer/lib/cnst"
+	"indicer/lib/util"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// CDC relation indices are not multiples of cnst.ChonkSize, so the boundaries of an
+// evidence file have to be read back from its relation keys. Keys are ordered as
+// strings rather than numbers, which means the whole prefix is read and sorted once
+// and kept around for the lifetime of the process.
+var (
+	relIndexMu    sync.Mutex
+	relIndexCache = map[string][]int64{}
+)
+
+// GetRelationIndices returns the ordered start offsets of all the chonks of the
+// evidence file ehash that overlap the byte range [start, end)
+func GetRelationIndices(ehash []byte, start, end int64, db *badger.DB) ([]int64, error) {
+	if !cnst.CDCMODE {
+		var dbstart int64
+		if start > 0 {
+			dbstart = util.GetDBStartOffset(start)
+		}
+		var indices []int64
+		for index := dbstart; index < end; index += cnst.ChonkSize {
+			indices = append(indices, index)
+		}
+		return indices, nil
+	}
+
+	all, err := getAllRelationIndices(ehash, db)
+	if err != nil {
+		return nil, err
+	}
+
+	// first boundary after start, the chonk holding start begins one before it
+	first, _ := slices.BinarySearchFunc(all, start, func(index, target int64) int {
+		if index <= target {
+			return -1
+		}
+		return 1
+	})
+	if first > 0 {
+		first--
+	}
+
+	var indices []int64
+	for _, index := range all[first:] {
+		if index >= end {
+			break
+		}
+		indices = append(indices, index)
+	}
+	return indices, nil
+}
+
+// ForgetRelationIndices drops cached boundaries, must be called whenever
+// relation keys of ehash are added or removed
+func ForgetRelationIndices(ehash []byte) {
+	relIndexMu.Lock()
+	delete(relIndexCache, string(ehash))
+	relIndexMu.Unlock()
+}
+
+func getAllRelationIndices(ehash []byte, db *badger.DB) ([]int64, error) {
+	relIndexMu.Lock()
+	defer relIndexMu.Unlock()
+
+	if indices, ok := relIndexCache[string(ehash)]; ok {
+		return indices, nil
+	}
+
+	prefix := util.AppendToBytesSlice(cnst.RelationNamespace, ehash, cnst.DataSeperator)
+	var indices []int64
+	err := db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			key := it.Item().Key()
+			index, err := strconv.ParseInt(string(key[len(prefix):]), 10, 64)
+			if err != nil {
+				return err
+			}
+			indices = append(indices, index)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.Sort(indices)
+	relIndexCache[string(ehash)] = indices
+	return indices, nil
+}
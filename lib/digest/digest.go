@@ -0,0 +1,101 @@
+package digest
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"hash"
+	"io"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// Digests are the hashes acquisition tools and court documents refer to,
+// DUES itself keys everything by SHA3-256
+type Digests struct {
+	MD5    []byte `msgpack:"md5"`
+	SHA1   []byte `msgpack:"sha1"`
+	SHA256 []byte `msgpack:"sha256"`
+}
+
+func (d Digests) IsZero() bool {
+	return len(d.MD5) == 0 && len(d.SHA1) == 0 && len(d.SHA256) == 0
+}
+
+// All returns the digests that are set, in MD5, SHA-1, SHA-256 order
+func (d Digests) All() [][]byte {
+	var all [][]byte
+	for _, sum := range [][]byte{d.MD5, d.SHA1, d.SHA256} {
+		if len(sum) > 0 {
+			all = append(all, sum)
+		}
+	}
+	return all
+}
+
+// Mismatches names the digests set in d that computed disagrees with
+func (d Digests) Mismatches(computed Digests) []string {
+	var names []string
+	for _, pair := range []struct {
+		name               string
+		recorded, computed []byte
+	}{{"MD5", d.MD5, computed.MD5}, {"SHA-1", d.SHA1, computed.SHA1}, {"SHA-256", d.SHA256, computed.SHA256}} {
+		if len(pair.recorded) > 0 && !bytes.Equal(pair.recorded, pair.computed) {
+			names = append(names, pair.name)
+		}
+	}
+	return names
+}
+
+// Hasher feeds every write to SHA3-256, MD5, SHA-1 and SHA-256 at once. It is a
+// hash.Hash whose Sum is the SHA3-256 file ID, the others are read with Digests.
+type Hasher struct {
+	id     hash.Hash
+	md5    hash.Hash
+	sha1   hash.Hash
+	sha256 hash.Hash
+	writer io.Writer
+}
+
+func New() *Hasher {
+	h := &Hasher{
+		id:     sha3.New256(),
+		md5:    md5.New(),
+		sha1:   sha1.New(),
+		sha256: sha256.New(),
+	}
+	h.writer = io.MultiWriter(h.id, h.md5, h.sha1, h.sha256)
+	return h
+}
+
+func (h *Hasher) Write(p []byte) (int, error) {
+	return h.writer.Write(p)
+}
+
+func (h *Hasher) Sum(b []byte) []byte {
+	return h.id.Sum(b)
+}
+
+func (h *Hasher) Reset() {
+	h.id.Reset()
+	h.md5.Reset()
+	h.sha1.Reset()
+	h.sha256.Reset()
+}
+
+func (h *Hasher) Size() int {
+	return h.id.Size()
+}
+
+func (h *Hasher) BlockSize() int {
+	return h.id.BlockSize()
+}
+
+func (h *Hasher) Digests() Digests {
+	return Digests{
+		MD5:    h.md5.Sum(nil),
+		SHA1:   h.sha1.Sum(nil),
+		SHA256: h.sha256.Sum(nil),
+	}
+}
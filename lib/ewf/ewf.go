@@ -0,0 +1,441 @@
+package ewf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"indicer/lib/cnst"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Signature opens every segment of an EWF-E01 image
+var Signature = []byte{'E', 'V', 'F', 0x09, 0x0d, 0x0a, 0xff, 0x00}
+
+const (
+	fileHeaderSize        = 13
+	sectionDescriptorSize = 76
+	tableHeaderSize       = 24
+	chunkCacheSize        = 64
+	maxChunkSize          = 64 * cnst.MB
+	maxHeaderSize         = cnst.MB
+)
+
+// Segment is one segment file of an image, Size is its length in bytes
+type Segment interface {
+	io.ReaderAt
+	Size() int64
+}
+
+// fileSegment is a segment file opened from disk
+type fileSegment struct {
+	*os.File
+	size int64
+}
+
+func (f fileSegment) Size() int64 {
+	return f.size
+}
+
+// Image is the logical media of a segmented EWF (E01) image, read through its chunk tables
+type Image struct {
+	segments  []Segment
+	chunks    []chunk
+	chunkSize int64
+	size      int64
+	header    map[string]string
+	md5       []byte
+	sha1      []byte
+
+	mu    sync.Mutex
+	cache map[int64][]byte
+}
+
+type chunk struct {
+	segment    int
+	offset     int64
+	size       int64
+	compressed bool
+}
+
+// SegmentNumber returns the number of the EWF-E01 segment at fpath, 0 when it is not one
+func SegmentNumber(fpath string) int {
+	fhandle, err := os.Open(fpath)
+	if err != nil {
+		return 0
+	}
+	defer fhandle.Close()
+
+	fheader := make([]byte, fileHeaderSize)
+	_, err = io.ReadFull(fhandle, fheader)
+	if err != nil || !bytes.Equal(fheader[:len(Signature)], Signature) {
+		return 0
+	}
+	return int(binary.LittleEndian.Uint16(fheader[9:11]))
+}
+
+// Open opens the image whose first segment is fpath, the other segments
+// are found next to it by their extension (.E02 ... .E99, .EAA ...)
+func Open(fpath string) (*Image, error) {
+	img := &Image{cache: make(map[int64][]byte)}
+
+	for number := 1; ; number++ {
+		spath := fpath
+		if number > 1 {
+			spath = segmentPath(fpath, number)
+		}
+		fhandle, err := os.Open(spath)
+		if os.IsNotExist(err) && number > 1 {
+			break
+		}
+		if err != nil {
+			img.Close()
+			return nil, err
+		}
+		info, err := fhandle.Stat()
+		if err != nil {
+			fhandle.Close()
+			img.Close()
+			return nil, err
+		}
+		img.segments = append(img.segments, fileSegment{File: fhandle, size: info.Size()})
+
+		done, err := img.parseSegment(len(img.segments)-1, number)
+		if err != nil {
+			img.Close()
+			return nil, fmt.Errorf("%s: %w", spath, err)
+		}
+		if done {
+			break
+		}
+	}
+
+	err := img.checkChunks()
+	if err != nil {
+		img.Close()
+		return nil, err
+	}
+	return img, nil
+}
+
+// OpenSegments opens the image held by segments, given in segment order
+func OpenSegments(segments ...Segment) (*Image, error) {
+	img := &Image{cache: make(map[int64][]byte)}
+	for index, segment := range segments {
+		img.segments = append(img.segments, segment)
+		done, err := img.parseSegment(index, index+1)
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			break
+		}
+	}
+	err := img.checkChunks()
+	if err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// checkChunks makes sure the chunk tables cover the whole media
+func (i *Image) checkChunks() error {
+	if i.chunkSize == 0 || int64(len(i.chunks))*i.chunkSize < i.size {
+		return fmt.Errorf(cnst.ErrEWFIncomplete.Error(), len(i.chunks))
+	}
+	return nil
+}
+
+// Size is the size of the logical media
+func (i *Image) Size() int64 {
+	return i.size
+}
+
+// Header returns the case metadata recorded by the acquisition tool, keyed by EWF header identifier
+func (i *Image) Header() map[string]string {
+	return i.header
+}
+
+func (i *Image) Close() error {
+	var err error
+	for _, segment := range i.segments {
+		closer, ok := segment.(io.Closer)
+		if !ok {
+			continue
+		}
+		if closeErr := closer.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func (i *Image) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, cnst.ErrEWFOffset
+	}
+	if off >= i.size {
+		return 0, io.EOF
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	var n int
+	for n < len(p) && off < i.size {
+		index := off / i.chunkSize
+		data, err := i.readChunk(index)
+		if err != nil {
+			return n, err
+		}
+
+		copied := copy(p[n:], data[off-index*i.chunkSize:])
+		n += copied
+		off += int64(copied)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (i *Image) readChunk(index int64) ([]byte, error) {
+	if data, ok := i.cache[index]; ok {
+		return data, nil
+	}
+
+	c := i.chunks[index]
+	if c.size > maxStoredSize(i.chunkSize) {
+		return nil, cnst.ErrEWFTable
+	}
+	raw := make([]byte, c.size)
+	_, err := i.segments[c.segment].ReadAt(raw, c.offset)
+	if err != nil {
+		return nil, fmt.Errorf(cnst.ErrEWFChunk.Error(), index, err)
+	}
+
+	var data []byte
+	if c.compressed {
+		data, err = inflate(raw, i.chunkSize)
+		if err != nil {
+			return nil, fmt.Errorf(cnst.ErrEWFChunk.Error(), index, err)
+		}
+	} else {
+		// uncompressed chunks carry a trailing adler32 checksum
+		data = raw[:max(len(raw)-4, 0)]
+	}
+
+	want := min(i.chunkSize, i.size-index*i.chunkSize)
+	if int64(len(data)) < want {
+		return nil, fmt.Errorf(cnst.ErrEWFChunk.Error(), index, io.ErrUnexpectedEOF)
+	}
+	data = data[:want]
+
+	if len(i.cache) >= chunkCacheSize {
+		clear(i.cache)
+	}
+	i.cache[index] = data
+	return data, nil
+}
+
+// parseSegment walks the section descriptors of a segment, done is set once the last segment is parsed
+func (i *Image) parseSegment(segment, number int) (bool, error) {
+	fhandle := i.segments[segment]
+
+	fheader := make([]byte, fileHeaderSize)
+	_, err := fhandle.ReadAt(fheader, 0)
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(fheader[:len(Signature)], Signature) {
+		return false, cnst.ErrEWFSignature
+	}
+	if got := int(binary.LittleEndian.Uint16(fheader[9:11])); got != number {
+		return false, fmt.Errorf(cnst.ErrEWFSegment.Error(), got, number)
+	}
+
+	var sectorsEnd int64
+	descriptor := make([]byte, sectionDescriptorSize)
+	for offset := int64(fileHeaderSize); ; {
+		_, err = fhandle.ReadAt(descriptor, offset)
+		if err != nil {
+			return false, err
+		}
+		stype := strings.TrimRight(string(descriptor[:16]), "\x00")
+		next := int64(binary.LittleEndian.Uint64(descriptor[16:24]))
+		size := int64(binary.LittleEndian.Uint64(descriptor[24:32]))
+		dataOffset := offset + sectionDescriptorSize
+		dataSize := size - sectionDescriptorSize
+
+		switch stype {
+		case "header2", "header":
+			if i.header != nil && stype == "header" {
+				break
+			}
+			data, err := readSection(fhandle, dataOffset, dataSize)
+			if err != nil {
+				return false, err
+			}
+			header, err := parseHeader(data)
+			if err != nil {
+				return false, err
+			}
+			i.header = header
+		case "volume", "disk", "data":
+			data, err := readSection(fhandle, dataOffset, dataSize)
+			if err != nil {
+				return false, err
+			}
+			err = i.parseVolume(data)
+			if err != nil {
+				return false, err
+			}
+		case "sectors":
+			sectorsEnd = offset + size
+		case "table":
+			data, err := readSection(fhandle, dataOffset, dataSize)
+			if err != nil {
+				return false, err
+			}
+			err = i.parseTable(segment, data, sectorsEnd, offset+size)
+			if err != nil {
+				return false, err
+			}
+		case "hash":
+			data, err := readSection(fhandle, dataOffset, dataSize)
+			if err != nil {
+				return false, err
+			}
+			if len(data) >= 16 && i.md5 == nil {
+				i.md5 = data[:16]
+			}
+		case "digest":
+			data, err := readSection(fhandle, dataOffset, dataSize)
+			if err != nil {
+				return false, err
+			}
+			if len(data) >= 36 {
+				i.md5 = data[:16]
+				i.sha1 = data[16:36]
+			}
+		case "next":
+			return false, nil
+		case "done":
+			return true, nil
+		}
+
+		if next <= offset {
+			return true, nil
+		}
+		offset = next
+	}
+}
+
+func (i *Image) parseVolume(data []byte) error {
+	if len(data) < 24 {
+		return cnst.ErrEWFVolume
+	}
+	sectorsPerChunk := int64(binary.LittleEndian.Uint32(data[8:12]))
+	bytesPerSector := int64(binary.LittleEndian.Uint32(data[12:16]))
+	sectors := int64(binary.LittleEndian.Uint64(data[16:24]))
+	if sectorsPerChunk == 0 || bytesPerSector == 0 || sectorsPerChunk > maxChunkSize/bytesPerSector {
+		return cnst.ErrEWFVolume
+	}
+	// a media size that overflows is no size at all
+	if sectors > math.MaxInt64/bytesPerSector {
+		return cnst.ErrEWFVolume
+	}
+
+	i.chunkSize = sectorsPerChunk * bytesPerSector
+	i.size = sectors * bytesPerSector
+	return nil
+}
+
+// parseTable adds the chunks of a table section. A chunk runs up to the next one,
+// the last one up to the end of the sectors section, or of the table itself in
+// images written before sectors sections existed
+func (i *Image) parseTable(segment int, data []byte, sectorsEnd, tableEnd int64) error {
+	if len(data) < tableHeaderSize {
+		return cnst.ErrEWFTable
+	}
+	count := int(binary.LittleEndian.Uint32(data[:4]))
+	base := int64(binary.LittleEndian.Uint64(data[8:16]))
+	if len(data) < tableHeaderSize+count*4 {
+		return cnst.ErrEWFTable
+	}
+
+	first := len(i.chunks)
+	for index := range count {
+		entry := binary.LittleEndian.Uint32(data[tableHeaderSize+index*4:])
+		i.chunks = append(i.chunks, chunk{
+			segment:    segment,
+			offset:     base + int64(entry&0x7fffffff),
+			compressed: entry&0x80000000 != 0,
+		})
+	}
+
+	for index := first; index < len(i.chunks); index++ {
+		end := tableEnd
+		if index+1 < len(i.chunks) {
+			end = i.chunks[index+1].offset
+		} else if sectorsEnd > i.chunks[index].offset {
+			end = sectorsEnd
+		}
+		if end <= i.chunks[index].offset {
+			return cnst.ErrEWFTable
+		}
+		i.chunks[index].size = end - i.chunks[index].offset
+	}
+	return nil
+}
+
+// readSection reads the data of a section, which has to lie within its segment
+func readSection(segment Segment, offset, size int64) ([]byte, error) {
+	if size < 0 || offset < 0 || size > segment.Size()-offset {
+		return nil, cnst.ErrEWFSection
+	}
+	data := make([]byte, size)
+	_, err := segment.ReadAt(data, offset)
+	return data, err
+}
+
+// maxStoredSize is the most a chunk of chunkSize bytes takes up in a segment: deflate falls
+// back to stored blocks of up to 64K with a 5 byte header each, zlib adds a 2 byte header,
+// and both zlib and uncompressed chunks end in a 4 byte adler32
+func maxStoredSize(chunkSize int64) int64 {
+	return chunkSize + (chunkSize/0xFFFF+1)*5 + 2 + 4
+}
+
+// inflate decompresses zlib data, reading no more than limit bytes of it
+func inflate(data []byte, limit int64) ([]byte, error) {
+	reader, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(io.LimitReader(reader, limit))
+}
+
+// segmentPath names segment number of the image fpath belongs to,
+// keeping the case of the first segment's extension
+func segmentPath(fpath string, number int) string {
+	ext := filepath.Ext(fpath)
+	base := strings.TrimSuffix(fpath, ext)
+
+	var next string
+	if number < 100 {
+		next = fmt.Sprintf("E%02d", number)
+	} else {
+		k := number - 100
+		next = string([]byte{byte('E' + k/(26*26)), byte('A' + k/26%26), byte('A' + k%26)})
+	}
+	if ext != strings.ToUpper(ext) {
+		next = strings.ToLower(next)
+	}
+	return base + "." + next
+}
@@ -0,0 +1,254 @@
+package ewf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"hash/adler32"
+	"indicer/lib/cnst"
+	"io"
+	"strings"
+	"testing"
+)
+
+const (
+	testSectorsPerChunk = 8
+	testBytesPerSector  = 512
+	testChunkSize       = testSectorsPerChunk * testBytesPerSector
+)
+
+// testSection is a section of a test segment, size overrides the size written to its descriptor
+type testSection struct {
+	stype string
+	data  []byte
+	size  int64
+}
+
+// testMedia is 2.5 chunks of media that does not compress too well
+func testMedia() []byte {
+	media := make([]byte, testChunkSize*5/2)
+	for index := range media {
+		media[index] = byte(index * 7 / 3)
+	}
+	return media
+}
+
+// testSegments splits media over count segments, even chunks are compressed and odd
+// ones stored with their adler32. edit can change the sections of a segment before it is laid out
+func testSegments(media []byte, count int, edit func(segment int, sections []testSection) []testSection) [][]byte {
+	var chunks [][]byte
+	for offset := 0; offset < len(media); offset += testChunkSize {
+		chunks = append(chunks, media[offset:min(offset+testChunkSize, len(media))])
+	}
+	perSegment := (len(chunks) + count - 1) / count
+
+	volume := make([]byte, 94)
+	binary.LittleEndian.PutUint32(volume[4:], uint32(len(chunks)))
+	binary.LittleEndian.PutUint32(volume[8:], testSectorsPerChunk)
+	binary.LittleEndian.PutUint32(volume[12:], testBytesPerSector)
+	binary.LittleEndian.PutUint64(volume[16:], uint64((len(media)+testBytesPerSector-1)/testBytesPerSector))
+
+	var segments [][]byte
+	for segment := range count {
+		var sections []testSection
+		if segment == 0 {
+			sections = append(sections, testSection{stype: "header", data: testCompress([]byte("1\nmain\nc\tn\te\nCASE-1\tEX-7\tJ. Doe\n\n"))})
+			sections = append(sections, testSection{stype: "volume", data: volume})
+		} else {
+			sections = append(sections, testSection{stype: "data", data: volume})
+		}
+
+		var body, entries []byte
+		for index, data := range chunks[segment*perSegment : min((segment+1)*perSegment, len(chunks))] {
+			entry := uint32(len(body))
+			if index%2 == 0 {
+				entry |= 0x80000000
+				body = append(body, testCompress(data)...)
+			} else {
+				body = append(body, data...)
+				body = binary.LittleEndian.AppendUint32(body, adler32.Checksum(data))
+			}
+			entries = binary.LittleEndian.AppendUint32(entries, entry)
+		}
+		sections = append(sections, testSection{stype: "sectors", data: body})
+		// the table base is filled in once the sectors section has its offset
+		table := make([]byte, tableHeaderSize)
+		binary.LittleEndian.PutUint32(table, uint32(len(entries)/4))
+		sections = append(sections, testSection{stype: "table", data: append(table, entries...)})
+
+		if segment == count-1 {
+			sum := md5.Sum(media)
+			sections = append(sections, testSection{stype: "hash", data: append(sum[:], make([]byte, 20)...)})
+			sections = append(sections, testSection{stype: "done"})
+		} else {
+			sections = append(sections, testSection{stype: "next"})
+		}
+		if edit != nil {
+			sections = edit(segment, sections)
+		}
+		segments = append(segments, testLayout(segment+1, sections))
+	}
+	return segments
+}
+
+// testLayout writes the file header and sections of segment number, the last section points at itself
+func testLayout(number int, sections []testSection) []byte {
+	segment := append([]byte{}, Signature...)
+	segment = append(segment, 1)
+	segment = binary.LittleEndian.AppendUint16(segment, uint16(number))
+	segment = append(segment, 0, 0)
+
+	var sectorsData int64
+	for index, section := range sections {
+		offset := int64(len(segment))
+		size := section.size
+		if size == 0 {
+			size = int64(sectionDescriptorSize + len(section.data))
+		}
+		next := offset + sectionDescriptorSize + int64(len(section.data))
+		if index == len(sections)-1 {
+			next = offset
+		}
+		if section.stype == "sectors" {
+			sectorsData = offset + sectionDescriptorSize
+		}
+		if section.stype == "table" && len(section.data) >= tableHeaderSize {
+			binary.LittleEndian.PutUint64(section.data[8:], uint64(sectorsData))
+		}
+
+		descriptor := make([]byte, sectionDescriptorSize)
+		copy(descriptor, section.stype)
+		binary.LittleEndian.PutUint64(descriptor[16:], uint64(next))
+		binary.LittleEndian.PutUint64(descriptor[24:], uint64(size))
+		segment = append(segment, descriptor...)
+		segment = append(segment, section.data...)
+	}
+	return segment
+}
+
+func testCompress(data []byte) []byte {
+	var buffer bytes.Buffer
+	writer := zlib.NewWriter(&buffer)
+	writer.Write(data)
+	writer.Close()
+	return buffer.Bytes()
+}
+
+// testOpen opens segments held in memory
+func testOpen(segments [][]byte) (*Image, error) {
+	readers := make([]Segment, len(segments))
+	for index, segment := range segments {
+		readers[index] = bytes.NewReader(segment)
+	}
+	return OpenSegments(readers...)
+}
+
+// setSection replaces the sections of type stype with section
+func setSection(stype string, section testSection) func(int, []testSection) []testSection {
+	return func(segment int, sections []testSection) []testSection {
+		for index := range sections {
+			if sections[index].stype == stype {
+				sections[index] = section
+			}
+		}
+		return sections
+	}
+}
+
+func TestOpen(t *testing.T) {
+	media := testMedia()
+	hugeVolume := make([]byte, 94)
+	binary.LittleEndian.PutUint32(hugeVolume[8:], 0xFFFFFFFF)
+	binary.LittleEndian.PutUint32(hugeVolume[12:], 0xFFFFFFFF)
+
+	tests := []struct {
+		name     string
+		segments [][]byte
+		openErr  string
+		readErr  error
+	}{
+		{name: "one segment", segments: testSegments(media, 1, nil)},
+		{name: "three segments", segments: testSegments(media, 3, nil)},
+		{
+			name:     "section larger than the segment",
+			segments: testSegments(media, 1, setSection("header", testSection{stype: "header", data: []byte{1}, size: 0xFFFFFFFF})),
+			openErr:  cnst.ErrEWFSection.Error(),
+		},
+		{
+			name:     "section size below its descriptor",
+			segments: testSegments(media, 1, setSection("header", testSection{stype: "header", size: 12})),
+			openErr:  cnst.ErrEWFSection.Error(),
+		},
+		{
+			name:     "chunk larger than the volume allows",
+			segments: testSegments(media, 1, setSection("volume", testSection{stype: "volume", data: hugeVolume})),
+			openErr:  cnst.ErrEWFVolume.Error(),
+		},
+		{
+			name: "chunk running far past its stored size",
+			segments: testSegments(media, 1, func(segment int, sections []testSection) []testSection {
+				for index := range sections {
+					if sections[index].stype == "sectors" {
+						sections[index].data = append(sections[index].data, make([]byte, 4*testChunkSize)...)
+					}
+				}
+				return sections
+			}),
+			readErr: cnst.ErrEWFTable,
+		},
+		{
+			name:     "missing segment",
+			segments: testSegments(media, 3, nil)[:2],
+			openErr:  "EWF image is incomplete",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			image, err := testOpen(test.segments)
+			if test.openErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), test.openErr) {
+					t.Fatalf("got %v, want %s", err, test.openErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			read, err := io.ReadAll(io.NewSectionReader(image, 0, image.Size()))
+			if test.readErr != nil {
+				if !errors.Is(err, test.readErr) {
+					t.Fatalf("got %v, want %v", err, test.readErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(read, media) {
+				t.Fatal("media read back differs")
+			}
+			sum := md5.Sum(media)
+			if !bytes.Equal(image.Digests().MD5, sum[:]) {
+				t.Error("recorded MD5 not found")
+			}
+			if image.Acquisition().CaseNumber != "CASE-1" {
+				t.Errorf("got case number %q", image.Acquisition().CaseNumber)
+			}
+		})
+	}
+}
+
+func FuzzOpen(f *testing.F) {
+	f.Add(testSegments(testMedia(), 1, nil)[0])
+	f.Fuzz(func(t *testing.T, segment []byte) {
+		image, err := OpenSegments(bytes.NewReader(segment))
+		if err != nil {
+			return
+		}
+		io.Copy(io.Discard, io.NewSectionReader(image, 0, image.Size()))
+	})
+}
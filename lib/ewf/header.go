@@ -0,0 +1,107 @@
+package ewf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"indicer/lib/cnst"
+	"indicer/lib/digest"
+	"indicer/lib/structs"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// EWF header identifiers, see the libewf format documentation
+const (
+	headerCaseNumber  = "c"
+	headerEvidenceID  = "n"
+	headerDescription = "a"
+	headerExaminer    = "e"
+	headerNotes       = "t"
+	headerModel       = "md"
+	headerSerial      = "sn"
+	headerAcquired    = "m"
+)
+
+// headerDateLayout is how header sections written by older tools record dates, header2 uses unix time
+const headerDateLayout = "2006 1 2 15 4 5"
+
+// parseHeader decodes a zlib compressed header or header2 section. The third line
+// holds the identifiers and the fourth their values, both tab separated
+func parseHeader(data []byte) (map[string]string, error) {
+	raw, err := inflate(data, maxHeaderSize)
+	if err != nil {
+		return nil, err
+	}
+
+	text := string(raw)
+	if bytes.HasPrefix(raw, []byte{0xff, 0xfe}) {
+		text = decodeUTF16(raw[2:])
+	}
+
+	lines := strings.Split(strings.ReplaceAll(text, "\r", ""), "\n")
+	if len(lines) < 4 {
+		return nil, cnst.ErrEWFHeader
+	}
+	keys := strings.Split(lines[2], "\t")
+	values := strings.Split(lines[3], "\t")
+
+	header := make(map[string]string, len(keys))
+	for index, key := range keys {
+		if index < len(values) && values[index] != "" {
+			header[key] = values[index]
+		}
+	}
+	return header, nil
+}
+
+func decodeUTF16(raw []byte) string {
+	chars := make([]uint16, len(raw)/2)
+	for index := range chars {
+		chars[index] = binary.LittleEndian.Uint16(raw[index*2:])
+	}
+	return string(utf16.Decode(chars))
+}
+
+// Digests returns the MD5 and SHA-1 the acquisition tool computed over the media
+func (i *Image) Digests() digest.Digests {
+	return digest.Digests{MD5: i.md5, SHA1: i.sha1}
+}
+
+// Acquisition maps the case metadata of the image onto the acquisition metadata of an evidence file
+func (i *Image) Acquisition() structs.Acquisition {
+	acq := structs.Acquisition{
+		CaseNumber:   i.header[headerCaseNumber],
+		ExhibitID:    i.header[headerEvidenceID],
+		Examiner:     i.header[headerExaminer],
+		DeviceSerial: i.header[headerSerial],
+		Notes:        i.header[headerNotes],
+		AcquiredAt:   parseHeaderDate(i.header[headerAcquired]),
+		Digests:      i.Digests(),
+	}
+
+	for key, tag := range map[string]string{headerDescription: "description", headerModel: "model"} {
+		if value := i.header[key]; value != "" {
+			if acq.Tags == nil {
+				acq.Tags = make(map[string]string)
+			}
+			acq.Tags[tag] = value
+		}
+	}
+	return acq
+}
+
+func parseHeaderDate(value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC()
+	}
+	acquiredAt, err := time.Parse(headerDateLayout, strings.Join(strings.Fields(value), " "))
+	if err != nil {
+		return time.Time{}
+	}
+	return acquiredAt.UTC()
+}
@@ -26,6 +26,8 @@ type BlockManager struct {
 	currentBlock *Block
 	blockFiles   map[string]*os.File // Block ID (hex string) -> file handle
 	containerMgr *ContainerManager
+	// chunk hashes of every block file looked up so far, kept up to date as blocks are flushed
+	index map[string]map[[64]byte]struct{}
 }
 
 // Block represents a group of chunk metadata entries
@@ -50,6 +52,7 @@ func NewBlockManager(dbpath string, containerMgr *ContainerManager) *BlockManage
 		dbpath:       dbpath,
 		blockFiles:   make(map[string]*os.File),
 		containerMgr: containerMgr,
+		index:        make(map[string]map[[64]byte]struct{}),
 	}
 }
 
@@ -127,42 +130,53 @@ func (bm *BlockManager) GetChunkMetadata(chunkHash []byte) (string, int64, int64
 	return bm.searchBlockFile(file, actualHash)
 }
 
-// searchBlockFile performs sequential search within a block file
-func (bm *BlockManager) searchBlockFile(file *os.File, targetHash []byte) (string, int64, int64, error) {
-	for {
-		// Read chunk hash (64 bytes)
-		var storedHash [64]byte
-		if _, err := io.ReadFull(file, storedHash[:]); err != nil {
-			if err == io.EOF {
-				return "", 0, 0, fmt.Errorf("chunk not found in block")
-			}
-			return "", 0, 0, err
-		}
+// HasChunk tells whether a chunk was added through this manager or is in its block file,
+// each block file is read once and its hashes kept in memory for later lookups
+func (bm *BlockManager) HasChunk(chunkHash []byte) (bool, error) {
+	bm.mutex.Lock()
+	defer bm.mutex.Unlock()
 
-		// Read offset (8 bytes)
-		var offset int64
-		if err := binary.Read(file, binary.LittleEndian, &offset); err != nil {
-			return "", 0, 0, err
-		}
+	actualHash := chunkHash[ChonkNamespaceLength:]
+	var hashArray [64]byte
+	copy(hashArray[:], actualHash)
 
-		// Read size (8 bytes)
-		var size int64
-		if err := binary.Read(file, binary.LittleEndian, &size); err != nil {
-			return "", 0, 0, err
+	if bm.currentBlock != nil {
+		for _, meta := range bm.currentBlock.metadata {
+			if meta.chunkHash == hashArray {
+				return true, nil
+			}
 		}
+	}
 
-		// Read container path length (2 bytes)
-		var pathLen uint16
-		if err := binary.Read(file, binary.LittleEndian, &pathLen); err != nil {
-			return "", 0, 0, err
+	blockID := bm.getBlockID(actualHash)
+	hashes, ok := bm.index[blockID]
+	if !ok {
+		hashes = make(map[[64]byte]struct{})
+		err := WalkBlockFile(bm.getBlockFilePath(blockID), func(storedHash []byte, _ string, _, _ int64) error {
+			var stored [64]byte
+			copy(stored[:], storedHash)
+			hashes[stored] = struct{}{}
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return false, err
 		}
+		bm.index[blockID] = hashes
+	}
+	_, ok = hashes[hashArray]
+	return ok, nil
+}
 
-		// Read container path
-		pathBytes := make([]byte, pathLen)
-		if _, err := io.ReadFull(file, pathBytes); err != nil {
+// searchBlockFile performs sequential search within a block file
+func (bm *BlockManager) searchBlockFile(file *os.File, targetHash []byte) (string, int64, int64, error) {
+	for {
+		storedHash, containerPath, offset, size, err := readBlockEntry(file)
+		if err == io.EOF {
+			return "", 0, 0, fmt.Errorf("chunk not found in block")
+		}
+		if err != nil {
 			return "", 0, 0, err
 		}
-		containerPath := string(pathBytes)
 
 		// Check if this is the target chunk
 		if bytesEqual(storedHash[:], targetHash) {
@@ -171,6 +185,78 @@ func (bm *BlockManager) searchBlockFile(file *os.File, targetHash []byte) (strin
 	}
 }
 
+// BlockIndexFiles lists the block files of the hierarchical index
+func BlockIndexFiles(dbpath string) ([]string, error) {
+	return filepath.Glob(filepath.Join(dbpath, cnst.BLOBSDIR, "blocks", "*"+BlockIndexExt))
+}
+
+// WalkBlockFile calls fn for every entry of a block file in the order they were written,
+// chunkHash is the bare SHA3-512 hash without the chonk namespace
+func WalkBlockFile(blockFile string, fn func(chunkHash []byte, containerPath string, offset, size int64) error) error {
+	file, err := os.Open(blockFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	for {
+		storedHash, containerPath, offset, size, err := readBlockEntry(file)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		err = fn(storedHash[:], containerPath, offset, size)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readBlockEntry reads a single entry, io.EOF is only returned on a clean entry boundary
+func readBlockEntry(r io.Reader) ([64]byte, string, int64, int64, error) {
+	// Read chunk hash (64 bytes)
+	var storedHash [64]byte
+	if _, err := io.ReadFull(r, storedHash[:]); err != nil {
+		return storedHash, "", 0, 0, err
+	}
+
+	// Read offset (8 bytes)
+	var offset int64
+	if err := binary.Read(r, binary.LittleEndian, &offset); err != nil {
+		return storedHash, "", 0, 0, truncatedEntry(err)
+	}
+
+	// Read size (8 bytes)
+	var size int64
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return storedHash, "", 0, 0, truncatedEntry(err)
+	}
+
+	// Read container path length (2 bytes)
+	var pathLen uint16
+	if err := binary.Read(r, binary.LittleEndian, &pathLen); err != nil {
+		return storedHash, "", 0, 0, truncatedEntry(err)
+	}
+
+	// Read container path
+	pathBytes := make([]byte, pathLen)
+	if _, err := io.ReadFull(r, pathBytes); err != nil {
+		return storedHash, "", 0, 0, truncatedEntry(err)
+	}
+
+	return storedHash, string(pathBytes), offset, size, nil
+}
+
+func truncatedEntry(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
 // flushBlock writes block metadata to disk
 func (bm *BlockManager) flushBlock(block *Block) error {
 	if block == nil || block.chunkCount == 0 {
@@ -192,35 +278,103 @@ func (bm *BlockManager) flushBlock(block *Block) error {
 	defer file.Close()
 
 	// Write each chunk metadata
+	hashes := bm.index[block.blockID]
 	for _, meta := range block.metadata {
-		// Write hash (64 bytes)
-		if _, err := file.Write(meta.chunkHash[:]); err != nil {
+		if err := writeBlockEntry(file, meta.chunkHash[:], meta.containerPath, meta.offset, meta.size); err != nil {
 			return err
 		}
-
-		// Write offset (8 bytes)
-		if err := binary.Write(file, binary.LittleEndian, meta.offset); err != nil {
-			return err
+		if hashes != nil {
+			hashes[meta.chunkHash] = struct{}{}
 		}
+	}
 
-		// Write size (8 bytes)
-		if err := binary.Write(file, binary.LittleEndian, meta.size); err != nil {
-			return err
-		}
+	return file.Sync()
+}
 
-		// Write container path length (2 bytes)
-		pathLen := uint16(len(meta.containerPath))
-		if err := binary.Write(file, binary.LittleEndian, pathLen); err != nil {
-			return err
-		}
+func writeBlockEntry(w io.Writer, chunkHash []byte, containerPath string, offset, size int64) error {
+	// Write hash (64 bytes)
+	if _, err := w.Write(chunkHash); err != nil {
+		return err
+	}
 
-		// Write container path
-		if _, err := file.Write([]byte(meta.containerPath)); err != nil {
+	// Write offset (8 bytes)
+	if err := binary.Write(w, binary.LittleEndian, offset); err != nil {
+		return err
+	}
+
+	// Write size (8 bytes)
+	if err := binary.Write(w, binary.LittleEndian, size); err != nil {
+		return err
+	}
+
+	// Write container path length (2 bytes)
+	pathLen := uint16(len(containerPath))
+	if err := binary.Write(w, binary.LittleEndian, pathLen); err != nil {
+		return err
+	}
+
+	// Write container path
+	_, err := w.Write([]byte(containerPath))
+	return err
+}
+
+// BlockEntry is a single chunk location of a block file
+type BlockEntry struct {
+	ChunkHash     []byte // bare SHA3-512 hash without the chonk namespace
+	ContainerPath string
+	Offset        int64
+	Size          int64
+}
+
+// WriteBlockFile replaces the contents of a block file with entries, the new file is
+// written next to the old one and renamed over it. A block file left without entries
+// is removed.
+func WriteBlockFile(blockFile string, entries []BlockEntry) error {
+	if len(entries) == 0 {
+		return os.Remove(blockFile)
+	}
+
+	tempPath := blockFile + ".tmp"
+	file, err := os.Create(tempPath)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		err = writeBlockEntry(file, entry.ChunkHash, entry.ContainerPath, entry.Offset, entry.Size)
+		if err != nil {
+			file.Close()
+			os.Remove(tempPath)
 			return err
 		}
 	}
+	if err = file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tempPath)
+		return err
+	}
+	if err = file.Close(); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
 
-	return file.Sync()
+	return os.Rename(tempPath, blockFile)
+}
+
+// Flush writes out the block being filled, so every chunk added so far can be found
+func (bm *BlockManager) Flush() error {
+	bm.mutex.Lock()
+	defer bm.mutex.Unlock()
+
+	if bm.currentBlock == nil || bm.currentBlock.chunkCount == 0 {
+		return nil
+	}
+	err := bm.flushBlock(bm.currentBlock)
+	if err != nil {
+		return err
+	}
+	bm.currentBlock = nil
+	return nil
 }
 
 // Close flushes any remaining blocks and closes files
@@ -47,6 +47,7 @@ const MaxContainerSize = 1 * cnst.GB // 1GB max per container file
 type WriteRequest struct {
 	data       []byte
 	key        []byte
+	encoded    bool // data is already compressed and sealed, copied as is
 	responseCh chan WriteResponse
 }
 
@@ -63,6 +64,7 @@ type ContainerManager struct {
 	currentContainer string
 	currentFile      *os.File
 	currentOffset    int64
+	chunkEnds        []int64 // end offset of every chunk in the current container
 	dbpath           string
 	containerIndex   int
 	writeQueue       chan *WriteRequest // Lock-free write queue
@@ -114,6 +116,27 @@ func (cm *ContainerManager) WriteChunkToContainer(data, ckey, key []byte) (conta
 	return resp.containerPath, resp.offset, resp.size, resp.err
 }
 
+// WriteEncodedChunk appends chunk bytes read with ReadEncodedChunk to a container without
+// decoding them, used to move live chunks out of containers that are being rewritten
+func (cm *ContainerManager) WriteEncodedChunk(encoded []byte) (containerPath string, offset int64, size int64, err error) {
+	req := &WriteRequest{
+		data:       encoded,
+		encoded:    true,
+		responseCh: make(chan WriteResponse, 1),
+	}
+
+	cm.acceptMu.RLock()
+	if cm.closed {
+		cm.acceptMu.RUnlock()
+		return "", 0, 0, errors.New("container manager is closed")
+	}
+	cm.writeQueue <- req
+	cm.acceptMu.RUnlock()
+
+	resp := <-req.responseCh
+	return resp.containerPath, resp.offset, resp.size, resp.err
+}
+
 // writerLoop is the dedicated writer goroutine that processes all writes sequentially
 // This eliminates lock contention by having a single writer
 func (cm *ContainerManager) writerLoop() {
@@ -121,7 +144,7 @@ func (cm *ContainerManager) writerLoop() {
 
 	for req := range cm.writeQueue {
 		// Process write request
-		path, offset, size, err := cm.doWrite(req.data, req.key)
+		path, offset, size, err := cm.doWrite(req.data, req.key, req.encoded)
 
 		// Send response back to caller
 		req.responseCh <- WriteResponse{
@@ -134,10 +157,10 @@ func (cm *ContainerManager) writerLoop() {
 }
 
 // doWrite performs the actual write operation (called only by writer goroutine)
-func (cm *ContainerManager) doWrite(data, key []byte) (containerPath string, offset int64, size int64, err error) {
+func (cm *ContainerManager) doWrite(data, key []byte, encoded bool) (containerPath string, offset int64, size int64, err error) {
 	// Encrypt and compress data
 	var processedData []byte
-	if !cnst.QUICKOPT {
+	if !cnst.QUICKOPT && !encoded {
 		processedData = cnst.ENCODER.EncodeAll(data, make([]byte, 0, len(data)))
 		processedData, err = util.SealAES(key, processedData)
 		if err != nil {
@@ -173,6 +196,7 @@ func (cm *ContainerManager) doWrite(data, key []byte) (containerPath string, off
 
 	// Update offset for next write
 	cm.currentOffset += size
+	cm.chunkEnds = append(cm.chunkEnds, cm.currentOffset)
 
 	return containerPath, offset, size, nil
 }
@@ -194,18 +218,25 @@ func (cm *ContainerManager) createNewContainer() error {
 		}
 	}
 
-	// Generate unique container filename
-	cm.containerIndex++
-	ckhash, err := util.GetChonkHash([]byte(fmt.Sprintf("container_%d", cm.containerIndex)), sha3.New512())
-	if err != nil {
-		return err
+	// Generate unique container filename, the index restarts with every process so
+	// names already taken by an earlier store (open or compressed) are skipped
+	var cfpath string
+	for {
+		cm.containerIndex++
+		ckhash, err := util.GetChonkHash([]byte(fmt.Sprintf("container_%d", cm.containerIndex)), sha3.New512())
+		if err != nil {
+			return err
+		}
+		cfname := base64.RawURLEncoding.EncodeToString(ckhash)[:cnst.FileNameLen] + cnst.BLOBEXT
+		cfpath = filepath.Join(cm.dbpath, cnst.BLOBSDIR, cfname)
+		if !pathExists(cfpath) && !pathExists(strings.TrimSuffix(cfpath, cnst.BLOBEXT)+cnst.BLOBZSTEXT) {
+			break
+		}
 	}
-	cfname := base64.RawURLEncoding.EncodeToString(ckhash)[:cnst.FileNameLen] + cnst.BLOBEXT
-	cfpath := filepath.Join(cm.dbpath, cnst.BLOBSDIR, cfname)
 
 	// Ensure BLOBS directory exists
 	blobsDir := filepath.Join(cm.dbpath, cnst.BLOBSDIR)
-	err = os.MkdirAll(blobsDir, os.ModePerm)
+	err := os.MkdirAll(blobsDir, os.ModePerm)
 	if err != nil {
 		return err
 	}
@@ -219,6 +250,7 @@ func (cm *ContainerManager) createNewContainer() error {
 	cm.currentFile = file
 	cm.currentContainer = cfpath
 	cm.currentOffset = 0
+	cm.chunkEnds = nil
 
 	return nil
 }
@@ -257,7 +289,7 @@ func (cm *ContainerManager) Close() error {
 	return closeErr
 }
 
-// compressContainer compresses a container file using zstd
+// compressContainer compresses a container file into seekable zstd frames
 func (cm *ContainerManager) compressContainer(containerPath string) error {
 	if containerPath == "" {
 		return nil
@@ -283,22 +315,14 @@ func (cm *ContainerManager) compressContainer(containerPath string) error {
 		return err
 	}
 
-	encoder, err := zstd.NewWriter(dstFile, zstd.WithEncoderLevel(zstd.EncoderLevel(zstd.SpeedBestCompression)))
+	info, err := srcFile.Stat()
 	if err != nil {
 		dstFile.Close()
 		srcFile.Close()
 		os.Remove(tempCompressedPath)
 		return err
 	}
-
-	if _, err = io.Copy(encoder, srcFile); err != nil {
-		encoder.Close()
-		dstFile.Close()
-		srcFile.Close()
-		os.Remove(tempCompressedPath)
-		return err
-	}
-	if err = encoder.Close(); err != nil {
+	if err = writeSeekableContainer(dstFile, srcFile, info.Size(), cm.chunkEnds); err != nil {
 		dstFile.Close()
 		srcFile.Close()
 		os.Remove(tempCompressedPath)
@@ -346,7 +370,8 @@ func (cm *ContainerManager) compressContainer(containerPath string) error {
 	return nil
 }
 
-func resolveContainerPath(containerPath string) string {
+// ResolveContainerPath returns the compressed path of a container once it has been closed
+func ResolveContainerPath(containerPath string) string {
 	if strings.HasSuffix(containerPath, cnst.BLOBZSTEXT) {
 		return containerPath
 	}
@@ -359,13 +384,96 @@ func resolveContainerPath(containerPath string) string {
 	return containerPath
 }
 
+// ContainerSize returns the decompressed size of a container, seekable containers
+// answer from their seek table and older ones are decoded end to end
+func ContainerSize(containerPath string) (int64, error) {
+	containerPath = ResolveContainerPath(containerPath)
+	if strings.HasSuffix(containerPath, cnst.BLOBZSTEXT) {
+		table, err := getSeekTable(containerPath)
+		if err == nil {
+			return table.size, nil
+		}
+		if err != errNotSeekable {
+			return -1, err
+		}
+	}
+	return CheckContainer(containerPath)
+}
+
+// CheckContainer decodes a container end to end and returns its decompressed size, a
+// corrupt frame or a seek table that does not match the frames is reported as an error
+func CheckContainer(containerPath string) (int64, error) {
+	containerPath = ResolveContainerPath(containerPath)
+	if !strings.HasSuffix(containerPath, cnst.BLOBZSTEXT) {
+		info, err := os.Stat(containerPath)
+		if err != nil {
+			return -1, err
+		}
+		return info.Size(), nil
+	}
+
+	table, err := getSeekTable(containerPath)
+	if err != nil && err != errNotSeekable {
+		return -1, err
+	}
+
+	file, err := os.Open(containerPath)
+	if err != nil {
+		return -1, err
+	}
+	defer file.Close()
+
+	decoder, err := zstd.NewReader(file)
+	if err != nil {
+		return -1, fmt.Errorf("failed to create decompressor: %w", err)
+	}
+	defer decoder.Close()
+
+	size, err := io.Copy(io.Discard, decoder)
+	if err != nil {
+		return -1, err
+	}
+	if table != nil && table.size != size {
+		return -1, fmt.Errorf("decoded %d bytes, seek table says %d: %w", size, table.size, errBadSeekTable)
+	}
+	return size, nil
+}
+
 // ReadChunkFromContainer reads a chunk from a container file at the specified offset
 func ReadChunkFromContainer(containerPath string, offset, size int64, key []byte) ([]byte, error) {
+	encoded, err := ReadEncodedChunk(containerPath, offset, size)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decrypt and decompress
+	var data []byte
+	if !cnst.QUICKOPT {
+		decrypted, err := util.UnsealAES(key, encoded)
+		if err != nil {
+			return nil, err
+		}
+		data = decrypted
+
+		decoded, err := cnst.DECODER.DecodeAll(data, nil)
+		if err != nil {
+			return nil, err
+		}
+		data = decoded
+	} else {
+		data = encoded
+	}
+
+	return data, nil
+}
+
+// ReadEncodedChunk reads the stored bytes of a chunk as they are, still compressed and sealed
+func ReadEncodedChunk(containerPath string, offset, size int64) ([]byte, error) {
 	if size < 0 || offset < 0 {
 		return nil, fmt.Errorf("invalid offset/size: offset=%d size=%d", offset, size)
 	}
 
-	containerPath = resolveContainerPath(containerPath)
+	containerPath = ResolveContainerPath(containerPath)
 
 	encoded := make([]byte, size)
 
@@ -390,23 +498,10 @@ func ReadChunkFromContainer(containerPath string, offset, size int64, key []byte
 		}
 	}
 
-	// Decrypt and decompress
-	var data []byte
-	if !cnst.QUICKOPT {
-		decrypted, err := util.UnsealAES(key, encoded)
-		if err != nil {
-			return nil, err
-		}
-		data = decrypted
-
-		decoded, err := cnst.DECODER.DecodeAll(data, nil)
-		if err != nil {
-			return nil, err
-		}
-		data = decoded
-	} else {
-		data = encoded
-	}
+	return encoded, nil
+}
 
-	return data, nil
+func pathExists(path string) bool {
+	_, err := os.Stat(path)
+	return !os.IsNotExist(err)
 }
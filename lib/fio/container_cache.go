@@ -70,6 +70,14 @@ func DisableContainerReadCache() {
 }
 
 func readFromCompressedContainer(containerPath string, offset, size int64) ([]byte, error) {
+	table, err := getSeekTable(containerPath)
+	if err == nil {
+		return readSeekableChunk(containerPath, table, offset, size)
+	}
+	if err != errNotSeekable {
+		return nil, err
+	}
+
 	if data, ok := getCachedDecompressedContainer(containerPath); ok {
 		return extractEncodedChunk(data, offset, size)
 	}
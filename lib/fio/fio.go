@@ -30,20 +30,17 @@ func WriteChonk(dbpath string, data, ckey, key []byte) ([]byte, error) {
 }
 
 func ReadChonk(cfpath, key []byte) ([]byte, error) {
-	var data []byte
-
-	encoded, err := os.ReadFile(string(cfpath))
+	data, err := os.ReadFile(string(cfpath))
 	if err != nil {
 		return nil, err
 	}
-	decrypted, err := util.UnsealAES(key, encoded)
-	if err == nil {
-		data = decrypted
-	}
-	decoded, err := cnst.DECODER.DecodeAll(data, nil)
-	if err == nil {
-		data = decoded
+	if cnst.QUICKOPT {
+		return data, nil
 	}
 
-	return data, nil
+	data, err = util.UnsealAES(key, data)
+	if err != nil {
+		return nil, err
+	}
+	return cnst.DECODER.DecodeAll(data, nil)
 }
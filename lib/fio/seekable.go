@@ -0,0 +1,295 @@
+package fio
+
+/*
+Seekable Containers:
+
+Finalized containers are written in the zstd seekable format. The container is
+split into independently compressed frames, followed by a skippable frame that
+holds the seek table:
+
+  [frame 0][frame 1]...[frame n-1][skippable frame: seek table]
+
+  Seek table: Magic(0x184D2A5E) FrameSize(u32)
+              n x { CompressedSize(u32) DecompressedSize(u32) }
+              NumberOfFrames(u32) Descriptor(u8) SeekableMagic(0x8F92EAB1)
+
+Frames are cut on chunk boundaries once they hold at least SeekableFrameSize
+bytes, so a chunk read only ever decodes the frame it lives in. Plain zstd
+readers skip the seek table, and containers compressed as a single stream
+before this format have no seek table and keep using the container cache.
+*/
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"indicer/lib/cnst"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	SeekableFrameSize   = 1 * cnst.MB // minimum decompressed bytes per frame
+	seekTableMagic      = 0x184D2A5E
+	seekableMagic       = 0x8F92EAB1
+	seekFooterSize      = 9
+	seekEntrySize       = 8
+	seekChecksumFlag    = 0x80
+	skippableHeaderSize = 8
+	maxLastFrames       = 64 // containers with a decoded frame kept around
+)
+
+type seekFrame struct {
+	compressedOffset   int64
+	compressedSize     int64
+	decompressedOffset int64
+	decompressedSize   int64
+}
+
+type seekTable struct {
+	fileSize int64 // size and modification time of the container the table was read from
+	modTime  time.Time
+	size     int64 // total decompressed size
+	frames   []seekFrame
+}
+
+type decodedFrame struct {
+	index int
+	data  []byte
+}
+
+var (
+	seekTableMu     sync.Mutex
+	seekTables      = map[string]*seekTable{}
+	lastFrames      = map[string]decodedFrame{}
+	errNotSeekable  = errors.New("container has no seek table")
+	errBadSeekTable = errors.New("corrupt seek table")
+)
+
+// writeSeekableContainer compresses src into dst frame by frame, chunkEnds holds the
+// end offset of every chunk written to the container in order
+func writeSeekableContainer(dst io.Writer, src io.Reader, size int64, chunkEnds []int64) error {
+	var frames []seekFrame
+	var start int64
+	for _, end := range planFrames(size, chunkEnds) {
+		data := make([]byte, end-start)
+		if _, err := io.ReadFull(src, data); err != nil {
+			return err
+		}
+
+		compressed := cnst.ENCODER.EncodeAll(data, make([]byte, 0, len(data)))
+		if _, err := dst.Write(compressed); err != nil {
+			return err
+		}
+		frames = append(frames, seekFrame{compressedSize: int64(len(compressed)), decompressedSize: int64(len(data))})
+		start = end
+	}
+
+	table := make([]byte, 0, skippableHeaderSize+len(frames)*seekEntrySize+seekFooterSize)
+	table = binary.LittleEndian.AppendUint32(table, seekTableMagic)
+	table = binary.LittleEndian.AppendUint32(table, uint32(len(frames)*seekEntrySize+seekFooterSize))
+	for _, frame := range frames {
+		table = binary.LittleEndian.AppendUint32(table, uint32(frame.compressedSize))
+		table = binary.LittleEndian.AppendUint32(table, uint32(frame.decompressedSize))
+	}
+	table = binary.LittleEndian.AppendUint32(table, uint32(len(frames)))
+	table = append(table, 0) // no per-frame checksums, every frame carries its own
+	table = binary.LittleEndian.AppendUint32(table, seekableMagic)
+
+	_, err := dst.Write(table)
+	return err
+}
+
+// planFrames returns the end offset of every frame, frames end on the first chunk
+// boundary past SeekableFrameSize
+func planFrames(size int64, chunkEnds []int64) []int64 {
+	var ends []int64
+	var start int64
+	for _, end := range chunkEnds {
+		if end > size {
+			break
+		}
+		if end-start >= SeekableFrameSize {
+			ends = append(ends, end)
+			start = end
+		}
+	}
+
+	// bytes without known chunk boundaries are cut into fixed frames
+	for size-start > SeekableFrameSize {
+		start += SeekableFrameSize
+		ends = append(ends, start)
+	}
+	if size > start || len(ends) == 0 {
+		ends = append(ends, size)
+	}
+	return ends
+}
+
+// getSeekTable returns the seek table of a compressed container, errNotSeekable
+// means the container is a single legacy stream
+func getSeekTable(containerPath string) (*seekTable, error) {
+	info, err := os.Stat(containerPath)
+	if err != nil {
+		return nil, err
+	}
+
+	seekTableMu.Lock()
+	table, ok := seekTables[containerPath]
+	seekTableMu.Unlock()
+	if ok && table.fileSize == info.Size() && table.modTime.Equal(info.ModTime()) {
+		if table.frames == nil {
+			return nil, errNotSeekable
+		}
+		return table, nil
+	}
+
+	file, err := os.Open(containerPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	table, err = readSeekTable(file, info.Size())
+	if err != nil && err != errNotSeekable {
+		return nil, err
+	}
+	table.modTime = info.ModTime()
+
+	seekTableMu.Lock()
+	seekTables[containerPath] = table
+	delete(lastFrames, containerPath)
+	seekTableMu.Unlock()
+
+	if table.frames == nil {
+		return nil, errNotSeekable
+	}
+	return table, nil
+}
+
+func readSeekTable(file *os.File, fileSize int64) (*seekTable, error) {
+	table := &seekTable{fileSize: fileSize}
+	if fileSize < skippableHeaderSize+seekFooterSize {
+		return table, errNotSeekable
+	}
+
+	footer := make([]byte, seekFooterSize)
+	if _, err := file.ReadAt(footer, fileSize-seekFooterSize); err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(footer[5:]) != seekableMagic {
+		return table, errNotSeekable
+	}
+
+	numFrames := int64(binary.LittleEndian.Uint32(footer[:4]))
+	descriptor := footer[4]
+	entrySize := int64(seekEntrySize)
+	if descriptor&seekChecksumFlag != 0 {
+		entrySize += 4
+	}
+	if descriptor&^byte(seekChecksumFlag) != 0 {
+		return nil, errBadSeekTable
+	}
+
+	tableSize := numFrames*entrySize + seekFooterSize
+	tableStart := fileSize - tableSize - skippableHeaderSize
+	if tableStart < 0 {
+		return nil, errBadSeekTable
+	}
+	raw := make([]byte, tableSize+skippableHeaderSize)
+	if _, err := file.ReadAt(raw, tableStart); err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(raw[:4]) != seekTableMagic || int64(binary.LittleEndian.Uint32(raw[4:8])) != tableSize {
+		return nil, errBadSeekTable
+	}
+
+	var compressedOffset, decompressedOffset int64
+	entries := raw[skippableHeaderSize:]
+	table.frames = make([]seekFrame, 0, numFrames)
+	for i := int64(0); i < numFrames; i++ {
+		entry := entries[i*entrySize:]
+		frame := seekFrame{
+			compressedOffset:   compressedOffset,
+			compressedSize:     int64(binary.LittleEndian.Uint32(entry[:4])),
+			decompressedOffset: decompressedOffset,
+			decompressedSize:   int64(binary.LittleEndian.Uint32(entry[4:8])),
+		}
+		table.frames = append(table.frames, frame)
+		compressedOffset += frame.compressedSize
+		decompressedOffset += frame.decompressedSize
+	}
+	if compressedOffset != tableStart {
+		return nil, errBadSeekTable
+	}
+
+	table.size = decompressedOffset
+	return table, nil
+}
+
+// readSeekableChunk decodes only the frames holding [offset, offset+size)
+func readSeekableChunk(containerPath string, table *seekTable, offset, size int64) ([]byte, error) {
+	if offset+size > table.size {
+		return nil, fmt.Errorf("offset %d + size %d exceeds decompressed container size", offset, size)
+	}
+
+	first := sort.Search(len(table.frames), func(i int) bool {
+		frame := table.frames[i]
+		return frame.decompressedOffset+frame.decompressedSize > offset
+	})
+
+	encoded := make([]byte, 0, size)
+	for index := first; int64(len(encoded)) < size && index < len(table.frames); index++ {
+		data, err := readSeekableFrame(containerPath, table, index)
+		if err != nil {
+			return nil, err
+		}
+
+		frame := table.frames[index]
+		from := max(offset-frame.decompressedOffset, 0)
+		to := min(offset+size-frame.decompressedOffset, frame.decompressedSize)
+		encoded = append(encoded, data[from:to]...)
+	}
+	return encoded, nil
+}
+
+// readSeekableFrame keeps the last decoded frame of every container around, chunks
+// are mostly read in order so neighbours in the same frame are served without decoding
+func readSeekableFrame(containerPath string, table *seekTable, index int) ([]byte, error) {
+	seekTableMu.Lock()
+	last, ok := lastFrames[containerPath]
+	seekTableMu.Unlock()
+	if ok && last.index == index {
+		return last.data, nil
+	}
+
+	file, err := os.Open(containerPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	frame := table.frames[index]
+	compressed := make([]byte, frame.compressedSize)
+	if _, err = file.ReadAt(compressed, frame.compressedOffset); err != nil {
+		return nil, err
+	}
+	data, err := cnst.DECODER.DecodeAll(compressed, make([]byte, 0, frame.decompressedSize))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != frame.decompressedSize {
+		return nil, fmt.Errorf("frame %d decoded to %d bytes, seek table says %d", index, len(data), frame.decompressedSize)
+	}
+
+	seekTableMu.Lock()
+	if _, ok := lastFrames[containerPath]; !ok && len(lastFrames) >= maxLastFrames {
+		clear(lastFrames)
+	}
+	lastFrames[containerPath] = decodedFrame{index: index, data: data}
+	seekTableMu.Unlock()
+	return data, nil
+}
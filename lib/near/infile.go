@@ -70,7 +70,7 @@ func nearIndexFile(fid []byte, db *badger.DB, deep ...bool) (*structs.ConcMap, e
 		isdeep = deep[0]
 	}
 	iname := util.GetArbitratyMapKey(ifile.Names)
-	return getNearLogicalFile(ifile.Start, ifile.Size, iname, fid, db, isdeep)
+	return getNearLogicalFile(ifile.Runs(), iname, fid, db, isdeep)
 }
 func nearPartitionFile(fid []byte, db *badger.DB, deep ...bool) (*structs.ConcMap, error) {
 	pfile, err := dbio.GetPartitionFile(fid, db)
@@ -82,7 +82,7 @@ func nearPartitionFile(fid []byte, db *badger.DB, deep ...bool) (*structs.ConcMa
 		isdeep = deep[0]
 	}
 	pname := util.GetArbitratyMapKey(pfile.Names)
-	return getNearLogicalFile(pfile.Start, pfile.Size, pname, fid, db, isdeep)
+	return getNearLogicalFile(pfile.Runs(), pname, fid, db, isdeep)
 }
 func nearEvidenceFile(fid []byte, db *badger.DB, deep ...bool) (*structs.ConcMap, error) {
 	efile, err := dbio.GetEvidenceFile(fid, db)
@@ -94,10 +94,10 @@ func nearEvidenceFile(fid []byte, db *badger.DB, deep ...bool) (*structs.ConcMap
 	if len(deep) > 0 {
 		isdeep = deep[0]
 	}
-	return getNearFile(efile.Start, efile.Size, ehash, fid, db, isdeep)
+	return getNearFile(efile.Runs(), ehash, fid, db, isdeep)
 }
 
-func getNearLogicalFile(start, size int64, fname string, fid []byte, db *badger.DB, deep ...bool) (*structs.ConcMap, error) {
+func getNearLogicalFile(runs []structs.Extent, fname string, fid []byte, db *badger.DB, deep ...bool) (*structs.ConcMap, error) {
 	ehash, err := util.GetEvidenceFileHash(fname)
 	if err != nil {
 		return nil, err
@@ -106,13 +106,17 @@ func getNearLogicalFile(start, size int64, fname string, fid []byte, db *badger.
 	if len(deep) > 0 {
 		isdeep = deep[0]
 	}
-	return getNearFile(start, size, ehash, fid, db, isdeep)
+	return getNearFile(runs, ehash, fid, db, isdeep)
 }
-func getNearFile(start, size int64, ehash, fid []byte, db *badger.DB, deep ...bool) (*structs.ConcMap, error) {
+func getNearFile(runs []structs.Extent, ehash, fid []byte, db *badger.DB, deep ...bool) (*structs.ConcMap, error) {
 	fhash := bytes.Split(fid, []byte(cnst.NamespaceSeperator))[1]
 	idmap := structs.NewConcMap()
 
 	fmt.Println("Finding NeAR Artefacts....")
+	var size int64
+	for _, run := range runs {
+		size += run.Size
+	}
 	bar := progressbar.DefaultBytes(size)
 
 	var active int
@@ -123,7 +127,7 @@ func getNearFile(start, size int64, ehash, fid []byte, db *badger.DB, deep ...bo
 		isdeep = deep[0]
 	}
 
-	for near := range getNear(start, size, ehash, db, isdeep) {
+	for near := range getNear(runs, ehash, db, isdeep) {
 		if active > cnst.GetMaxThreadCount() {
 			if <-echan != nil {
 				return nil, <-echan
@@ -156,32 +160,47 @@ func getNearFile(start, size int64, ehash, fid []byte, db *badger.DB, deep ...bo
 	return idmap, bar.Close()
 }
 
+// updateConfidence turns the matched chonk count of every object into the percentage
+// of its chonks that matched
 func updateConfidence(idmap *structs.ConcMap, db *badger.DB) error {
-	var size int64
 	for id := range idmap.GetData() {
-		if strings.HasPrefix(id, cnst.IdxFileNamespace) {
+		var runs []structs.Extent
+		var ehash []byte
+		switch {
+		case strings.HasPrefix(id, cnst.IdxFileNamespace):
 			ifile, err := dbio.GetIndexedFile([]byte(id), db)
 			if err != nil {
 				return err
 			}
-			size = ifile.Size
-		}
-		if strings.HasPrefix(id, cnst.PartiFileNamespace) {
+			runs = ifile.Runs()
+			ehash, err = util.GetEvidenceFileHash(util.GetArbitratyMapKey(ifile.Names))
+			if err != nil {
+				return err
+			}
+		case strings.HasPrefix(id, cnst.PartiFileNamespace):
 			pfile, err := dbio.GetPartitionFile([]byte(id), db)
 			if err != nil {
 				return err
 			}
-			size = pfile.Size
-		}
-		if strings.HasPrefix(id, cnst.EviFileNamespace) {
+			runs = pfile.Runs()
+			ehash, err = util.GetEvidenceFileHash(util.GetArbitratyMapKey(pfile.Names))
+			if err != nil {
+				return err
+			}
+		case strings.HasPrefix(id, cnst.EviFileNamespace):
 			efile, err := dbio.GetEvidenceFile([]byte(id), db)
 			if err != nil {
 				return err
 			}
-			size = efile.Size
+			runs = efile.Runs()
+			ehash = bytes.Split([]byte(id), []byte(cnst.NamespaceSeperator))[1]
 		}
 
-		chonks := float64(size / cnst.ChonkSize)
+		indices, err := nearIndices(runs, ehash, db)
+		if err != nil {
+			return err
+		}
+		chonks := float64(max(len(indices), 1))
 		confidence, _ := idmap.Get(id)
 		confidence = (confidence / chonks) * 100
 		idmap.Set(id, confidence, true)
@@ -193,23 +212,24 @@ func updateConfidence(idmap *structs.ConcMap, db *badger.DB) error {
 // getNear function loops through entire file indexed in db
 // finds all the relation nodes, uses relation nodes to find
 // all the chonk --> rel reverse relation objects
-func getNear(start, size int64, ehash []byte, db *badger.DB, deep bool) chan structs.NearGen {
+func getNear(runs []structs.Extent, ehash []byte, db *badger.DB, deep bool) chan structs.NearGen {
 	neargenChan := make(chan structs.NearGen)
 	seenMap := make(map[int64]struct{})
 
-	var dbstart int64
-	if start > 0 {
-		dbstart = util.GetDBStartOffset(start)
-	}
-
 	go func() {
 		defer close(neargenChan)
 
-		end := start + size
 		var neargen structs.NearGen
 
+		indices, err := nearIndices(runs, ehash, db)
+		if err != nil {
+			neargen.Err = err
+			neargenChan <- neargen
+			return
+		}
+
 		var confidence float64
-		for nearIndex := dbstart; nearIndex < end; nearIndex += cnst.ChonkSize {
+		for _, nearIndex := range indices {
 			relKey := util.AppendToBytesSlice(cnst.RelationNamespace, ehash, cnst.DataSeperator, nearIndex)
 			split := bytes.Split(relKey, []byte(cnst.DataSeperator))
 			idxstr := split[len(split)-1]
@@ -297,6 +317,19 @@ func getNear(start, size int64, ehash []byte, db *badger.DB, deep bool) chan str
 	return neargenChan
 }
 
+// nearIndices lists the relation indices of the chonks holding the runs of a file
+func nearIndices(runs []structs.Extent, ehash []byte, db *badger.DB) ([]int64, error) {
+	var indices []int64
+	for _, run := range runs {
+		runIndices, err := dbio.GetRelationIndices(ehash, run.Start, run.Start+run.Size, db)
+		if err != nil {
+			return nil, err
+		}
+		indices = append(indices, runIndices...)
+	}
+	return indices, nil
+}
+
 func partialMatch(inhash, chash []byte, db *badger.DB) (map[string]struct{}, float64, error) {
 	ckey := util.AppendToBytesSlice(cnst.ChonkNamespace, chash)
 	cdata, err := dbio.GetNode(ckey, db)
@@ -110,8 +110,7 @@ func countFile(ridx int64, namespace string, inputHash, fhash []byte, offset str
 		return nil, false, nil
 	}
 
-	inRange := isInRange(offset.Start, offset.End, ridx)
-	return id, inRange, nil
+	return id, offset.Contains(ridx), nil
 }
 
 func getIDFromHash(namespace, hashStr string) ([]byte, error) {
@@ -122,10 +121,6 @@ func getIDFromHash(namespace, hashStr string) ([]byte, error) {
 	return util.AppendToBytesSlice(namespace, hash), nil
 }
 
-func isInRange(start, end, index int64) bool {
-	return index >= start && index <= end
-}
-
 func partialChonkMatch(inhash, chonk []byte, db *badger.DB) ([]byte, float64, error) {
 	var confidence float64
 	var keyToReturn []byte
@@ -3,7 +3,6 @@ package near
 import (
 	"encoding/hex"
 	"fmt"
-	"indicer/lib/cnst"
 	"indicer/lib/util"
 	"os"
 	"time"
@@ -61,13 +60,9 @@ func getOutfileChonks(size int64, mappedFile mmap.MMap) chan []byte {
 	chonk := make(chan []byte)
 	go func() {
 		defer close(chonk)
-		for outindex := int64(0); outindex <= size; outindex += cnst.ChonkSize {
-			var buffSize int64
-			if size-outindex <= cnst.ChonkSize {
-				buffSize = size - outindex
-			} else {
-				buffSize = cnst.ChonkSize
-			}
+		var buffSize int64
+		for outindex := int64(0); outindex < size; outindex += buffSize {
+			buffSize = util.GetNextChonkSize(mappedFile[outindex:size])
 			chonk <- mappedFile[outindex : outindex+buffSize]
 		}
 	}()
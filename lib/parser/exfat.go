@@ -1,201 +1,437 @@
 package parser
 
 import (
-	"errors"
+	"bytes"
+	"encoding/binary"
 	"indicer/lib/cnst"
-	"indicer/lib/dbio"
-	"indicer/lib/store"
 	"indicer/lib/structs"
-	"indicer/lib/util"
-	"os"
+	"io"
+	"unicode/utf16"
+)
 
-	"github.com/aoiflux/libxfat"
-	"github.com/dgraph-io/badger/v4"
-	"github.com/schollz/progressbar/v3"
-	"golang.org/x/crypto/sha3"
+const (
+	exfatSignature     = "EXFAT   "
+	exfatDirEntrySize  = 32
+	exfatEntryFile     = 0x85
+	exfatEntryStream   = 0xC0
+	exfatEntryName     = 0xC1
+	exfatEntryBitmap   = 0x81
+	exfatEntryInUse    = 0x80
+	exfatNameChars     = 15
+	exfatAttrDir       = 0x10
+	exfatNoFatChain    = 0x02
+	exfatFirstCluster  = 2
+	exfatLastCluster   = 0xFFFFFFF6
+	exfatMaxDirSize    = 256 << 20
+	bootSectorSigIndex = 510
 )
 
-func IndexEXFAT(pfile structs.InputFile, idxChan chan error) {
-	startOffset := getStartOffset(uint64(pfile.GetStartIndex()))
-	exfatdata, err := libxfat.New(pfile.GetHandle(), true, startOffset)
+// exfatVolume is an exFAT file system found at start of the evidence, all offsets are absolute
+type exfatVolume struct {
+	r            io.ReaderAt
+	fatOffset    int64
+	heapOffset   int64
+	clusterSize  int64
+	clusterCount uint32
+	rootCluster  uint32
+}
+
+// exfatEntry is a file directory entry set, deleted is set when its in use bits are cleared
+// and intact when the set still matches its checksum
+type exfatEntry struct {
+	name       string
+	attr       uint16
+	cluster    uint32
+	size       int64
+	noFatChain bool
+	deleted    bool
+	intact     bool
+}
+
+func (e exfatEntry) isDir() bool {
+	return e.attr&exfatAttrDir != 0
+}
+
+// ListEXFAT returns the files of the exFAT volume at partition, their hashes are filled in
+// by the ingest pass. Fragmented files are listed with the runs of their cluster chain,
+// deleted files are only listed in deleted mode. File slack, unallocated clusters and
+// volume slack are listed after the files
+func ListEXFAT(r io.ReaderAt, partition structs.PartitionFile) ([]*structs.IngestRange, error) {
+	volume, err := openEXFAT(r, partition.Start)
 	if err != nil {
-		idxChan <- cnst.ErrIncompatibleFileSystem
+		return nil, cnst.ErrIncompatibleFileSystem
+	}
+	// clusters past the end of the partition are corrupt, the heap is cut where it ends
+	if partition.Size > 0 {
+		heapEnd := max(partition.Start+partition.Size-volume.heapOffset, 0)
+		volume.clusterCount = uint32(min(int64(volume.clusterCount), heapEnd/volume.clusterSize))
 	}
 
-	rootEntries, err := exfatdata.ReadRootDir()
+	allEntries, err := volume.walk()
 	if err != nil {
-		idxChan <- err
+		return nil, err
 	}
 
-	indexableEntries, err := exfatdata.GetIndexableEntries(rootEntries)
+	bitmap, err := volume.allocationBitmap()
 	if err != nil {
-		idxChan <- err
+		return nil, err
 	}
 
-	var flag bool
-	total := int64(len(indexableEntries))
-	bar := progressbar.Default(total, "indexing files")
-	bar.Clear()
+	// a size larger than the cluster heap is corrupt and would overflow the cluster count
+	heapSize := int64(volume.clusterCount) * volume.clusterSize
+	var entries []*structs.IngestRange
+	for _, entry := range allEntries {
+		if entry.isDir() || entry.cluster == 0 || entry.size <= 0 || entry.size > heapSize {
+			continue
+		}
+		if entry.deleted {
+			if !cnst.DELETEDOPT || bitmap == nil {
+				continue
+			}
+			irange, err := volume.recoverDeleted(entry, bitmap)
+			if err != nil {
+				return nil, err
+			}
+			if irange != nil {
+				entries = append(entries, irange)
+			}
+			continue
+		}
+		runs, err := volume.runs(entry)
+		if err != nil {
+			return nil, err
+		}
+		if runs == nil {
+			continue
+		}
+		entries = append(entries, newIngestRange(entry.name, runs, entry.size))
+	}
 
-	encodedPfileHash, err := pfile.GetEncodedHash()
-	if err != nil {
-		idxChan <- err
+	var free []run
+	if bitmap != nil {
+		free = freeRuns(bitmap, uint64(volume.clusterCount), volume.heapOffset, volume.clusterSize)
 	}
+	end := volume.heapOffset + int64(volume.clusterCount)*volume.clusterSize
+	return append(entries, spaceRanges(entries, free, volume.heapOffset, volume.clusterSize, end, partition)...), nil
+}
 
-	batch, err := util.InitBatch(pfile.GetDB())
+func isEXFAT(r io.ReaderAt, start int64) bool {
+	_, err := openEXFAT(r, start)
+	return err == nil
+}
+
+func openEXFAT(r io.ReaderAt, start int64) (*exfatVolume, error) {
+	vbr := make([]byte, cnst.SectorSize)
+	_, err := r.ReadAt(vbr, start)
 	if err != nil {
-		idxChan <- err
+		return nil, err
+	}
+	if string(vbr[3:11]) != exfatSignature || !bytes.Equal(vbr[bootSectorSigIndex:], []byte{0x55, 0xAA}) {
+		return nil, cnst.ErrIncompatibleFileSystem
 	}
 
-	var index int
-	var entry libxfat.Entry
-	idxmap := make(map[string]structs.IndexedFile)
-	for index, entry = range indexableEntries {
-		indexableEntries = util.Reslice(indexableEntries, 0)
+	sectorShift := vbr[0x6C]
+	clusterShift := vbr[0x6D]
+	if sectorShift < 9 || sectorShift > 12 || clusterShift > 25-sectorShift {
+		return nil, cnst.ErrIncompatibleFileSystem
+	}
+	sectorSize := int64(1) << sectorShift
 
-		if !flag {
-			flag = checkChannel(idxChan)
-			if flag {
-				bar.Set(index)
-			}
+	volume := &exfatVolume{
+		r:            r,
+		fatOffset:    start + int64(binary.LittleEndian.Uint32(vbr[0x50:]))*sectorSize,
+		heapOffset:   start + int64(binary.LittleEndian.Uint32(vbr[0x58:]))*sectorSize,
+		clusterSize:  sectorSize << clusterShift,
+		clusterCount: binary.LittleEndian.Uint32(vbr[0x5C:]),
+		rootCluster:  binary.LittleEndian.Uint32(vbr[0x60:]),
+	}
+	if !volume.validCluster(volume.rootCluster) {
+		return nil, cnst.ErrIncompatibleFileSystem
+	}
+	return volume, nil
+}
+
+func (v *exfatVolume) validCluster(cluster uint32) bool {
+	return cluster >= exfatFirstCluster && cluster < v.clusterCount+exfatFirstCluster
+}
+
+func (v *exfatVolume) clusterOffset(cluster uint32) int64 {
+	return v.heapOffset + int64(cluster-exfatFirstCluster)*v.clusterSize
+}
+
+func (v *exfatVolume) nextCluster(cluster uint32) (uint32, error) {
+	entry := make([]byte, 4)
+	_, err := v.r.ReadAt(entry, v.fatOffset+int64(cluster)*4)
+	return binary.LittleEndian.Uint32(entry), err
+}
+
+// clusters returns the clusters of a file, size limits how many are read
+// when the file is contiguous and has no FAT chain
+func (v *exfatVolume) clusters(first uint32, size int64, noFatChain bool) ([]uint32, error) {
+	if !v.validCluster(first) {
+		return nil, nil
+	}
+	if noFatChain {
+		count := max((size+v.clusterSize-1)/v.clusterSize, 1)
+		count = min(count, int64(v.clusterCount+exfatFirstCluster-first))
+		clusters := make([]uint32, count)
+		for index := range clusters {
+			clusters[index] = first + uint32(index)
 		}
+		return clusters, nil
+	}
 
-		iname := string(util.AppendToBytesSlice(pfile.GetEviFileHash(), cnst.DataSeperator, encodedPfileHash, cnst.DataSeperator, entry.GetName()))
-		istart := int64(exfatdata.GetClusterOffset(entry.GetEntryCluster()))
-		isize := int64(entry.GetSize())
-		ihash, err := util.GetLogicalFileHash(pfile.GetHandle(), sha3.New256(), istart, isize, false)
+	var clusters []uint32
+	for cluster := first; v.validCluster(cluster) && cluster < exfatLastCluster; {
+		// a looping chain is cut off once it is longer than the volume
+		if len(clusters) > int(v.clusterCount) {
+			break
+		}
+		clusters = append(clusters, cluster)
+		next, err := v.nextCluster(cluster)
 		if err != nil {
-			idxChan <- err
+			return nil, err
 		}
+		cluster = next
+	}
+	return clusters, nil
+}
 
-		if val, ok := idxmap[string(ihash)]; ok {
-			if _, ok := val.Names[iname]; !ok {
-				val.Names[iname] = struct{}{}
-			}
-		} else {
-			idxmap[string(ihash)] = structs.NewIndexedFile(iname, istart, isize)
-		}
-		pfile.UpdateInternalObjects(istart, isize, ihash)
+// runs returns the runs of a file in file order cut to its size, nil when its
+// cluster chain is shorter than the file
+func (v *exfatVolume) runs(entry exfatEntry) ([]run, error) {
+	clusters, err := v.clusters(entry.cluster, entry.size, entry.noFatChain)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(clusters))*v.clusterSize < entry.size {
+		return nil, nil
+	}
+	return v.clusterRuns(clusters, entry.size), nil
+}
 
-		if flag {
-			bar.Add(1)
+func (v *exfatVolume) clusterRuns(clusters []uint32, size int64) []run {
+	var runs []run
+	remaining := size
+	for _, cluster := range clusters {
+		if remaining <= 0 {
+			break
 		}
+		dataRun := run{start: v.clusterOffset(cluster), size: min(v.clusterSize, remaining)}
+		remaining -= dataRun.size
+		runs = appendRun(runs, dataRun)
 	}
+	return runs
+}
 
-	err = storeIndexedFiles(idxmap, pfile.GetDB(), batch, idxChan)
+// recoverDeleted lists a deleted file, nil when none of its clusters are still free. Deleting a
+// file only clears its bits in the allocation bitmap, so a contiguous file is read back
+// from its first cluster while a fragmented one is read from whatever its FAT chain still
+// says, or assumed contiguous once the chain is gone. The recovery confidence is the share
+// of its clusters nothing has been allocated to since, lowered for a guessed layout or an
+// entry set that no longer matches its checksum
+func (v *exfatVolume) recoverDeleted(entry exfatEntry, bitmap []byte) (*structs.IngestRange, error) {
+	count := (entry.size + v.clusterSize - 1) / v.clusterSize
+	clusters, err := v.clusters(entry.cluster, entry.size, entry.noFatChain)
 	if err != nil {
-		idxChan <- err
+		return nil, err
 	}
+	if int64(len(clusters)) < count {
+		clusters, err = v.clusters(entry.cluster, entry.size, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if int64(len(clusters)) < count {
+		return nil, nil
+	}
+	clusters = clusters[:count]
 
-	err = batch.Flush()
+	var free int
+	for _, cluster := range clusters {
+		if !v.allocated(bitmap, cluster) {
+			free++
+		}
+	}
+	if free == 0 {
+		return nil, nil
+	}
+
+	confidence := float64(free) / float64(len(clusters))
+	if !entry.noFatChain {
+		confidence /= 2
+	}
+	if !entry.intact {
+		confidence /= 2
+	}
+
+	irange := newIngestRange(entry.name, v.clusterRuns(clusters, entry.size), entry.size)
+	irange.Deleted = true
+	irange.RecoveryConfidence = confidence
+	return irange, nil
+}
+
+// allocationBitmap returns the bitmap of clusters in use, the first one listed in the
+// root directory on a volume that keeps two. It is nil when the root directory lists none
+func (v *exfatVolume) allocationBitmap() ([]byte, error) {
+	clusters, err := v.clusters(v.rootCluster, 0, false)
 	if err != nil {
-		idxChan <- err
+		return nil, err
 	}
-	if flag {
-		bar.Finish()
+	root, err := v.readClusters(clusters)
+	if err != nil {
+		return nil, err
 	}
 
-	pchan := make(chan error)
-	go store.Store(pfile, pchan)
-	idxChan <- <-pchan
+	for offset := 0; offset+exfatDirEntrySize <= len(root) && root[offset] != 0; offset += exfatDirEntrySize {
+		if root[offset] != exfatEntryBitmap {
+			continue
+		}
+		first := binary.LittleEndian.Uint32(root[offset+20:])
+		size := binary.LittleEndian.Uint64(root[offset+24:])
+		clusters, err := v.clusters(first, 0, false)
+		if err != nil {
+			return nil, err
+		}
+		bitmap, err := v.readClusters(clusters)
+		if err != nil {
+			return nil, err
+		}
+		return bitmap[:min(size, uint64(len(bitmap)))], nil
+	}
+	return nil, nil
 }
 
-func checkChannel(idxChan chan error) bool {
-	select {
-	case <-idxChan:
+// allocated reports whether cluster is in use, clusters past the end of the bitmap count as used
+func (v *exfatVolume) allocated(bitmap []byte, cluster uint32) bool {
+	index := cluster - exfatFirstCluster
+	if int(index/8) >= len(bitmap) {
 		return true
-	default:
-		return false
 	}
+	return bitmap[index/8]&(1<<(index%8)) != 0
 }
 
-func getStartOffset(pfileStart uint64) uint64 {
-	if pfileStart == 0 {
-		return 0
+func (v *exfatVolume) readClusters(clusters []uint32) ([]byte, error) {
+	data := make([]byte, int64(len(clusters))*v.clusterSize)
+	for index, cluster := range clusters {
+		_, err := v.r.ReadAt(data[int64(index)*v.clusterSize:int64(index+1)*v.clusterSize], v.clusterOffset(cluster))
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
 	}
-	return uint64(pfileStart) / libxfat.SECTOR_SIZE
+	return data, nil
 }
 
-func parsEXFAT(fhandle *os.File, size int64) []structs.PartitionFile {
-	var partition structs.PartitionFile
-	partition.Start = 0
-	partition.Size = size
-	_, err := libxfat.New(fhandle, true)
+// walk returns every entry set of the volume, directories are read breadth first from the root
+func (v *exfatVolume) walk() ([]exfatEntry, error) {
+	clusters, err := v.clusters(v.rootCluster, 0, false)
 	if err != nil {
-		return nil
+		return nil, err
+	}
+	data, err := v.readClusters(clusters)
+	if err != nil {
+		return nil, err
 	}
-	return []structs.PartitionFile{partition}
-}
-
-func storeIndexedFiles(idxmap map[string]structs.IndexedFile, db *badger.DB, batch *badger.WriteBatch, idxChan chan error) error {
-	var pflag bool
-	total := int64(len(idxmap))
-	bar := progressbar.Default(total, "indexing files")
-	bar.Clear()
 
-	for ihash, newIdxfile := range idxmap {
-		delete(idxmap, ihash)
-		if !pflag {
-			pflag = checkChannel(idxChan)
-			if pflag {
-				bar.Set(1)
-			}
-		}
+	var entries []exfatEntry
+	visited := map[uint32]struct{}{v.rootCluster: {}}
+	dirEntries := parseEXFATDir(data)
+	for len(dirEntries) > 0 {
+		entries = append(entries, dirEntries...)
 
-		id := util.AppendToBytesSlice(cnst.IdxFileNamespace, ihash)
-		oldIdxFile, err := dbio.GetIndexedFile(id, db)
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			err = dbio.SetIndexedFile(id, newIdxfile, batch)
-			if err != nil {
-				return err
+		var subEntries []exfatEntry
+		for _, entry := range dirEntries {
+			if entry.deleted || !entry.isDir() {
+				continue
 			}
-			continue
-		}
-		if err != nil && err != badger.ErrKeyNotFound {
-			return err
-		}
-
-		flag := true
-		if len(newIdxfile.Names) < len(oldIdxFile.Names) {
-			for newName := range newIdxfile.Names {
-				if _, ok := oldIdxFile.Names[newName]; !ok {
-					oldIdxFile.Names[newName] = struct{}{}
-					flag = false
-				}
+			if _, ok := visited[entry.cluster]; ok {
+				continue
 			}
+			visited[entry.cluster] = struct{}{}
 
-			if flag {
-				continue
+			// no directory is larger than the format allows
+			clusters, err := v.clusters(entry.cluster, min(entry.size, exfatMaxDirSize), entry.noFatChain)
+			if err != nil {
+				return nil, err
 			}
-			err = dbio.SetIndexedFile(id, oldIdxFile, batch)
+			data, err := v.readClusters(clusters[:min(int64(len(clusters)), exfatMaxDirSize/v.clusterSize)])
 			if err != nil {
-				return err
+				return nil, err
 			}
+			subEntries = append(subEntries, parseEXFATDir(data)...)
+		}
+		dirEntries = subEntries
+	}
+	return entries, nil
+}
 
+// parseEXFATDir decodes the file entry sets of a directory. Deleted sets are kept, they
+// look the same as live ones with the in use bit of every entry cleared
+func parseEXFATDir(data []byte) []exfatEntry {
+	var entries []exfatEntry
+	for offset := 0; offset+exfatDirEntrySize <= len(data) && data[offset] != 0; offset += exfatDirEntrySize {
+		etype := data[offset]
+		if etype&^exfatEntryInUse != exfatEntryFile&^exfatEntryInUse {
 			continue
 		}
 
-		for oldName := range oldIdxFile.Names {
-			if _, ok := newIdxfile.Names[oldName]; !ok {
-				newIdxfile.Names[oldName] = struct{}{}
-				flag = false
-			}
+		secondaryCount := int(data[offset+1])
+		if secondaryCount < 2 || offset+(secondaryCount+1)*exfatDirEntrySize > len(data) {
+			continue
 		}
-		if flag {
+		deleted := etype&exfatEntryInUse == 0
+		stream := data[offset+exfatDirEntrySize : offset+2*exfatDirEntrySize]
+		if stream[0] != exfatEntryStream&^deletedMask(deleted) {
 			continue
 		}
-		err = dbio.SetIndexedFile(id, newIdxfile, batch)
-		if err != nil {
-			return err
+
+		entry := exfatEntry{
+			attr:       binary.LittleEndian.Uint16(data[offset+4:]),
+			cluster:    binary.LittleEndian.Uint32(stream[20:]),
+			size:       int64(binary.LittleEndian.Uint64(stream[24:])),
+			noFatChain: stream[1]&exfatNoFatChain != 0,
+			deleted:    deleted,
+			intact:     exfatSetChecksum(data[offset:offset+(secondaryCount+1)*exfatDirEntrySize], deleted) == binary.LittleEndian.Uint16(data[offset+2:]),
 		}
 
-		if flag {
-			bar.Add(1)
+		nameLen := int(stream[3])
+		var name []uint16
+		for index := 2; index <= secondaryCount && len(name) < nameLen; index++ {
+			nameEntry := data[offset+index*exfatDirEntrySize : offset+(index+1)*exfatDirEntrySize]
+			if nameEntry[0] != exfatEntryName&^deletedMask(deleted) {
+				break
+			}
+			for char := 0; char < exfatNameChars && len(name) < nameLen; char++ {
+				name = append(name, binary.LittleEndian.Uint16(nameEntry[2+char*2:]))
+			}
 		}
+		entry.name = string(utf16.Decode(name))
+
+		entries = append(entries, entry)
+		offset += secondaryCount * exfatDirEntrySize
 	}
+	return entries
+}
+
+// exfatSetChecksum computes the checksum of an entry set, the in use bits of a deleted set
+// are put back first as they were set when the checksum was written
+func exfatSetChecksum(set []byte, deleted bool) uint16 {
+	var checksum uint16
+	for index, value := range set {
+		if index == 2 || index == 3 {
+			continue
+		}
+		if index%exfatDirEntrySize == 0 {
+			value |= deletedMask(deleted)
+		}
+		checksum = ((checksum << 15) | (checksum >> 1)) + uint16(value)
+	}
+	return checksum
+}
 
-	if pflag {
-		bar.Finish()
+func deletedMask(deleted bool) byte {
+	if deleted {
+		return exfatEntryInUse
 	}
-	return nil
+	return 0
 }
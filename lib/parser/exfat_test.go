@@ -0,0 +1,295 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"indicer/lib/cnst"
+	"testing"
+	"unicode/utf16"
+)
+
+const (
+	testEXFATSector     = 512
+	testEXFATCluster    = 2 * testEXFATSector
+	testEXFATClusters   = 60
+	testEXFATHeapSector = 4
+	testEXFATRoot       = 4
+	testEXFATSubDir     = 24
+)
+
+// testEXFATFile is a file entry set of a test volume
+type testEXFATFile struct {
+	name       string
+	attr       uint16
+	cluster    uint32
+	size       int64
+	noFatChain bool
+	deleted    bool
+}
+
+// exfatTestFiles are the entry sets of the root directory of the test volume. Deleted sets
+// cover a fully free contiguous file, one half reallocated, one wholly reallocated and one
+// whose FAT chain is still there
+var exfatTestFiles = []testEXFATFile{
+	{name: "live.txt", cluster: 5, size: 700, noFatChain: true},
+	{name: "frag.bin", cluster: 8, size: 2 * testEXFATCluster},
+	{name: "gone.txt", cluster: 12, size: 600, noFatChain: true, deleted: true},
+	{name: "half.txt", cluster: 14, size: 2 * testEXFATCluster, noFatChain: true, deleted: true},
+	{name: "taken.txt", cluster: 16, size: 100, noFatChain: true, deleted: true},
+	{name: "chain.bin", cluster: 20, size: 2 * testEXFATCluster, deleted: true},
+	{name: "dir", attr: exfatAttrDir, cluster: testEXFATSubDir, size: testEXFATCluster, noFatChain: true},
+}
+
+// exfatTestImage builds a small exFAT volume with two sector clusters, its allocation bitmap
+// in cluster 2, the root directory in cluster 4 and /dir/inner.txt in a subdirectory. edit can
+// change the image afterwards
+func exfatTestImage(edit func(image []byte)) []byte {
+	image := make([]byte, testEXFATHeapSector*testEXFATSector+testEXFATClusters*testEXFATCluster)
+	vbr := image[:testEXFATSector]
+	copy(vbr[3:], exfatSignature)
+	binary.LittleEndian.PutUint32(vbr[0x50:], 1)
+	binary.LittleEndian.PutUint32(vbr[0x58:], testEXFATHeapSector)
+	binary.LittleEndian.PutUint32(vbr[0x5C:], testEXFATClusters)
+	binary.LittleEndian.PutUint32(vbr[0x60:], testEXFATRoot)
+	vbr[0x6C] = 9
+	vbr[0x6D] = 1
+	vbr[bootSectorSigIndex] = 0x55
+	vbr[bootSectorSigIndex+1] = 0xAA
+
+	fat := image[testEXFATSector:]
+	for cluster, next := range map[uint32]uint32{2: 0xFFFFFFFF, testEXFATRoot: 0xFFFFFFFF, 8: 10, 10: 0xFFFFFFFF, 20: 22, 22: 0xFFFFFFFF} {
+		binary.LittleEndian.PutUint32(fat[cluster*4:], next)
+	}
+
+	bitmap := image[exfatTestClusterOffset(2):]
+	for _, cluster := range []uint32{2, 3, testEXFATRoot, 5, 6, 8, 10, 15, 16, testEXFATSubDir, 25} {
+		bitmap[(cluster-exfatFirstCluster)/8] |= 1 << ((cluster - exfatFirstCluster) % 8)
+	}
+
+	root := make([]byte, exfatDirEntrySize)
+	root[0] = exfatEntryBitmap
+	binary.LittleEndian.PutUint32(root[20:], 2)
+	binary.LittleEndian.PutUint64(root[24:], (testEXFATClusters+7)/8)
+	for _, file := range exfatTestFiles {
+		root = append(root, exfatTestSet(file)...)
+	}
+	copy(image[exfatTestClusterOffset(testEXFATRoot):], root)
+	copy(image[exfatTestClusterOffset(testEXFATSubDir):], exfatTestSet(testEXFATFile{name: "inner.txt", cluster: 25, size: 5, noFatChain: true}))
+
+	if edit != nil {
+		edit(image)
+	}
+	return image
+}
+
+func exfatTestClusterOffset(cluster uint32) int64 {
+	return int64(testEXFATHeapSector*testEXFATSector) + int64(cluster-exfatFirstCluster)*testEXFATCluster
+}
+
+// exfatTestSet lays out the file, stream and name entries of a file, a deleted set keeps
+// the checksum it was written with and has the in use bit of every entry cleared
+func exfatTestSet(file testEXFATFile) []byte {
+	name := utf16.Encode([]rune(file.name))
+	nameEntries := (len(name) + exfatNameChars - 1) / exfatNameChars
+	set := make([]byte, (2+nameEntries)*exfatDirEntrySize)
+
+	set[0] = exfatEntryFile
+	set[1] = byte(1 + nameEntries)
+	binary.LittleEndian.PutUint16(set[4:], file.attr)
+
+	stream := set[exfatDirEntrySize:]
+	stream[0] = exfatEntryStream
+	stream[1] = 0x01
+	if file.noFatChain {
+		stream[1] |= exfatNoFatChain
+	}
+	stream[3] = byte(len(name))
+	binary.LittleEndian.PutUint32(stream[20:], file.cluster)
+	binary.LittleEndian.PutUint64(stream[24:], uint64(file.size))
+
+	for index, char := range name {
+		entry := set[(2+index/exfatNameChars)*exfatDirEntrySize:]
+		entry[0] = exfatEntryName
+		binary.LittleEndian.PutUint16(entry[2+index%exfatNameChars*2:], char)
+	}
+
+	binary.LittleEndian.PutUint16(set[2:], exfatSetChecksum(set, false))
+	if file.deleted {
+		for offset := 0; offset < len(set); offset += exfatDirEntrySize {
+			set[offset] &^= exfatEntryInUse
+		}
+	}
+	return set
+}
+
+// exfatTestSetOffset is where the entry set of exfatTestFiles[index] starts in the image
+func exfatTestSetOffset(index int) int64 {
+	offset := exfatTestClusterOffset(testEXFATRoot) + exfatDirEntrySize
+	for _, file := range exfatTestFiles[:index] {
+		offset += int64(len(exfatTestSet(file)))
+	}
+	return offset
+}
+
+// testDeleted is what a listed deleted file is expected to look like, last is where its last run starts
+type testDeleted struct {
+	start      int64
+	last       int64
+	confidence float64
+}
+
+func TestListEXFAT(t *testing.T) {
+	tests := []struct {
+		name    string
+		deleted bool
+		edit    func(image []byte)
+		live    []string
+		found   map[string]testDeleted
+	}{
+		{
+			name: "live files only",
+			live: []string{"live.txt", "frag.bin", "inner.txt"},
+		},
+		{
+			name:    "deleted files",
+			deleted: true,
+			live:    []string{"live.txt", "frag.bin", "inner.txt"},
+			found: map[string]testDeleted{
+				"gone.txt":  {start: exfatTestClusterOffset(12), last: exfatTestClusterOffset(12), confidence: 1},
+				"half.txt":  {start: exfatTestClusterOffset(14), last: exfatTestClusterOffset(14), confidence: 0.5},
+				"chain.bin": {start: exfatTestClusterOffset(20), last: exfatTestClusterOffset(22), confidence: 0.5},
+			},
+		},
+		{
+			name:    "deleted set that no longer matches its checksum",
+			deleted: true,
+			edit: func(image []byte) {
+				image[exfatTestSetOffset(2)+2]++
+			},
+			live: []string{"live.txt", "frag.bin", "inner.txt"},
+			found: map[string]testDeleted{
+				"gone.txt":  {start: exfatTestClusterOffset(12), last: exfatTestClusterOffset(12), confidence: 0.5},
+				"half.txt":  {start: exfatTestClusterOffset(14), last: exfatTestClusterOffset(14), confidence: 0.5},
+				"chain.bin": {start: exfatTestClusterOffset(20), last: exfatTestClusterOffset(22), confidence: 0.5},
+			},
+		},
+		{
+			name:    "FAT chain of a deleted file gone",
+			deleted: true,
+			edit: func(image []byte) {
+				binary.LittleEndian.PutUint32(image[testEXFATSector+20*4:], 0)
+			},
+			live: []string{"live.txt", "frag.bin", "inner.txt"},
+			found: map[string]testDeleted{
+				"gone.txt":  {start: exfatTestClusterOffset(12), last: exfatTestClusterOffset(12), confidence: 1},
+				"half.txt":  {start: exfatTestClusterOffset(14), last: exfatTestClusterOffset(14), confidence: 0.5},
+				"chain.bin": {start: exfatTestClusterOffset(20), last: exfatTestClusterOffset(20), confidence: 0.5},
+			},
+		},
+		{
+			name:    "deleted file larger than the volume",
+			deleted: true,
+			edit: func(image []byte) {
+				binary.LittleEndian.PutUint64(image[exfatTestSetOffset(2)+exfatDirEntrySize+24:], 0x9200770000000000)
+			},
+			live: []string{"live.txt", "frag.bin", "inner.txt"},
+			found: map[string]testDeleted{
+				"half.txt":  {start: exfatTestClusterOffset(14), last: exfatTestClusterOffset(14), confidence: 0.5},
+				"chain.bin": {start: exfatTestClusterOffset(20), last: exfatTestClusterOffset(22), confidence: 0.5},
+			},
+		},
+		{
+			name:    "allocation bitmap size past the end of int64",
+			deleted: true,
+			edit: func(image []byte) {
+				binary.LittleEndian.PutUint64(image[exfatTestClusterOffset(testEXFATRoot)+24:], 1<<63)
+			},
+			live: []string{"live.txt", "frag.bin", "inner.txt"},
+			found: map[string]testDeleted{
+				"gone.txt":  {start: exfatTestClusterOffset(12), last: exfatTestClusterOffset(12), confidence: 1},
+				"half.txt":  {start: exfatTestClusterOffset(14), last: exfatTestClusterOffset(14), confidence: 0.5},
+				"chain.bin": {start: exfatTestClusterOffset(20), last: exfatTestClusterOffset(22), confidence: 0.5},
+			},
+		},
+		{
+			name:    "no allocation bitmap",
+			deleted: true,
+			edit: func(image []byte) {
+				image[exfatTestClusterOffset(testEXFATRoot)] = 0x01
+			},
+			live: []string{"live.txt", "frag.bin", "inner.txt"},
+		},
+	}
+
+	defer func(deleted bool) { cnst.DELETEDOPT = deleted }(cnst.DELETEDOPT)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cnst.DELETEDOPT = test.deleted
+			entries, err := ListEXFAT(bytes.NewReader(exfatTestImage(test.edit)), testPartition(0))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var live []string
+			found := make(map[string]testDeleted)
+			for _, entry := range entries {
+				switch {
+				case entry.Space != "":
+				case entry.Deleted:
+					runs := entry.Runs()
+					found[entry.Name] = testDeleted{start: entry.Start, last: runs[len(runs)-1].Start, confidence: entry.RecoveryConfidence}
+				default:
+					live = append(live, entry.Name)
+				}
+			}
+			if len(live) != len(test.live) {
+				t.Fatalf("got live files %v, want %v", live, test.live)
+			}
+			for index := range live {
+				if live[index] != test.live[index] {
+					t.Errorf("got live files %v, want %v", live, test.live)
+				}
+			}
+			if len(found) != len(test.found) {
+				t.Fatalf("got deleted files %v, want %v", found, test.found)
+			}
+			for name, want := range test.found {
+				if found[name] != want {
+					t.Errorf("%s: got %+v, want %+v", name, found[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestListEXFATClustersPastThePartition(t *testing.T) {
+	image := exfatTestImage(func(image []byte) {
+		binary.LittleEndian.PutUint32(image[0x5C:], 0x4000001)
+		binary.LittleEndian.PutUint64(image[exfatTestSetOffset(6)+exfatDirEntrySize+24:], 1<<40)
+	})
+	entries, err := ListEXFAT(bytes.NewReader(image), testPartition(int64(len(image))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var live int
+	for _, entry := range entries {
+		if entry.Space == "" {
+			live++
+		}
+		if entry.Start+entry.Size > int64(len(image)) {
+			t.Errorf("%s ends at %d past the %d byte partition", entry.Name, entry.Start+entry.Size, len(image))
+		}
+	}
+	if live != 3 {
+		t.Errorf("got %d live files, want 3", live)
+	}
+}
+
+func FuzzListEXFAT(f *testing.F) {
+	defer func(deleted bool) { cnst.DELETEDOPT = deleted }(cnst.DELETEDOPT)
+	cnst.DELETEDOPT = true
+	f.Add(exfatTestImage(nil))
+	f.Fuzz(func(t *testing.T, image []byte) {
+		ListEXFAT(bytes.NewReader(image), testPartition(int64(len(image))))
+	})
+}
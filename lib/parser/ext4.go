@@ -0,0 +1,596 @@
+package parser
+
+import (
+	"cmp"
+	"encoding/binary"
+	"indicer/lib/cnst"
+	"indicer/lib/structs"
+	"io"
+	"path"
+	"slices"
+	"time"
+)
+
+const (
+	ext4SuperblockOffset    = 1024
+	ext4SuperblockSize      = 1024
+	ext4Magic               = 0xEF53
+	ext4MaxLogBlockSize     = 6
+	ext4MaxBlockSize        = ext4SuperblockSize << ext4MaxLogBlockSize
+	ext4RootInode           = 2
+	ext4OldInodeSize        = 128
+	ext4OldDescSize         = 32
+	ext4MinDescSize64       = 64
+	ext4Incompat64Bit       = 0x80
+	ext4IncompatMetaBG      = 0x10
+	ext4RoCompatSparseSuper = 0x01
+	ext4RoCompatBigalloc    = 0x200
+	ext4GroupInodeUninit    = 0x01
+	ext4GroupBlockUninit    = 0x02
+	ext4ModeMask            = 0xF000
+	ext4ModeFile            = 0x8000
+	ext4ModeDir             = 0x4000
+	ext4FlagEncrypt         = 0x800
+	ext4FlagExtents         = 0x80000
+	ext4FlagInlineData      = 0x10000000
+	ext4BlockOffset         = 0x28
+	ext4InlineSize          = 60
+	ext4InlineDirHeader     = 4
+	ext4DirectBlocks        = 12
+	ext4IndirectLevels      = 3
+	ext4ExtentMagic         = 0xF30A
+	ext4ExtentSize          = 12
+	ext4ExtentMaxInit       = 32768
+	ext4MaxExtentDepth      = 5
+	ext4DirentHeader        = 8
+	ext4ExtraIsizeOffset    = 0x80
+	ext4EpochMask           = 0x3
+)
+
+// ext4Volume is an ext2, ext3 or ext4 file system found at start of the evidence,
+// all offsets are absolute and blocks are numbered from start
+type ext4Volume struct {
+	r              io.ReaderAt
+	start          int64
+	blockSize      int64
+	blockCount     uint64
+	firstDataBlock uint64
+	blocksPerGroup uint64
+	inodesPerGroup uint64
+	inodeCount     uint64
+	inodeSize      int64
+	descSize       int64
+	incompat       uint32
+	roCompat       uint32
+	firstMetaBG    uint64
+}
+
+// ext4Inode is the part of an inode needed to find its data, block is the raw i_block
+// area that holds the extent tree, the block map or inline data
+type ext4Inode struct {
+	mode        uint16
+	flags       uint32
+	size        int64
+	block       []byte
+	blockOffset int64
+	times       structs.FileTimes
+}
+
+func (i ext4Inode) isDir() bool {
+	return i.mode&ext4ModeMask == ext4ModeDir
+}
+
+func (i ext4Inode) isFile() bool {
+	return i.mode&ext4ModeMask == ext4ModeFile
+}
+
+// ext4Extent maps length blocks of a file starting at logical to the blocks starting at start
+type ext4Extent struct {
+	logical uint64
+	start   uint64
+	length  uint64
+	uninit  bool
+}
+
+type ext4Dirent struct {
+	inode uint32
+	name  string
+}
+
+func isEXT4(r io.ReaderAt, start int64) bool {
+	_, err := openEXT4(r, start)
+	return err == nil
+}
+
+// ListEXT4 returns the regular files of the ext2/3/4 volume at partition named by their full
+// path, with their timestamps and the runs of fragmented files. Their hashes are filled in by
+// the ingest pass. Sparse and encrypted files are left out. File slack, free blocks and
+// volume slack are listed after the files
+func ListEXT4(r io.ReaderAt, partition structs.PartitionFile) ([]*structs.IngestRange, error) {
+	volume, err := openEXT4(r, partition.Start)
+	if err != nil {
+		return nil, cnst.ErrIncompatibleFileSystem
+	}
+
+	var entries []*structs.IngestRange
+	err = volume.walk(func(name string, inode ext4Inode) {
+		runs, ok := volume.runs(inode)
+		if !ok || inode.size == 0 {
+			return
+		}
+		irange := newIngestRange(name, runs, inode.size)
+		irange.Times = inode.times
+		entries = append(entries, irange)
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(entries, func(a, b *structs.IngestRange) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
+
+	free, err := volume.freeRuns()
+	if err != nil {
+		return nil, err
+	}
+	end := volume.blockOffset(volume.blockCount)
+	return append(entries, spaceRanges(entries, free, volume.start, volume.blockSize, end, partition)...), nil
+}
+
+// freeRuns returns the runs of the blocks that are clear in the block bitmaps. Groups whose
+// bitmap was never written are left out, as are volumes whose bitmaps count clusters of blocks
+func (v *ext4Volume) freeRuns() ([]run, error) {
+	if v.roCompat&ext4RoCompatBigalloc != 0 {
+		return nil, nil
+	}
+
+	var runs []run
+	desc := make([]byte, v.descSize)
+	groups := (v.blockCount - v.firstDataBlock + v.blocksPerGroup - 1) / v.blocksPerGroup
+	for group := uint64(0); group < groups; group++ {
+		_, err := v.r.ReadAt(desc, v.groupDescOffset(group))
+		if err != nil {
+			return nil, err
+		}
+		if binary.LittleEndian.Uint16(desc[0x12:])&ext4GroupBlockUninit != 0 {
+			continue
+		}
+		block := uint64(binary.LittleEndian.Uint32(desc[0x00:]))
+		if v.descSize >= ext4MinDescSize64 {
+			block |= uint64(binary.LittleEndian.Uint32(desc[0x20:])) << 32
+		}
+		if !v.validBlocks(block, 1) {
+			continue
+		}
+		bitmap, err := v.readBlock(block)
+		if err != nil {
+			return nil, err
+		}
+
+		first := v.firstDataBlock + group*v.blocksPerGroup
+		for _, freeRun := range freeRuns(bitmap, min(v.blocksPerGroup, v.blockCount-first), v.blockOffset(first), v.blockSize) {
+			runs = appendRun(runs, freeRun)
+		}
+	}
+	return runs, nil
+}
+
+func openEXT4(r io.ReaderAt, start int64) (*ext4Volume, error) {
+	sb := make([]byte, ext4SuperblockSize)
+	_, err := r.ReadAt(sb, start+ext4SuperblockOffset)
+	if err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint16(sb[0x38:]) != ext4Magic {
+		return nil, cnst.ErrIncompatibleFileSystem
+	}
+
+	logBlockSize := binary.LittleEndian.Uint32(sb[0x18:])
+	if logBlockSize > ext4MaxLogBlockSize {
+		return nil, cnst.ErrIncompatibleFileSystem
+	}
+	volume := &ext4Volume{
+		r:              r,
+		start:          start,
+		blockSize:      ext4SuperblockSize << logBlockSize,
+		blockCount:     uint64(binary.LittleEndian.Uint32(sb[0x04:])),
+		firstDataBlock: uint64(binary.LittleEndian.Uint32(sb[0x14:])),
+		blocksPerGroup: uint64(binary.LittleEndian.Uint32(sb[0x20:])),
+		inodesPerGroup: uint64(binary.LittleEndian.Uint32(sb[0x28:])),
+		inodeCount:     uint64(binary.LittleEndian.Uint32(sb[0x00:])),
+		inodeSize:      ext4OldInodeSize,
+		descSize:       ext4OldDescSize,
+		incompat:       binary.LittleEndian.Uint32(sb[0x60:]),
+		roCompat:       binary.LittleEndian.Uint32(sb[0x64:]),
+		firstMetaBG:    uint64(binary.LittleEndian.Uint32(sb[0x104:])),
+	}
+	// revision 0 file systems have fixed size inodes
+	if binary.LittleEndian.Uint32(sb[0x4C:]) > 0 {
+		volume.inodeSize = int64(binary.LittleEndian.Uint16(sb[0x58:]))
+	}
+	if volume.incompat&ext4Incompat64Bit != 0 {
+		volume.blockCount |= uint64(binary.LittleEndian.Uint32(sb[0x150:])) << 32
+		volume.descSize = int64(binary.LittleEndian.Uint16(sb[0xFE:]))
+		if volume.descSize < ext4MinDescSize64 {
+			return nil, cnst.ErrIncompatibleFileSystem
+		}
+	}
+
+	if volume.blocksPerGroup == 0 || volume.inodesPerGroup == 0 || volume.blockCount <= volume.firstDataBlock ||
+		volume.inodeSize < ext4OldInodeSize || volume.inodeSize > volume.blockSize || volume.inodeSize&(volume.inodeSize-1) != 0 ||
+		volume.descSize > volume.blockSize || volume.descSize&(volume.descSize-1) != 0 {
+		return nil, cnst.ErrIncompatibleFileSystem
+	}
+	return volume, nil
+}
+
+func (v *ext4Volume) blockOffset(block uint64) int64 {
+	return v.start + int64(block)*v.blockSize
+}
+
+func (v *ext4Volume) validBlocks(block, count uint64) bool {
+	return block != 0 && block < v.blockCount && count <= v.blockCount-block
+}
+
+func (v *ext4Volume) readBlock(block uint64) ([]byte, error) {
+	if !v.validBlocks(block, 1) {
+		return nil, cnst.ErrIncompatibleFileSystem
+	}
+	data := make([]byte, v.blockSize)
+	_, err := v.r.ReadAt(data, v.blockOffset(block))
+	return data, err
+}
+
+// hasSuperblock reports whether a block group starts with a superblock backup, with
+// sparse superblocks only groups 0, 1 and powers of 3, 5 and 7 have one
+func (v *ext4Volume) hasSuperblock(group uint64) bool {
+	if v.roCompat&ext4RoCompatSparseSuper == 0 || group <= 1 {
+		return true
+	}
+	for _, base := range []uint64{3, 5, 7} {
+		power := base
+		for power < group {
+			power *= base
+		}
+		if power == group {
+			return true
+		}
+	}
+	return false
+}
+
+// groupDescOffset finds the descriptor of a block group. They follow the superblock,
+// except with meta_bg, where each meta group keeps its own in its first group
+func (v *ext4Volume) groupDescOffset(group uint64) int64 {
+	perBlock := uint64(v.blockSize / v.descSize)
+	metaGroup := group / perBlock
+	if v.incompat&ext4IncompatMetaBG != 0 && metaGroup >= v.firstMetaBG {
+		first := metaGroup * perBlock
+		block := v.firstDataBlock + first*v.blocksPerGroup
+		if v.hasSuperblock(first) {
+			block++
+		}
+		return v.blockOffset(block) + int64(group%perBlock)*v.descSize
+	}
+	return v.blockOffset(v.firstDataBlock+1) + int64(group)*v.descSize
+}
+
+func (v *ext4Volume) readInode(number uint64) (ext4Inode, error) {
+	var inode ext4Inode
+	if number == 0 || number > v.inodeCount {
+		return inode, cnst.ErrIncompatibleFileSystem
+	}
+	group := (number - 1) / v.inodesPerGroup
+	index := (number - 1) % v.inodesPerGroup
+
+	desc := make([]byte, v.descSize)
+	_, err := v.r.ReadAt(desc, v.groupDescOffset(group))
+	if err != nil {
+		return inode, err
+	}
+	// the inode table of a group that was never used holds no inodes, it may not even be zeroed
+	if binary.LittleEndian.Uint16(desc[0x12:])&ext4GroupInodeUninit != 0 {
+		return inode, cnst.ErrIncompatibleFileSystem
+	}
+	table := uint64(binary.LittleEndian.Uint32(desc[0x08:]))
+	if v.descSize >= ext4MinDescSize64 {
+		table |= uint64(binary.LittleEndian.Uint32(desc[0x28:])) << 32
+	}
+	if !v.validBlocks(table, 1) {
+		return inode, cnst.ErrIncompatibleFileSystem
+	}
+
+	offset := v.blockOffset(table) + int64(index)*v.inodeSize
+	raw := make([]byte, v.inodeSize)
+	_, err = v.r.ReadAt(raw, offset)
+	if err != nil {
+		return inode, err
+	}
+
+	inode = ext4Inode{
+		mode:        binary.LittleEndian.Uint16(raw[0x00:]),
+		flags:       binary.LittleEndian.Uint32(raw[0x20:]),
+		size:        int64(uint64(binary.LittleEndian.Uint32(raw[0x04:])) | uint64(binary.LittleEndian.Uint32(raw[0x6C:]))<<32),
+		block:       raw[ext4BlockOffset : ext4BlockOffset+ext4InlineSize],
+		blockOffset: offset + ext4BlockOffset,
+	}
+	extra := 0
+	if len(raw) > ext4ExtraIsizeOffset {
+		extra = ext4ExtraIsizeOffset + int(binary.LittleEndian.Uint16(raw[ext4ExtraIsizeOffset:]))
+	}
+	inode.times = structs.FileTimes{
+		Modified: ext4Time(raw, 0x10, 0x88, extra),
+		Accessed: ext4Time(raw, 0x08, 0x8C, extra),
+		Changed:  ext4Time(raw, 0x0C, 0x84, extra),
+		Created:  ext4Time(raw, 0x90, 0x94, extra),
+	}
+	return inode, nil
+}
+
+// ext4Time reads a timestamp, large inodes extend it with an extra field holding the
+// nanoseconds and two more bits of seconds. Fields past the extra inode size are not there
+func ext4Time(raw []byte, secondsOffset, extraOffset, extraEnd int) time.Time {
+	if secondsOffset+4 > len(raw) || (secondsOffset >= ext4ExtraIsizeOffset && secondsOffset+4 > extraEnd) {
+		return time.Time{}
+	}
+	seconds := int64(int32(binary.LittleEndian.Uint32(raw[secondsOffset:])))
+	var nanoseconds int64
+	if extraOffset+4 <= extraEnd && extraOffset+4 <= len(raw) {
+		extra := binary.LittleEndian.Uint32(raw[extraOffset:])
+		seconds += int64(extra&ext4EpochMask) << 32
+		nanoseconds = int64(extra >> 2)
+	}
+	if seconds == 0 && nanoseconds == 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, nanoseconds).UTC()
+}
+
+// runs returns the runs of the data of an inode in file order cut to its size, merging
+// runs that follow each other on disk. ok is false when the data is not stored as is
+func (v *ext4Volume) runs(inode ext4Inode) ([]run, bool) {
+	if inode.flags&ext4FlagEncrypt != 0 || inode.size < 0 {
+		return nil, false
+	}
+	// inline data past the inode's block area is kept in an extended attribute
+	if inode.flags&ext4FlagInlineData != 0 {
+		if inode.size > ext4InlineSize {
+			return nil, false
+		}
+		return []run{{start: inode.blockOffset, size: inode.size}}, true
+	}
+
+	count := uint64((inode.size + v.blockSize - 1) / v.blockSize)
+	if count > v.blockCount {
+		return nil, false
+	}
+	var extents []ext4Extent
+	var ok bool
+	if inode.flags&ext4FlagExtents != 0 {
+		extents, ok = v.extents(inode.block, ext4MaxExtentDepth)
+	} else {
+		extents, ok = v.blockMap(inode.block, count)
+	}
+	if !ok {
+		return nil, false
+	}
+	slices.SortFunc(extents, func(a, b ext4Extent) int {
+		return cmp.Compare(a.logical, b.logical)
+	})
+
+	var runs []run
+	var next uint64
+	remaining := inode.size
+	for _, extent := range extents {
+		if remaining <= 0 {
+			break
+		}
+		// holes and preallocated blocks read as zeros, they are not stored as is
+		if extent.logical != next || extent.uninit || !v.validBlocks(extent.start, extent.length) {
+			return nil, false
+		}
+		next += extent.length
+
+		dataRun := run{start: v.blockOffset(extent.start), size: min(int64(extent.length)*v.blockSize, remaining)}
+		remaining -= dataRun.size
+		runs = appendRun(runs, dataRun)
+	}
+	if remaining > 0 {
+		return nil, false
+	}
+	return runs, true
+}
+
+// extents reads the extents of an extent tree node, index nodes are followed down to their
+// leaves. maxDepth only allows children deeper than their parent, so a corrupt tree cannot loop
+func (v *ext4Volume) extents(node []byte, maxDepth uint16) ([]ext4Extent, bool) {
+	if len(node) < ext4ExtentSize || binary.LittleEndian.Uint16(node[0:]) != ext4ExtentMagic {
+		return nil, false
+	}
+	count := int(binary.LittleEndian.Uint16(node[2:]))
+	depth := binary.LittleEndian.Uint16(node[6:])
+	if depth > maxDepth || ext4ExtentSize*(count+1) > len(node) {
+		return nil, false
+	}
+
+	var extents []ext4Extent
+	for index := range count {
+		entry := node[ext4ExtentSize*(index+1):]
+		if depth == 0 {
+			length := uint64(binary.LittleEndian.Uint16(entry[4:]))
+			extent := ext4Extent{
+				logical: uint64(binary.LittleEndian.Uint32(entry[0:])),
+				start:   uint64(binary.LittleEndian.Uint16(entry[6:]))<<32 | uint64(binary.LittleEndian.Uint32(entry[8:])),
+				length:  length,
+			}
+			if length > ext4ExtentMaxInit {
+				extent.length -= ext4ExtentMaxInit
+				extent.uninit = true
+			}
+			extents = append(extents, extent)
+			continue
+		}
+
+		leaf := uint64(binary.LittleEndian.Uint16(entry[8:]))<<32 | uint64(binary.LittleEndian.Uint32(entry[4:]))
+		child, err := v.readBlock(leaf)
+		if err != nil {
+			return nil, false
+		}
+		childExtents, ok := v.extents(child, depth-1)
+		if !ok {
+			return nil, false
+		}
+		extents = append(extents, childExtents...)
+	}
+	return extents, true
+}
+
+// blockMap reads the first count blocks of an ext2/ext3 style block map, made of direct
+// blocks followed by single, double and triple indirect blocks. A hole fails the read
+func (v *ext4Volume) blockMap(iblock []byte, count uint64) ([]ext4Extent, bool) {
+	blocks := make([]uint64, 0, count)
+	for index := 0; index < ext4DirectBlocks && uint64(len(blocks)) < count; index++ {
+		blocks = append(blocks, uint64(binary.LittleEndian.Uint32(iblock[index*4:])))
+	}
+	var ok bool
+	for level := 1; level <= ext4IndirectLevels && uint64(len(blocks)) < count; level++ {
+		pointer := uint64(binary.LittleEndian.Uint32(iblock[(ext4DirectBlocks+level-1)*4:]))
+		blocks, ok = v.indirectBlocks(pointer, level, blocks, count)
+		if !ok {
+			return nil, false
+		}
+	}
+
+	var extents []ext4Extent
+	for index, block := range blocks {
+		if block == 0 {
+			return nil, false
+		}
+		last := len(extents) - 1
+		if last >= 0 && extents[last].start+extents[last].length == block {
+			extents[last].length++
+			continue
+		}
+		extents = append(extents, ext4Extent{logical: uint64(index), start: block, length: 1})
+	}
+	return extents, true
+}
+
+func (v *ext4Volume) indirectBlocks(pointer uint64, level int, blocks []uint64, count uint64) ([]uint64, bool) {
+	data, err := v.readBlock(pointer)
+	if err != nil {
+		return nil, false
+	}
+	var ok bool
+	for offset := 0; offset+4 <= len(data) && uint64(len(blocks)) < count; offset += 4 {
+		block := uint64(binary.LittleEndian.Uint32(data[offset:]))
+		if level == 1 {
+			blocks = append(blocks, block)
+			continue
+		}
+		blocks, ok = v.indirectBlocks(block, level-1, blocks, count)
+		if !ok {
+			return nil, false
+		}
+	}
+	return blocks, true
+}
+
+// walk calls found with the full path of every regular file, going through the directories
+// from the root. Each directory is read once, so hard linked directories cannot loop
+func (v *ext4Volume) walk(found func(name string, inode ext4Inode)) error {
+	root, err := v.readInode(ext4RootInode)
+	if err != nil {
+		return err
+	}
+	if !root.isDir() {
+		return cnst.ErrIncompatibleFileSystem
+	}
+
+	type queued struct {
+		name  string
+		inode ext4Inode
+	}
+	visited := map[uint32]struct{}{ext4RootInode: {}}
+	queue := []queued{{"/", root}}
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue = queue[1:]
+
+		dirents, err := v.readDir(dir.inode)
+		if err != nil {
+			continue
+		}
+		for _, dirent := range dirents {
+			inode, err := v.readInode(uint64(dirent.inode))
+			if err != nil {
+				continue
+			}
+			name := path.Join(dir.name, dirent.name)
+			if inode.isDir() {
+				if _, ok := visited[dirent.inode]; !ok && inode.flags&ext4FlagEncrypt == 0 {
+					visited[dirent.inode] = struct{}{}
+					queue = append(queue, queued{name, inode})
+				}
+				continue
+			}
+			if inode.isFile() {
+				found(name, inode)
+			}
+		}
+	}
+	return nil
+}
+
+func (v *ext4Volume) readDir(inode ext4Inode) ([]ext4Dirent, error) {
+	// an inline directory starts with its parent's inode number instead of . and ..
+	if inode.flags&ext4FlagInlineData != 0 {
+		data := inode.block[ext4InlineDirHeader:]
+		return parseEXT4Dir(data, int64(len(data))), nil
+	}
+
+	runs, ok := v.runs(inode)
+	if !ok {
+		return nil, cnst.ErrIncompatibleFileSystem
+	}
+	var data []byte
+	for _, dataRun := range runs {
+		chunk := make([]byte, dataRun.size)
+		_, err := v.r.ReadAt(chunk, dataRun.start)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, chunk...)
+	}
+	return parseEXT4Dir(data, v.blockSize), nil
+}
+
+// parseEXT4Dir reads the linear directory entries of each block. Hashed directories
+// hide their index in entries without an inode, so their leaves are read the same way
+func parseEXT4Dir(data []byte, blockSize int64) []ext4Dirent {
+	var dirents []ext4Dirent
+	for offset := int64(0); offset+ext4DirentHeader <= int64(len(data)); {
+		recLen := int64(binary.LittleEndian.Uint16(data[offset+4:]))
+		blockEnd := (offset/blockSize + 1) * blockSize
+		// 64K blocks store the length of an entry reaching the end of the block as 0 or 0xFFFF
+		if blockSize == ext4MaxBlockSize && (recLen == 0 || recLen == ext4MaxBlockSize-1) {
+			recLen = blockEnd - offset
+		}
+		// a corrupt entry is skipped by moving on to the next block
+		if recLen < ext4DirentHeader || offset+recLen > blockEnd {
+			offset = blockEnd
+			continue
+		}
+
+		number := binary.LittleEndian.Uint32(data[offset:])
+		nameLen := int64(data[offset+6])
+		if number != 0 && nameLen > 0 && ext4DirentHeader+nameLen <= recLen {
+			name := string(data[offset+ext4DirentHeader : offset+ext4DirentHeader+nameLen])
+			if name != "." && name != ".." {
+				dirents = append(dirents, ext4Dirent{inode: number, name: name})
+			}
+		}
+		offset += recLen
+	}
+	return dirents
+}
@@ -0,0 +1,258 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"indicer/lib/structs"
+	"slices"
+	"testing"
+)
+
+const (
+	testEXT4Block       = 1024
+	testEXT4Blocks      = 64
+	testEXT4InodeTable  = 5
+	testEXT4InodeSize   = 128
+	testEXT4Inodes      = 16
+	testEXT4RootBlock   = 10
+	testEXT4SubBlock    = 11
+	testEXT4FileBlock   = 20
+	testEXT4FragBlocks  = 30
+	testEXT4FragBlocks2 = 40
+)
+
+// ext4TestImage builds a small ext4 volume with 1K blocks and a single group: /file.txt in
+// two blocks of an extent, /sub/frag.bin in two extents apart on disk, /inline.txt kept in
+// its inode and /sub read through an ext2 style block map. edit can change the image afterwards
+func ext4TestImage(edit func(image []byte)) []byte {
+	image := make([]byte, testEXT4Blocks*testEXT4Block)
+	sb := image[ext4SuperblockOffset:]
+	binary.LittleEndian.PutUint32(sb[0x00:], testEXT4Inodes)
+	binary.LittleEndian.PutUint32(sb[0x04:], testEXT4Blocks)
+	binary.LittleEndian.PutUint32(sb[0x14:], 1)
+	binary.LittleEndian.PutUint32(sb[0x20:], 8192)
+	binary.LittleEndian.PutUint32(sb[0x28:], testEXT4Inodes)
+	binary.LittleEndian.PutUint16(sb[0x38:], ext4Magic)
+	binary.LittleEndian.PutUint32(sb[0x4C:], 1)
+	binary.LittleEndian.PutUint16(sb[0x58:], testEXT4InodeSize)
+
+	desc := image[2*testEXT4Block:]
+	binary.LittleEndian.PutUint32(desc[0x00:], 3)
+	binary.LittleEndian.PutUint32(desc[0x04:], 4)
+	binary.LittleEndian.PutUint32(desc[0x08:], testEXT4InodeTable)
+
+	// bit n of the bitmap is block n+1, the blocks up to the inode table and every data block are used
+	bitmap := image[3*testEXT4Block:]
+	for _, block := range []int{1, 2, 3, 4, 5, 6, testEXT4RootBlock, testEXT4SubBlock, testEXT4FileBlock, testEXT4FileBlock + 1, testEXT4FragBlocks, testEXT4FragBlocks2} {
+		bitmap[(block-1)/8] |= 1 << ((block - 1) % 8)
+	}
+
+	ext4TestInode(image, ext4RootInode, ext4ModeDir, ext4FlagExtents, testEXT4Block, ext4TestExtents([3]uint32{0, 1, testEXT4RootBlock}))
+	ext4TestDir(image[testEXT4RootBlock*testEXT4Block:], map[string]uint32{"file.txt": 12, "sub": 13, "inline.txt": 15})
+
+	var blockMap [ext4InlineSize]byte
+	binary.LittleEndian.PutUint32(blockMap[:], testEXT4SubBlock)
+	ext4TestInode(image, 13, ext4ModeDir, 0, testEXT4Block, blockMap[:])
+	ext4TestDir(image[testEXT4SubBlock*testEXT4Block:], map[string]uint32{"frag.bin": 14})
+
+	ext4TestInode(image, 12, ext4ModeFile, ext4FlagExtents, 1500, ext4TestExtents([3]uint32{0, 2, testEXT4FileBlock}))
+	ext4TestInode(image, 14, ext4ModeFile, ext4FlagExtents, 2*testEXT4Block,
+		ext4TestExtents([3]uint32{0, 1, testEXT4FragBlocks}, [3]uint32{1, 1, testEXT4FragBlocks2}))
+	ext4TestInode(image, 15, ext4ModeFile, ext4FlagInlineData, 5, []byte("hello"))
+
+	if edit != nil {
+		edit(image)
+	}
+	return image
+}
+
+func ext4TestInodeOffset(number int) int {
+	return testEXT4InodeTable*testEXT4Block + (number-1)*testEXT4InodeSize
+}
+
+func ext4TestInode(image []byte, number int, mode uint16, flags uint32, size int64, block []byte) {
+	inode := image[ext4TestInodeOffset(number):]
+	binary.LittleEndian.PutUint16(inode[0x00:], mode)
+	binary.LittleEndian.PutUint32(inode[0x04:], uint32(size))
+	binary.LittleEndian.PutUint32(inode[0x10:], 1700000000)
+	binary.LittleEndian.PutUint32(inode[0x20:], flags)
+	copy(inode[ext4BlockOffset:ext4BlockOffset+ext4InlineSize], block)
+}
+
+// ext4TestExtents is a leaf extent node of logical block, length and start triples
+func ext4TestExtents(extents ...[3]uint32) []byte {
+	node := make([]byte, ext4InlineSize)
+	binary.LittleEndian.PutUint16(node[0:], ext4ExtentMagic)
+	binary.LittleEndian.PutUint16(node[2:], uint16(len(extents)))
+	binary.LittleEndian.PutUint16(node[4:], 4)
+	for index, extent := range extents {
+		entry := node[ext4ExtentSize*(index+1):]
+		binary.LittleEndian.PutUint32(entry[0:], extent[0])
+		binary.LittleEndian.PutUint16(entry[4:], uint16(extent[1]))
+		binary.LittleEndian.PutUint32(entry[8:], extent[2])
+	}
+	return node
+}
+
+// ext4TestDir writes a directory block, the last entry reaches the end of the block
+func ext4TestDir(block []byte, entries map[string]uint32) {
+	names := []string{".", ".."}
+	for name := range entries {
+		names = append(names, name)
+	}
+	slices.Sort(names[2:])
+
+	offset := 0
+	for index, name := range names {
+		recLen := (ext4DirentHeader + len(name) + 3) &^ 3
+		if index == len(names)-1 {
+			recLen = testEXT4Block - offset
+		}
+		binary.LittleEndian.PutUint32(block[offset:], max(entries[name], ext4RootInode))
+		binary.LittleEndian.PutUint16(block[offset+4:], uint16(recLen))
+		block[offset+6] = byte(len(name))
+		copy(block[offset+ext4DirentHeader:], name)
+		offset += recLen
+	}
+}
+
+// testListedFiles collects the runs of the files a parser listed, leaving out slack and unallocated space
+func testListedFiles(entries []*structs.IngestRange) map[string][]structs.Extent {
+	files := make(map[string][]structs.Extent)
+	for _, entry := range entries {
+		if entry.Space == "" {
+			files[entry.Name] = entry.Runs()
+		}
+	}
+	return files
+}
+
+// testPartition is a partition of size bytes at the start of the evidence
+func testPartition(size int64) structs.PartitionFile {
+	var partition structs.PartitionFile
+	partition.Size = size
+	return partition
+}
+
+func testCompareFiles(t *testing.T, got, want map[string][]structs.Extent) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got files %v, want %v", got, want)
+	}
+	for name, runs := range want {
+		if !slices.Equal(got[name], runs) {
+			t.Errorf("%s: got %v, want %v", name, got[name], runs)
+		}
+	}
+}
+
+func TestListEXT4(t *testing.T) {
+	inline := structs.Extent{Start: int64(ext4TestInodeOffset(15) + ext4BlockOffset), Size: 5}
+	file := structs.Extent{Start: testEXT4FileBlock * testEXT4Block, Size: 1500}
+	frag := []structs.Extent{{Start: testEXT4FragBlocks * testEXT4Block, Size: testEXT4Block}, {Start: testEXT4FragBlocks2 * testEXT4Block, Size: testEXT4Block}}
+
+	tests := []struct {
+		name  string
+		edit  func(image []byte)
+		files map[string][]structs.Extent
+	}{
+		{
+			name:  "valid",
+			files: map[string][]structs.Extent{"/file.txt": {file}, "/sub/frag.bin": frag, "/inline.txt": {inline}},
+		},
+		{
+			name: "extent past the end of the volume",
+			edit: func(image []byte) {
+				binary.LittleEndian.PutUint32(image[ext4TestInodeOffset(12)+ext4BlockOffset+ext4ExtentSize+8:], testEXT4Blocks)
+			},
+			files: map[string][]structs.Extent{"/sub/frag.bin": frag, "/inline.txt": {inline}},
+		},
+		{
+			name: "uninitialized extent",
+			edit: func(image []byte) {
+				binary.LittleEndian.PutUint16(image[ext4TestInodeOffset(12)+ext4BlockOffset+ext4ExtentSize+4:], ext4ExtentMaxInit+2)
+			},
+			files: map[string][]structs.Extent{"/sub/frag.bin": frag, "/inline.txt": {inline}},
+		},
+		{
+			name: "extent tree deeper than allowed",
+			edit: func(image []byte) {
+				binary.LittleEndian.PutUint16(image[ext4TestInodeOffset(14)+ext4BlockOffset+6:], ext4MaxExtentDepth+1)
+			},
+			files: map[string][]structs.Extent{"/file.txt": {file}, "/inline.txt": {inline}},
+		},
+		{
+			name: "hole in the block map of a directory",
+			edit: func(image []byte) {
+				binary.LittleEndian.PutUint32(image[ext4TestInodeOffset(13)+ext4BlockOffset:], 0)
+			},
+			files: map[string][]structs.Extent{"/file.txt": {file}, "/inline.txt": {inline}},
+		},
+		{
+			name: "inline data larger than the inode holds",
+			edit: func(image []byte) {
+				binary.LittleEndian.PutUint32(image[ext4TestInodeOffset(15)+0x04:], ext4InlineSize+1)
+			},
+			files: map[string][]structs.Extent{"/file.txt": {file}, "/sub/frag.bin": frag},
+		},
+		{
+			name: "directory linking back to the root",
+			edit: func(image []byte) {
+				ext4TestDir(image[testEXT4SubBlock*testEXT4Block:], map[string]uint32{"frag.bin": 14, "loop": ext4RootInode})
+			},
+			files: map[string][]structs.Extent{"/file.txt": {file}, "/sub/frag.bin": frag, "/inline.txt": {inline}},
+		},
+		{
+			name: "entry length past the end of the block",
+			edit: func(image []byte) {
+				binary.LittleEndian.PutUint16(image[testEXT4SubBlock*testEXT4Block+4:], testEXT4Block+4)
+			},
+			files: map[string][]structs.Extent{"/file.txt": {file}, "/inline.txt": {inline}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entries, err := ListEXT4(bytes.NewReader(ext4TestImage(test.edit)), testPartition(testEXT4Blocks*testEXT4Block))
+			if err != nil {
+				t.Fatal(err)
+			}
+			testCompareFiles(t, testListedFiles(entries), test.files)
+		})
+	}
+}
+
+func TestListEXT4Times(t *testing.T) {
+	entries, err := ListEXT4(bytes.NewReader(ext4TestImage(nil)), structs.PartitionFile{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.Space == "" && entry.Times.Modified.Unix() != 1700000000 {
+			t.Errorf("%s: got modified time %v", entry.Name, entry.Times.Modified)
+		}
+	}
+}
+
+func TestListEXT4NotEXT4(t *testing.T) {
+	for name, edit := range map[string]func(image []byte){
+		"bad magic":              func(image []byte) { image[ext4SuperblockOffset+0x38] = 0 },
+		"block size too large":   func(image []byte) { image[ext4SuperblockOffset+0x18] = ext4MaxLogBlockSize + 1 },
+		"no blocks per group":    func(image []byte) { binary.LittleEndian.PutUint32(image[ext4SuperblockOffset+0x20:], 0) },
+		"inode size not a power": func(image []byte) { binary.LittleEndian.PutUint16(image[ext4SuperblockOffset+0x58:], 129) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ListEXT4(bytes.NewReader(ext4TestImage(edit)), structs.PartitionFile{})
+			if err == nil {
+				t.Fatal("a volume that is not ext4 was listed")
+			}
+		})
+	}
+}
+
+func FuzzListEXT4(f *testing.F) {
+	f.Add(ext4TestImage(nil))
+	f.Fuzz(func(t *testing.T, image []byte) {
+		ListEXT4(bytes.NewReader(image), testPartition(int64(len(image))))
+	})
+}
@@ -0,0 +1,458 @@
+package parser
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"indicer/lib/cnst"
+	"indicer/lib/structs"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	fatDirEntrySize  = 32
+	fatFirstCluster  = 2
+	fat12MaxClusters = 4085
+	fat16MaxClusters = 65525
+	fat32ClusterMask = 0x0FFFFFFF
+	fatAttrVolumeID  = 0x08
+	fatAttrDir       = 0x10
+	fatAttrLFN       = 0x0F
+	fatAttrLFNMask   = 0x3F
+	fatEntryEnd      = 0x00
+	fatEntryDeleted  = 0xE5
+	fatEntryKanjiE5  = 0x05
+	fatLFNLast       = 0x40
+	fatLFNOrderMask  = 0x1F
+	fatLFNChars      = 13
+	fatShortNameSize = 11
+	fatCaseLowerBase = 0x08
+	fatCaseLowerExt  = 0x10
+	fatMinMediaByte  = 0xF0
+	fatEpochYear     = 1980
+	// fatBlockClusters is even, so no FAT12 entry is split between two blocks
+	fatBlockClusters = 256 * 1024
+)
+
+// fatVolume is a FAT12, FAT16 or FAT32 file system found at start of the evidence, all offsets are absolute
+type fatVolume struct {
+	r            io.ReaderAt
+	bits         int
+	fatOffset    int64
+	dataOffset   int64
+	clusterSize  int64
+	clusterCount uint32
+	// FAT12 and FAT16 keep the root directory in a fixed region before the data area
+	rootOffset  int64
+	rootSize    int64
+	rootCluster uint32
+}
+
+// fatEntry is a short directory entry with the long name of the LFN entries in front of it
+type fatEntry struct {
+	name    string
+	attr    byte
+	cluster uint32
+	size    int64
+	times   structs.FileTimes
+}
+
+func (e fatEntry) isDir() bool {
+	return e.attr&fatAttrDir != 0
+}
+
+func isFAT(r io.ReaderAt, start int64) bool {
+	_, err := openFAT(r, start)
+	return err == nil
+}
+
+// ListFAT returns the files of the FAT volume at partition named by their full path, with
+// their timestamps and the runs of fragmented files. Their hashes are filled in by the ingest pass.
+// File slack, free clusters and volume slack are listed after the files
+func ListFAT(r io.ReaderAt, partition structs.PartitionFile) ([]*structs.IngestRange, error) {
+	volume, err := openFAT(r, partition.Start)
+	if err != nil {
+		return nil, cnst.ErrIncompatibleFileSystem
+	}
+
+	var entries []*structs.IngestRange
+	err = volume.walk(func(name string, entry fatEntry) {
+		runs, ok := volume.runs(entry)
+		if !ok {
+			return
+		}
+		irange := newIngestRange(name, runs, entry.size)
+		irange.Times = entry.times
+		entries = append(entries, irange)
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(entries, func(a, b *structs.IngestRange) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
+
+	free, err := volume.freeRuns()
+	if err != nil {
+		return nil, err
+	}
+	end := volume.dataOffset + int64(volume.clusterCount)*volume.clusterSize
+	return append(entries, spaceRanges(entries, free, volume.dataOffset, volume.clusterSize, end, partition)...), nil
+}
+
+func openFAT(r io.ReaderAt, start int64) (*fatVolume, error) {
+	boot := make([]byte, cnst.SectorSize)
+	_, err := r.ReadAt(boot, start)
+	if err != nil {
+		return nil, err
+	}
+	if (boot[0] != 0xEB && boot[0] != 0xE9) || boot[bootSectorSigIndex] != 0x55 || boot[bootSectorSigIndex+1] != 0xAA {
+		return nil, cnst.ErrIncompatibleFileSystem
+	}
+
+	sectorSize := int64(binary.LittleEndian.Uint16(boot[0x0B:]))
+	sectorsPerCluster := int64(boot[0x0D])
+	reserved := int64(binary.LittleEndian.Uint16(boot[0x0E:]))
+	fatCount := int64(boot[0x10])
+	rootEntries := int64(binary.LittleEndian.Uint16(boot[0x11:]))
+	media := boot[0x15]
+	if sectorSize < 512 || sectorSize > 4096 || sectorSize&(sectorSize-1) != 0 ||
+		sectorsPerCluster == 0 || sectorsPerCluster&(sectorsPerCluster-1) != 0 ||
+		reserved == 0 || fatCount == 0 || media < fatMinMediaByte {
+		return nil, cnst.ErrIncompatibleFileSystem
+	}
+
+	totalSectors := int64(binary.LittleEndian.Uint16(boot[0x13:]))
+	if totalSectors == 0 {
+		totalSectors = int64(binary.LittleEndian.Uint32(boot[0x20:]))
+	}
+	fatSectors := int64(binary.LittleEndian.Uint16(boot[0x16:]))
+	if fatSectors == 0 {
+		fatSectors = int64(binary.LittleEndian.Uint32(boot[0x24:]))
+	}
+	rootSectors := (rootEntries*fatDirEntrySize + sectorSize - 1) / sectorSize
+	dataSector := reserved + fatCount*fatSectors + rootSectors
+	if fatSectors == 0 || totalSectors <= dataSector {
+		return nil, cnst.ErrIncompatibleFileSystem
+	}
+
+	// the FAT type follows from the number of clusters alone
+	clusterCount := (totalSectors - dataSector) / sectorsPerCluster
+	volume := &fatVolume{
+		r:            r,
+		fatOffset:    start + reserved*sectorSize,
+		dataOffset:   start + dataSector*sectorSize,
+		clusterSize:  sectorsPerCluster * sectorSize,
+		clusterCount: uint32(min(clusterCount, fat32ClusterMask)),
+		rootOffset:   start + (reserved+fatCount*fatSectors)*sectorSize,
+		rootSize:     rootSectors * sectorSize,
+	}
+	switch {
+	case clusterCount < fat12MaxClusters:
+		volume.bits = 12
+	case clusterCount < fat16MaxClusters:
+		volume.bits = 16
+	default:
+		volume.bits = 32
+		volume.rootCluster = binary.LittleEndian.Uint32(boot[0x2C:]) & fat32ClusterMask
+		if rootEntries != 0 || !volume.validCluster(volume.rootCluster) {
+			return nil, cnst.ErrIncompatibleFileSystem
+		}
+	}
+	if volume.bits != 32 && rootEntries == 0 {
+		return nil, cnst.ErrIncompatibleFileSystem
+	}
+
+	// clusters the FAT has no entry for cannot be used, a FAT short of more than a sector
+	// of entries means the boot sector does not describe the volume
+	perSector := sectorSize * 8 / int64(volume.bits)
+	capacity := fatSectors*perSector - fatFirstCluster
+	if capacity <= 0 || clusterCount > capacity+perSector {
+		return nil, cnst.ErrIncompatibleFileSystem
+	}
+	volume.clusterCount = uint32(min(clusterCount, capacity, fat32ClusterMask))
+	return volume, nil
+}
+
+func (v *fatVolume) validCluster(cluster uint32) bool {
+	return cluster >= fatFirstCluster && cluster < v.clusterCount+fatFirstCluster
+}
+
+func (v *fatVolume) clusterOffset(cluster uint32) int64 {
+	return v.dataOffset + int64(cluster-fatFirstCluster)*v.clusterSize
+}
+
+// nextCluster reads the FAT entry of a cluster, FAT12 packs two entries into three bytes
+func (v *fatVolume) nextCluster(cluster uint32) (uint32, error) {
+	first := cluster &^ 1
+	entry := make([]byte, 8)
+	_, err := v.r.ReadAt(entry, v.fatOffset+int64(first)*int64(v.bits)/8)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	return v.fatEntry(entry, cluster-first, cluster), nil
+}
+
+// freeRuns returns the runs of the clusters whose FAT entry is zero, the FAT is read
+// fatBlockClusters entries at a time
+func (v *fatVolume) freeRuns() ([]run, error) {
+	var runs []run
+	end := v.clusterCount + fatFirstCluster
+	for first := uint32(0); first < end; first += fatBlockClusters {
+		count := min(fatBlockClusters, end-first)
+		// the extra byte lets the last FAT12 entry be read as two bytes
+		table := make([]byte, (int64(count)*int64(v.bits)+7)/8+1)
+		_, err := v.r.ReadAt(table, v.fatOffset+int64(first)*int64(v.bits)/8)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		for cluster := max(first, fatFirstCluster); cluster < first+count; cluster++ {
+			if v.fatEntry(table, cluster-first, cluster) == 0 {
+				runs = appendRun(runs, run{start: v.clusterOffset(cluster), size: v.clusterSize})
+			}
+		}
+	}
+	return runs, nil
+}
+
+// fatEntry decodes entry index of a block of the FAT read from an even cluster on
+func (v *fatVolume) fatEntry(table []byte, index, cluster uint32) uint32 {
+	switch v.bits {
+	case 12:
+		next := uint32(binary.LittleEndian.Uint16(table[index+index/2:]))
+		if cluster%2 == 1 {
+			return next >> 4
+		}
+		return next & 0xFFF
+	case 16:
+		return uint32(binary.LittleEndian.Uint16(table[index*2:]))
+	default:
+		return binary.LittleEndian.Uint32(table[index*4:]) & fat32ClusterMask
+	}
+}
+
+// chain follows the cluster chain of a file through the FAT, limit stops it after the
+// clusters a file of its size needs. A chain that loops or leaves the volume is cut short
+func (v *fatVolume) chain(first uint32, limit int64) ([]uint32, error) {
+	var clusters []uint32
+	visited := make(map[uint32]struct{})
+	for cluster := first; v.validCluster(cluster) && int64(len(clusters)) < limit; {
+		if _, ok := visited[cluster]; ok {
+			break
+		}
+		visited[cluster] = struct{}{}
+		clusters = append(clusters, cluster)
+
+		next, err := v.nextCluster(cluster)
+		if err != nil {
+			return nil, err
+		}
+		cluster = next
+	}
+	return clusters, nil
+}
+
+// runs returns the runs of a file in file order cut to its size, merging clusters that
+// follow each other on disk. ok is false when the chain is shorter than the file
+func (v *fatVolume) runs(entry fatEntry) ([]run, bool) {
+	if entry.size == 0 {
+		return nil, false
+	}
+	count := (entry.size + v.clusterSize - 1) / v.clusterSize
+	clusters, err := v.chain(entry.cluster, count)
+	if err != nil || int64(len(clusters)) < count {
+		return nil, false
+	}
+
+	var runs []run
+	remaining := entry.size
+	for _, cluster := range clusters {
+		dataRun := run{start: v.clusterOffset(cluster), size: min(v.clusterSize, remaining)}
+		remaining -= dataRun.size
+		runs = appendRun(runs, dataRun)
+	}
+	return runs, true
+}
+
+func (v *fatVolume) readDir(cluster uint32) ([]byte, error) {
+	if cluster == 0 && v.bits != 32 {
+		data := make([]byte, v.rootSize)
+		_, err := v.r.ReadAt(data, v.rootOffset)
+		return data, err
+	}
+	if cluster == 0 {
+		cluster = v.rootCluster
+	}
+
+	clusters, err := v.chain(cluster, int64(v.clusterCount))
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, int64(len(clusters))*v.clusterSize)
+	for index, cluster := range clusters {
+		_, err = v.r.ReadAt(data[int64(index)*v.clusterSize:int64(index+1)*v.clusterSize], v.clusterOffset(cluster))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// walk calls found with the full path of every file, going through the directories from
+// the root. Each directory is read once, so a corrupt directory pointing back up cannot loop
+func (v *fatVolume) walk(found func(name string, entry fatEntry)) error {
+	type queued struct {
+		name    string
+		cluster uint32
+	}
+	visited := map[uint32]struct{}{0: {}, v.rootCluster: {}}
+	queue := []queued{{"/", 0}}
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue = queue[1:]
+
+		data, err := v.readDir(dir.cluster)
+		if err != nil {
+			if dir.cluster == 0 {
+				return err
+			}
+			continue
+		}
+		for _, entry := range parseFATDir(data) {
+			name := path.Join(dir.name, entry.name)
+			if !entry.isDir() {
+				found(name, entry)
+				continue
+			}
+			if _, ok := visited[entry.cluster]; !ok && v.validCluster(entry.cluster) {
+				visited[entry.cluster] = struct{}{}
+				queue = append(queue, queued{name, entry.cluster})
+			}
+		}
+	}
+	return nil
+}
+
+// parseFATDir reads the entries of a directory. LFN entries come in reverse order in
+// front of their short entry, they are only used when complete and their checksum matches
+func parseFATDir(data []byte) []fatEntry {
+	var entries []fatEntry
+	var lfn []uint16
+	var lfnChecksum byte
+	var lfnNext byte
+
+	for offset := 0; offset+fatDirEntrySize <= len(data); offset += fatDirEntrySize {
+		raw := data[offset : offset+fatDirEntrySize]
+		if raw[0] == fatEntryEnd {
+			break
+		}
+		if raw[0] == fatEntryDeleted {
+			lfn = nil
+			continue
+		}
+
+		attr := raw[0x0B]
+		if attr&fatAttrLFNMask == fatAttrLFN {
+			order := raw[0] & fatLFNOrderMask
+			if raw[0]&fatLFNLast != 0 {
+				lfn = make([]uint16, int(order)*fatLFNChars)
+				lfnChecksum = raw[0x0D]
+				lfnNext = order
+			}
+			if lfn == nil || order == 0 || order != lfnNext || raw[0x0D] != lfnChecksum {
+				lfn = nil
+				continue
+			}
+			chars := lfn[int(order-1)*fatLFNChars:]
+			for index, charOffset := range []int{1, 3, 5, 7, 9, 14, 16, 18, 20, 22, 24, 28, 30} {
+				chars[index] = binary.LittleEndian.Uint16(raw[charOffset:])
+			}
+			lfnNext--
+			continue
+		}
+
+		name := fatShortName(raw)
+		if lfn != nil && lfnNext == 0 && fatChecksum(raw[:fatShortNameSize]) == lfnChecksum {
+			name = decodeLFN(lfn)
+		}
+		lfn = nil
+		if attr&fatAttrVolumeID != 0 || name == "." || name == ".." || name == "" {
+			continue
+		}
+
+		entries = append(entries, fatEntry{
+			name:    name,
+			attr:    attr,
+			cluster: uint32(binary.LittleEndian.Uint16(raw[0x14:]))<<16 | uint32(binary.LittleEndian.Uint16(raw[0x1A:])),
+			size:    int64(binary.LittleEndian.Uint32(raw[0x1C:])),
+			times: structs.FileTimes{
+				Modified: fatTime(binary.LittleEndian.Uint16(raw[0x18:]), binary.LittleEndian.Uint16(raw[0x16:]), 0),
+				Accessed: fatTime(binary.LittleEndian.Uint16(raw[0x12:]), 0, 0),
+				Created:  fatTime(binary.LittleEndian.Uint16(raw[0x10:]), binary.LittleEndian.Uint16(raw[0x0E:]), raw[0x0D]),
+			},
+		})
+	}
+	return entries
+}
+
+// fatShortName turns an 8.3 name into a file name, Windows keeps an all lower case
+// base or extension as upper case with a flag instead of an LFN entry
+func fatShortName(raw []byte) string {
+	short := bytes.Clone(raw[:fatShortNameSize])
+	if short[0] == fatEntryKanjiE5 {
+		short[0] = fatEntryDeleted
+	}
+	base := strings.TrimRight(string(short[:8]), " ")
+	ext := strings.TrimRight(string(short[8:]), " ")
+	if raw[0x0C]&fatCaseLowerBase != 0 {
+		base = strings.ToLower(base)
+	}
+	if raw[0x0C]&fatCaseLowerExt != 0 {
+		ext = strings.ToLower(ext)
+	}
+	if ext == "" {
+		return base
+	}
+	return base + "." + ext
+}
+
+func fatChecksum(short []byte) byte {
+	var sum byte
+	for _, char := range short {
+		sum = ((sum >> 1) | (sum << 7)) + char
+	}
+	return sum
+}
+
+// decodeLFN decodes a long name, which ends at a NUL and is padded with 0xFFFF
+func decodeLFN(chars []uint16) string {
+	for index, char := range chars {
+		if char == 0 {
+			chars = chars[:index]
+			break
+		}
+	}
+	return string(utf16.Decode(chars))
+}
+
+// fatTime reads a FAT date and time, stored in two second steps with a tenths field for
+// the creation time. FAT keeps local time without a zone, it is taken as UTC
+func fatTime(date, clock uint16, tenths byte) time.Time {
+	if date == 0 {
+		return time.Time{}
+	}
+	year := fatEpochYear + int(date>>9)
+	month := time.Month(date >> 5 & 0x0F)
+	day := int(date & 0x1F)
+	hour := int(clock >> 11)
+	minute := int(clock >> 5 & 0x3F)
+	second := int(clock&0x1F)*2 + int(tenths)/100
+	nanosecond := int(tenths) % 100 * int(10*time.Millisecond)
+	return time.Date(year, month, day, hour, minute, second, nanosecond, time.UTC)
+}
@@ -0,0 +1,216 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"indicer/lib/structs"
+	"testing"
+	"unicode/utf16"
+)
+
+const (
+	testFATSector      = 512
+	testFATSectors     = 128
+	testFATDataSector  = 4
+	testFATRootEntries = 16
+	testFATSubCluster  = 7
+)
+
+// fatTestImage builds a small FAT12 volume with one sector clusters: /Long File Name.txt
+// in clusters 2 and 3 behind an LFN entry, /FRAG.BIN in clusters 4 and 6 and /SUB/inner.txt,
+// whose lower case name is kept in the case flags. edit can change the image afterwards
+func fatTestImage(edit func(image []byte)) []byte {
+	image := make([]byte, testFATSectors*testFATSector)
+	boot := image[:testFATSector]
+	boot[0] = 0xEB
+	binary.LittleEndian.PutUint16(boot[0x0B:], testFATSector)
+	boot[0x0D] = 1
+	binary.LittleEndian.PutUint16(boot[0x0E:], 1)
+	boot[0x10] = 2
+	binary.LittleEndian.PutUint16(boot[0x11:], testFATRootEntries)
+	binary.LittleEndian.PutUint16(boot[0x13:], testFATSectors)
+	boot[0x15] = 0xF8
+	binary.LittleEndian.PutUint16(boot[0x16:], 1)
+	boot[bootSectorSigIndex] = 0x55
+	boot[bootSectorSigIndex+1] = 0xAA
+
+	for cluster, next := range map[uint32]uint32{2: 3, 3: 0xFFF, 4: 6, 6: 0xFFF, testFATSubCluster: 0xFFF, 8: 0xFFF} {
+		fatTestSetEntry(image, cluster, next)
+	}
+
+	root := fatTestLFN("Long File Name.txt", "LONGFI~1TXT")
+	root = append(root, fatTestEntry("LONGFI~1TXT", 0, 0, 2, 700)...)
+	root = append(root, fatTestEntry("FRAG    BIN", 0, 0, 4, 2*testFATSector)...)
+	deleted := fatTestEntry("GONE    TXT", 0, 0, 9, 10)
+	deleted[0] = fatEntryDeleted
+	root = append(root, deleted...)
+	root = append(root, fatTestEntry("SUB        ", fatAttrDir, 0, testFATSubCluster, 0)...)
+	copy(image[3*testFATSector:], root)
+
+	sub := fatTestEntry(".          ", fatAttrDir, 0, testFATSubCluster, 0)
+	sub = append(sub, fatTestEntry("..         ", fatAttrDir, 0, 0, 0)...)
+	sub = append(sub, fatTestEntry("INNER   TXT", 0, fatCaseLowerBase|fatCaseLowerExt, 8, 10)...)
+	copy(image[fatTestClusterOffset(testFATSubCluster):], sub)
+
+	if edit != nil {
+		edit(image)
+	}
+	return image
+}
+
+func fatTestClusterOffset(cluster uint32) int64 {
+	return int64(testFATDataSector*testFATSector) + int64(cluster-fatFirstCluster)*testFATSector
+}
+
+// fatTestSetEntry sets the FAT12 entry of a cluster in both FATs
+func fatTestSetEntry(image []byte, cluster, next uint32) {
+	for _, fat := range []int{testFATSector, 2 * testFATSector} {
+		offset := fat + int(cluster+cluster/2)
+		packed := uint32(binary.LittleEndian.Uint16(image[offset:]))
+		if cluster%2 == 1 {
+			packed = packed&0x000F | next<<4
+		} else {
+			packed = packed&0xF000 | next
+		}
+		binary.LittleEndian.PutUint16(image[offset:], uint16(packed))
+	}
+}
+
+func fatTestEntry(short string, attr, caseFlags byte, cluster uint32, size uint32) []byte {
+	entry := make([]byte, fatDirEntrySize)
+	copy(entry, short)
+	entry[0x0B] = attr
+	entry[0x0C] = caseFlags
+	binary.LittleEndian.PutUint16(entry[0x14:], uint16(cluster>>16))
+	binary.LittleEndian.PutUint16(entry[0x1A:], uint16(cluster))
+	binary.LittleEndian.PutUint32(entry[0x1C:], size)
+	// 2024-03-15 10:30:00
+	binary.LittleEndian.PutUint16(entry[0x18:], (2024-fatEpochYear)<<9|3<<5|15)
+	binary.LittleEndian.PutUint16(entry[0x16:], 10<<11|30<<5)
+	return entry
+}
+
+// fatTestLFN lays out the LFN entries of name in the reverse order they are stored in
+func fatTestLFN(name, short string) []byte {
+	chars := append(utf16.Encode([]rune(name)), 0)
+	for len(chars)%fatLFNChars != 0 {
+		chars = append(chars, 0xFFFF)
+	}
+	count := len(chars) / fatLFNChars
+	checksum := fatChecksum([]byte(short))
+
+	var entries []byte
+	for order := count; order >= 1; order-- {
+		entry := make([]byte, fatDirEntrySize)
+		entry[0] = byte(order)
+		if order == count {
+			entry[0] |= fatLFNLast
+		}
+		entry[0x0B] = fatAttrLFN
+		entry[0x0D] = checksum
+		for index, charOffset := range []int{1, 3, 5, 7, 9, 14, 16, 18, 20, 22, 24, 28, 30} {
+			binary.LittleEndian.PutUint16(entry[charOffset:], chars[(order-1)*fatLFNChars+index])
+		}
+		entries = append(entries, entry...)
+	}
+	return entries
+}
+
+func TestListFAT(t *testing.T) {
+	long := []structs.Extent{{Start: fatTestClusterOffset(2), Size: 700}}
+	frag := []structs.Extent{{Start: fatTestClusterOffset(4), Size: testFATSector}, {Start: fatTestClusterOffset(6), Size: testFATSector}}
+	inner := []structs.Extent{{Start: fatTestClusterOffset(8), Size: 10}}
+	rootOffset := 3 * testFATSector
+	// FRAG.BIN comes after the two LFN entries and the short entry of the long name
+	fragEntry := rootOffset + 3*fatDirEntrySize
+
+	tests := []struct {
+		name  string
+		edit  func(image []byte)
+		files map[string][]structs.Extent
+	}{
+		{
+			name:  "valid",
+			files: map[string][]structs.Extent{"/Long File Name.txt": long, "/FRAG.BIN": frag, "/SUB/inner.txt": inner},
+		},
+		{
+			name: "LFN checksum mismatch",
+			edit: func(image []byte) {
+				image[rootOffset+0x0D]++
+				image[rootOffset+fatDirEntrySize+0x0D]++
+			},
+			files: map[string][]structs.Extent{"/LONGFI~1.TXT": long, "/FRAG.BIN": frag, "/SUB/inner.txt": inner},
+		},
+		{
+			name:  "cluster chain looping on itself",
+			edit:  func(image []byte) { fatTestSetEntry(image, 4, 4) },
+			files: map[string][]structs.Extent{"/Long File Name.txt": long, "/SUB/inner.txt": inner},
+		},
+		{
+			name:  "chain shorter than the file",
+			edit:  func(image []byte) { binary.LittleEndian.PutUint32(image[fragEntry+0x1C:], 3*testFATSector) },
+			files: map[string][]structs.Extent{"/Long File Name.txt": long, "/SUB/inner.txt": inner},
+		},
+		{
+			name:  "first cluster past the volume",
+			edit:  func(image []byte) { binary.LittleEndian.PutUint16(image[fragEntry+0x1A:], 0xFF0) },
+			files: map[string][]structs.Extent{"/Long File Name.txt": long, "/SUB/inner.txt": inner},
+		},
+		{
+			name: "directory pointing back at itself",
+			edit: func(image []byte) {
+				copy(image[fatTestClusterOffset(testFATSubCluster)+3*fatDirEntrySize:], fatTestEntry("LOOP       ", fatAttrDir, 0, testFATSubCluster, 0))
+			},
+			files: map[string][]structs.Extent{"/Long File Name.txt": long, "/FRAG.BIN": frag, "/SUB/inner.txt": inner},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entries, err := ListFAT(bytes.NewReader(fatTestImage(test.edit)), testPartition(testFATSectors*testFATSector))
+			if err != nil {
+				t.Fatal(err)
+			}
+			testCompareFiles(t, testListedFiles(entries), test.files)
+		})
+	}
+}
+
+func TestListFATTimes(t *testing.T) {
+	entries, err := ListFAT(bytes.NewReader(fatTestImage(nil)), testPartition(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "2024-03-15 10:30:00 +0000 UTC"
+	for _, entry := range entries {
+		if entry.Space == "" && entry.Times.Modified.String() != want {
+			t.Errorf("%s: got modified time %v, want %s", entry.Name, entry.Times.Modified, want)
+		}
+	}
+}
+
+func TestListFATNotFAT(t *testing.T) {
+	for name, edit := range map[string]func(image []byte){
+		"no boot signature":       func(image []byte) { image[bootSectorSigIndex] = 0 },
+		"sector size not a power": func(image []byte) { binary.LittleEndian.PutUint16(image[0x0B:], 513) },
+		"no root directory":       func(image []byte) { binary.LittleEndian.PutUint16(image[0x11:], 0) },
+		"FAT too small for the clusters": func(image []byte) {
+			binary.LittleEndian.PutUint16(image[0x13:], 0)
+			binary.LittleEndian.PutUint32(image[0x20:], 4000)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ListFAT(bytes.NewReader(fatTestImage(edit)), testPartition(0))
+			if err == nil {
+				t.Fatal("a volume that is not FAT was listed")
+			}
+		})
+	}
+}
+
+func FuzzListFAT(f *testing.F) {
+	f.Add(fatTestImage(nil))
+	f.Fuzz(func(t *testing.T, image []byte) {
+		ListFAT(bytes.NewReader(image), testPartition(int64(len(image))))
+	})
+}
@@ -0,0 +1,175 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"indicer/lib/cnst"
+	"indicer/lib/structs"
+	"io"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/fatih/color"
+)
+
+const (
+	gptSignature      = "EFI PART"
+	gptMinHeaderSize  = 92
+	gptMinEntrySize   = 128
+	gptMaxEntries     = 1 << 16
+	gptNameOffset     = 56
+	gptNameSize       = 72
+	gptHeaderLBA      = 1
+	gptLargeBlockSize = 4096
+)
+
+// gptHeader is the part of a GPT header needed to find and check its partition entries
+type gptHeader struct {
+	currentLBA uint64
+	backupLBA  uint64
+	entriesLBA uint64
+	entryCount uint32
+	entrySize  uint32
+	entriesCRC uint32
+	blockSize  int64
+	entries    []byte
+}
+
+// parseGPT returns the partitions of a GUID partition table. The primary table is
+// checked against its CRCs and the backup at the end of the disk is used when it is corrupt
+func parseGPT(size int64, r io.ReaderAt) []structs.PartitionFile {
+	for _, blockSize := range []int64{int64(cnst.SectorSize), gptLargeBlockSize} {
+		if size < 3*blockSize {
+			continue
+		}
+		header, err := readGPTHeader(size, r, blockSize, gptHeaderLBA)
+		if err != nil {
+			// a primary header that is intact but has corrupt entries still says where its backup is
+			backupLBA := uint64(size/blockSize) - 1
+			if err == cnst.ErrGPTEntries {
+				backupLBA = header.backupLBA
+			}
+			header, err = readGPTHeader(size, r, blockSize, backupLBA)
+			if err != nil {
+				continue
+			}
+			color.Yellow("⚠️  primary GPT is corrupt, partitions are read from the backup GPT at LBA %d", backupLBA)
+		}
+		return gptPartitions(size, header)
+	}
+	return nil
+}
+
+func readGPTHeader(size int64, r io.ReaderAt, blockSize int64, lba uint64) (gptHeader, error) {
+	var header gptHeader
+	if lba == 0 {
+		return header, cnst.ErrGPTHeader
+	}
+
+	block := make([]byte, blockSize)
+	_, err := r.ReadAt(block, int64(lba)*blockSize)
+	if err != nil {
+		return header, err
+	}
+	if string(block[:8]) != gptSignature {
+		return header, cnst.ErrGPTHeader
+	}
+
+	headerSize := binary.LittleEndian.Uint32(block[12:16])
+	if headerSize < gptMinHeaderSize || int64(headerSize) > blockSize {
+		return header, cnst.ErrGPTHeader
+	}
+	raw := bytes.Clone(block[:headerSize])
+	headerCRC := binary.LittleEndian.Uint32(raw[16:20])
+	clear(raw[16:20])
+	if crc32.ChecksumIEEE(raw) != headerCRC {
+		return header, cnst.ErrGPTHeader
+	}
+
+	header = gptHeader{
+		currentLBA: binary.LittleEndian.Uint64(block[24:32]),
+		backupLBA:  binary.LittleEndian.Uint64(block[32:40]),
+		entriesLBA: binary.LittleEndian.Uint64(block[72:80]),
+		entryCount: binary.LittleEndian.Uint32(block[80:84]),
+		entrySize:  binary.LittleEndian.Uint32(block[84:88]),
+		entriesCRC: binary.LittleEndian.Uint32(block[88:92]),
+		blockSize:  blockSize,
+	}
+	if header.currentLBA != lba || header.entrySize < gptMinEntrySize || header.entrySize > gptLargeBlockSize || header.entrySize%gptMinEntrySize != 0 ||
+		header.entryCount == 0 || header.entryCount > gptMaxEntries {
+		return header, cnst.ErrGPTHeader
+	}
+	// the entries are bounded by the disk before they are allocated
+	entriesSize := int64(header.entryCount) * int64(header.entrySize)
+	if header.entriesLBA >= uint64(size/blockSize) || int64(header.entriesLBA)*blockSize+entriesSize > size {
+		return header, cnst.ErrGPTHeader
+	}
+
+	header.entries = make([]byte, entriesSize)
+	_, err = r.ReadAt(header.entries, int64(header.entriesLBA)*blockSize)
+	if err != nil {
+		return header, err
+	}
+	if crc32.ChecksumIEEE(header.entries) != header.entriesCRC {
+		return header, cnst.ErrGPTEntries
+	}
+	return header, nil
+}
+
+func gptPartitions(size int64, header gptHeader) []structs.PartitionFile {
+	var plist []structs.PartitionFile
+	for index := range int(header.entryCount) {
+		entry := header.entries[index*int(header.entrySize) : (index+1)*int(header.entrySize)]
+		typeGUID := entry[:16]
+		if bytes.Equal(typeGUID, make([]byte, 16)) {
+			continue
+		}
+
+		// LBAs are checked against the disk before they are turned into offsets that could overflow
+		firstLBA := binary.LittleEndian.Uint64(entry[32:40])
+		lastLBA := binary.LittleEndian.Uint64(entry[40:48])
+		if firstLBA == 0 || lastLBA < firstLBA || lastLBA >= uint64(size/header.blockSize) {
+			continue
+		}
+		start := int64(firstLBA) * header.blockSize
+		end := int64(lastLBA+1) * header.blockSize
+
+		var pfile structs.PartitionFile
+		pfile.Start = start
+		pfile.Size = end - start
+		pfile.Table = structs.PartitionTableEntry{
+			Scheme:     structs.SchemeGPT,
+			TypeGUID:   formatGUID(typeGUID),
+			GUID:       formatGUID(entry[16:32]),
+			Label:      decodeGPTName(entry[gptNameOffset : gptNameOffset+gptNameSize]),
+			Attributes: binary.LittleEndian.Uint64(entry[48:56]),
+		}
+		plist = append(plist, pfile)
+	}
+	return plist
+}
+
+// formatGUID prints a GUID the way it is usually written, its first three fields are stored little endian
+func formatGUID(raw []byte) string {
+	return strings.ToUpper(fmt.Sprintf("%08x-%04x-%04x-%x-%x",
+		binary.LittleEndian.Uint32(raw[0:4]),
+		binary.LittleEndian.Uint16(raw[4:6]),
+		binary.LittleEndian.Uint16(raw[6:8]),
+		raw[8:10],
+		raw[10:16],
+	))
+}
+
+func decodeGPTName(raw []byte) string {
+	var name []uint16
+	for index := 0; index+1 < len(raw); index += 2 {
+		char := binary.LittleEndian.Uint16(raw[index:])
+		if char == 0 {
+			break
+		}
+		name = append(name, char)
+	}
+	return string(utf16.Decode(name))
+}
@@ -0,0 +1,209 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/crc32"
+	"indicer/lib/cnst"
+	"indicer/lib/structs"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+const (
+	testSectorSize      = 512
+	testDiskSectors     = 64
+	testGPTEntries      = 4
+	testGPTEntriesLBA   = 2
+	testGPTBackupLBA    = testDiskSectors - 1
+	testGPTBackupTables = testDiskSectors - 2
+	testGPTEFI          = "C12A7328-F81F-11D2-BA4B-00A0C93EC93B"
+	testGPTLinux        = "0FC63DAF-8483-4772-8E79-3D69D8477DE4"
+)
+
+// testGPTEntry is a partition entry of a test disk
+type testGPTEntry struct {
+	typeGUID   string
+	guid       string
+	firstLBA   uint64
+	lastLBA    uint64
+	attributes uint64
+	label      string
+}
+
+var testGPTPartitions = []testGPTEntry{
+	{testGPTEFI, "11111111-2222-3333-4444-555555555555", 4, 19, 0, "EFI system"},
+	{testGPTLinux, "66666666-7777-8888-9999-AAAAAAAAAAAA", 20, 59, 1 << 60, "root"},
+}
+
+// gptTestDisk builds a disk with 512 byte sectors, a protective MBR and a primary and backup
+// GPT of four entries. edit can change the entries before the CRCs are computed, and the disk after
+func gptTestDisk(editEntries func(entries []byte), edit func(disk []byte)) []byte {
+	disk := make([]byte, testDiskSectors*testSectorSize)
+	mbr := disk[:testSectorSize]
+	mbr[mbrTableOffset+4] = cnst.MBRTypeProtective
+	binary.LittleEndian.PutUint32(mbr[mbrTableOffset+8:], 1)
+	binary.LittleEndian.PutUint32(mbr[mbrTableOffset+12:], testDiskSectors-1)
+	mbr[bootSectorSigIndex] = 0x55
+	mbr[bootSectorSigIndex+1] = 0xAA
+
+	entries := make([]byte, testGPTEntries*gptMinEntrySize)
+	for index, partition := range testGPTPartitions {
+		entry := entries[index*gptMinEntrySize:]
+		copy(entry, gptTestGUID(partition.typeGUID))
+		copy(entry[16:], gptTestGUID(partition.guid))
+		binary.LittleEndian.PutUint64(entry[32:], partition.firstLBA)
+		binary.LittleEndian.PutUint64(entry[40:], partition.lastLBA)
+		binary.LittleEndian.PutUint64(entry[48:], partition.attributes)
+		for char, value := range utf16.Encode([]rune(partition.label)) {
+			binary.LittleEndian.PutUint16(entry[gptNameOffset+char*2:], value)
+		}
+	}
+	if editEntries != nil {
+		editEntries(entries)
+	}
+
+	gptTestHeader(disk, gptHeaderLBA, testGPTBackupLBA, testGPTEntriesLBA, entries)
+	gptTestHeader(disk, testGPTBackupLBA, gptHeaderLBA, testGPTBackupTables, entries)
+	if edit != nil {
+		edit(disk)
+	}
+	return disk
+}
+
+func gptTestHeader(disk []byte, lba, backupLBA, entriesLBA int, entries []byte) {
+	copy(disk[entriesLBA*testSectorSize:], entries)
+	header := disk[lba*testSectorSize : lba*testSectorSize+gptMinHeaderSize]
+	copy(header, gptSignature)
+	binary.LittleEndian.PutUint32(header[8:], 0x00010000)
+	binary.LittleEndian.PutUint32(header[12:], gptMinHeaderSize)
+	binary.LittleEndian.PutUint64(header[24:], uint64(lba))
+	binary.LittleEndian.PutUint64(header[32:], uint64(backupLBA))
+	binary.LittleEndian.PutUint64(header[72:], uint64(entriesLBA))
+	binary.LittleEndian.PutUint32(header[80:], testGPTEntries)
+	binary.LittleEndian.PutUint32(header[84:], gptMinEntrySize)
+	binary.LittleEndian.PutUint32(header[88:], crc32.ChecksumIEEE(entries))
+	binary.LittleEndian.PutUint32(header[16:], crc32.ChecksumIEEE(header))
+}
+
+// gptTestGUID stores a GUID the way GPT does, with its first three fields little endian
+func gptTestGUID(guid string) []byte {
+	raw, err := hex.DecodeString(strings.ReplaceAll(guid, "-", ""))
+	if err != nil {
+		panic(err)
+	}
+	binary.LittleEndian.PutUint32(raw[0:], binary.BigEndian.Uint32(raw[0:]))
+	binary.LittleEndian.PutUint16(raw[4:], binary.BigEndian.Uint16(raw[4:]))
+	binary.LittleEndian.PutUint16(raw[6:], binary.BigEndian.Uint16(raw[6:]))
+	return raw
+}
+
+// testPartitionWant is what a partition found on a test disk is expected to look like
+type testPartitionWant struct {
+	startLBA int64
+	sectors  int64
+	table    structs.PartitionTableEntry
+}
+
+func testComparePartitions(t *testing.T, got []structs.PartitionFile, want []testPartitionWant, blockSize int64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d partitions, want %d", len(got), len(want))
+	}
+	for index := range want {
+		if got[index].Start != want[index].startLBA*blockSize || got[index].Size != want[index].sectors*blockSize || got[index].Table != want[index].table {
+			t.Errorf("partition %d: got %d+%d %+v, want %+v", index, got[index].Start, got[index].Size, got[index].Table, want[index])
+		}
+	}
+}
+
+// testWithinDisk fails when a partition does not lie within a disk of size bytes
+func testWithinDisk(t *testing.T, partitions []structs.PartitionFile, size int64) {
+	t.Helper()
+	for _, partition := range partitions {
+		if partition.Start < 0 || partition.Size <= 0 || partition.Start+partition.Size > size {
+			t.Fatalf("partition %d+%d of a %d byte disk", partition.Start, partition.Size, size)
+		}
+	}
+}
+
+func TestParseGPT(t *testing.T) {
+	var valid []testPartitionWant
+	for _, partition := range testGPTPartitions {
+		valid = append(valid, testPartitionWant{
+			startLBA: int64(partition.firstLBA),
+			sectors:  int64(partition.lastLBA - partition.firstLBA + 1),
+			table: structs.PartitionTableEntry{
+				Scheme:     structs.SchemeGPT,
+				TypeGUID:   partition.typeGUID,
+				GUID:       partition.guid,
+				Label:      partition.label,
+				Attributes: partition.attributes,
+			},
+		})
+	}
+	primaryEntries := testGPTEntriesLBA * testSectorSize
+	backupHeader := testGPTBackupLBA * testSectorSize
+
+	tests := []struct {
+		name        string
+		editEntries func(entries []byte)
+		edit        func(disk []byte)
+		want        []testPartitionWant
+	}{
+		{name: "valid", want: valid},
+		{name: "primary header corrupt", edit: func(disk []byte) { disk[testSectorSize+24]++ }, want: valid},
+		{name: "primary entries corrupt", edit: func(disk []byte) { disk[primaryEntries+32]++ }, want: valid},
+		{
+			name: "both headers corrupt",
+			edit: func(disk []byte) {
+				disk[testSectorSize+24]++
+				disk[backupHeader+24]++
+			},
+		},
+		{
+			name: "last LBA overflowing the partition size",
+			editEntries: func(entries []byte) {
+				binary.LittleEndian.PutUint64(entries[gptMinEntrySize+40:], 1<<62)
+			},
+			want: valid[:1],
+		},
+		{
+			name: "partition past the end of the disk",
+			editEntries: func(entries []byte) {
+				binary.LittleEndian.PutUint64(entries[gptMinEntrySize+40:], testDiskSectors)
+			},
+			want: valid[:1],
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			disk := gptTestDisk(test.editEntries, test.edit)
+			testComparePartitions(t, parseGPT(int64(len(disk)), bytes.NewReader(disk)), test.want, testSectorSize)
+		})
+	}
+}
+
+func TestParseGPTEntriesPastTheDisk(t *testing.T) {
+	disk := gptTestDisk(nil, nil)
+	header := disk[testSectorSize : testSectorSize+gptMinHeaderSize]
+	binary.LittleEndian.PutUint64(header[72:], 1<<40)
+	binary.LittleEndian.PutUint32(header[80:], gptMaxEntries)
+	clear(header[16:20])
+	binary.LittleEndian.PutUint32(header[16:], crc32.ChecksumIEEE(header))
+
+	_, err := readGPTHeader(int64(len(disk)), bytes.NewReader(disk), testSectorSize, gptHeaderLBA)
+	if err != cnst.ErrGPTHeader {
+		t.Fatalf("got %v, want %v", err, cnst.ErrGPTHeader)
+	}
+}
+
+func FuzzParseGPT(f *testing.F) {
+	f.Add(gptTestDisk(nil, nil))
+	f.Fuzz(func(t *testing.T, disk []byte) {
+		testWithinDisk(t, parseGPT(int64(len(disk)), bytes.NewReader(disk)), int64(len(disk)))
+	})
+}
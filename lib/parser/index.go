@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"errors"
+	"indicer/lib/cnst"
+	"indicer/lib/dbio"
+	"indicer/lib/structs"
+	"indicer/lib/util"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/schollz/progressbar/v3"
+)
+
+// IndexFiles stores the indexed files of pfile from entries hashed by the ingest pass,
+// entries the pass could not hash completely are left out
+func IndexFiles(pfile *structs.InputFile, entries []*structs.IngestRange) error {
+	encodedPfileHash, err := pfile.GetEncodedHash()
+	if err != nil {
+		return err
+	}
+
+	bar := progressbar.Default(int64(len(entries)), "indexing files")
+	idxmap := make(map[string]structs.IndexedFile)
+	for _, entry := range entries {
+		bar.Add(1)
+		if !entry.Hashed() {
+			continue
+		}
+
+		iname := string(util.AppendToBytesSlice(pfile.GetEviFileHash(), cnst.DataSeperator, encodedPfileHash, cnst.DataSeperator, entry.Name))
+		if val, ok := idxmap[string(entry.Hash)]; ok {
+			val.Names[iname] = struct{}{}
+			val.MergeRecovery(entry.Deleted, entry.RecoveryConfidence)
+			val.MergeSpace(entry.Space)
+			idxmap[string(entry.Hash)] = val
+		} else {
+			idxfile := structs.NewIndexedFile(iname, entry.Start, entry.Size)
+			idxfile.Digests = entry.Digests
+			idxfile.Times = entry.Times
+			idxfile.Extents = entry.Extents
+			idxfile.Deleted = entry.Deleted
+			idxfile.RecoveryConfidence = entry.RecoveryConfidence
+			idxfile.Space = entry.Space
+			idxmap[string(entry.Hash)] = idxfile
+		}
+		pfile.UpdateInternalObjects(entry.Start, entry.Size, entry.Hash, entry.Extents...)
+	}
+	bar.Finish()
+
+	batch, err := util.InitBatch(pfile.GetDB())
+	if err != nil {
+		return err
+	}
+	err = storeIndexedFiles(idxmap, pfile.GetDB(), batch)
+	if err != nil {
+		return err
+	}
+	return batch.Flush()
+}
+
+func storeIndexedFiles(idxmap map[string]structs.IndexedFile, db *badger.DB, batch *badger.WriteBatch) error {
+	for ihash, newIdxfile := range idxmap {
+		id := util.AppendToBytesSlice(cnst.IdxFileNamespace, ihash)
+		oldIdxFile, err := dbio.GetIndexedFile(id, db)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			err = dbio.SetIndexedFile(id, newIdxfile, batch)
+			if err != nil {
+				return err
+			}
+			err = dbio.SetBatchDigestIndex(newIdxfile.Digests, []byte(ihash), batch)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		recovery := oldIdxFile.MergeRecovery(newIdxfile.Deleted, newIdxfile.RecoveryConfidence)
+		space := oldIdxFile.MergeSpace(newIdxfile.Space)
+		flag := !recovery && !space
+		for newName := range newIdxfile.Names {
+			if _, ok := oldIdxFile.Names[newName]; !ok {
+				oldIdxFile.Names[newName] = struct{}{}
+				flag = false
+			}
+		}
+		if flag {
+			continue
+		}
+		err = dbio.SetIndexedFile(id, oldIdxFile, batch)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
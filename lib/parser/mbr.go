@@ -1,34 +1,129 @@
 package parser
 
 import (
+	"encoding/binary"
+	"indicer/lib/cnst"
 	"indicer/lib/structs"
-	"os"
+	"io"
+)
 
-	"github.com/aoiflux/libxfat"
-	"github.com/diskfs/go-diskfs/partition/mbr"
+const (
+	mbrTableOffset = 446
+	mbrEntrySize   = 16
+	mbrEntryCount  = 4
+	mbrActive      = 0x80
+	mbrMaxLogical  = 128
 )
 
-func parseMBR(size int64, fhandle *os.File) []structs.PartitionFile {
-	mbrdata, err := mbr.Read(fhandle, 0, 0)
+type mbrEntry struct {
+	status   byte
+	ptype    byte
+	startLBA int64
+	sectors  int64
+}
+
+func (e mbrEntry) isEmpty() bool {
+	return e.ptype == cnst.MBRTypeEmpty || e.sectors == 0
+}
+
+func (e mbrEntry) isExtended() bool {
+	return e.ptype == cnst.MBRTypeExtended || e.ptype == cnst.MBRTypeExtendedLBA || e.ptype == cnst.MBRTypeExtendedLinux
+}
+
+// parseMBR returns every primary and logical partition of an MBR, whatever its type.
+// Extended partitions are only containers, the logical partitions in their EBR chain are returned instead
+func parseMBR(size int64, r io.ReaderAt) []structs.PartitionFile {
+	entries, err := readMBRTable(r, 0)
 	if err != nil {
 		return nil
 	}
 
 	var plist []structs.PartitionFile
-	for _, partition := range mbrdata.Partitions {
-		if partition.GetSize() == 0 || partition.GetSize()*int64(libxfat.SECTOR_SIZE) > size {
+	for _, entry := range entries {
+		if entry.isEmpty() || entry.ptype == cnst.MBRTypeProtective {
 			continue
 		}
-		if _, err := libxfat.New(fhandle, true, uint64(partition.GetStart())); err != nil {
+		if entry.isExtended() {
+			plist = append(plist, parseEBRChain(size, r, entry.startLBA)...)
 			continue
 		}
+		if pfile, ok := mbrPartition(size, entry, 0, false); ok {
+			plist = append(plist, pfile)
+		}
+	}
+	return plist
+}
+
+// parseEBRChain follows the EBRs of an extended partition. Each EBR holds a logical
+// partition relative to itself and a link relative to the start of the extended partition
+func parseEBRChain(size int64, r io.ReaderAt, extendedLBA int64) []structs.PartitionFile {
+	var plist []structs.PartitionFile
+	visited := make(map[int64]struct{})
+	for ebrLBA := extendedLBA; len(visited) < mbrMaxLogical; {
+		if _, ok := visited[ebrLBA]; ok {
+			break
+		}
+		visited[ebrLBA] = struct{}{}
+
+		entries, err := readMBRTable(r, ebrLBA*int64(cnst.SectorSize))
+		if err != nil {
+			break
+		}
+		if !entries[0].isEmpty() {
+			if pfile, ok := mbrPartition(size, entries[0], ebrLBA, true); ok {
+				plist = append(plist, pfile)
+			}
+		}
+		if entries[1].isEmpty() || !entries[1].isExtended() {
+			break
+		}
+		ebrLBA = extendedLBA + entries[1].startLBA
+	}
+	return plist
+}
 
-		var pfile structs.PartitionFile
-		pfile.Start = partition.GetStart() * int64(libxfat.SECTOR_SIZE)
-		pfile.Size = partition.GetSize() * int64(libxfat.SECTOR_SIZE)
+func readMBRTable(r io.ReaderAt, offset int64) ([mbrEntryCount]mbrEntry, error) {
+	var entries [mbrEntryCount]mbrEntry
 
-		plist = append(plist, pfile)
+	sector := make([]byte, cnst.SectorSize)
+	_, err := r.ReadAt(sector, offset)
+	if err != nil {
+		return entries, err
+	}
+	if sector[bootSectorSigIndex] != 0x55 || sector[bootSectorSigIndex+1] != 0xAA {
+		return entries, cnst.ErrMBRSignature
 	}
 
-	return plist
+	for index := range entries {
+		raw := sector[mbrTableOffset+index*mbrEntrySize : mbrTableOffset+(index+1)*mbrEntrySize]
+		entries[index] = mbrEntry{
+			status:   raw[0],
+			ptype:    raw[4],
+			startLBA: int64(binary.LittleEndian.Uint32(raw[8:12])),
+			sectors:  int64(binary.LittleEndian.Uint32(raw[12:16])),
+		}
+		// boot code in the table area of a volume boot record rarely passes for status bytes
+		if entries[index].status != 0 && entries[index].status != mbrActive {
+			return entries, cnst.ErrMBRSignature
+		}
+	}
+	return entries, nil
+}
+
+func mbrPartition(size int64, entry mbrEntry, baseLBA int64, logical bool) (structs.PartitionFile, bool) {
+	var pfile structs.PartitionFile
+	start := (baseLBA + entry.startLBA) * int64(cnst.SectorSize)
+	length := entry.sectors * int64(cnst.SectorSize)
+	if entry.startLBA == 0 || start+length > size {
+		return pfile, false
+	}
+
+	pfile.Start = start
+	pfile.Size = length
+	pfile.Table = structs.PartitionTableEntry{
+		Scheme:  structs.SchemeMBR,
+		Type:    entry.ptype,
+		Logical: logical,
+	}
+	return pfile, true
 }
@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"indicer/lib/cnst"
+	"indicer/lib/structs"
+	"testing"
+)
+
+// testMBREntry is a partition table entry, relative to the table it is in
+type testMBREntry struct {
+	ptype    byte
+	startLBA uint32
+	sectors  uint32
+}
+
+// mbrTestTable writes a partition table with its boot signature into the sector at lba
+func mbrTestTable(disk []byte, lba int, entries ...testMBREntry) {
+	sector := disk[lba*testSectorSize : (lba+1)*testSectorSize]
+	clear(sector[mbrTableOffset : mbrTableOffset+mbrEntryCount*mbrEntrySize])
+	for index, entry := range entries {
+		raw := sector[mbrTableOffset+index*mbrEntrySize:]
+		raw[4] = entry.ptype
+		binary.LittleEndian.PutUint32(raw[8:], entry.startLBA)
+		binary.LittleEndian.PutUint32(raw[12:], entry.sectors)
+	}
+	sector[bootSectorSigIndex] = 0x55
+	sector[bootSectorSigIndex+1] = 0xAA
+}
+
+// mbrTestDisk has two primary partitions and an extended partition at LBA 30 holding two
+// logical partitions, edit can change the disk afterwards
+func mbrTestDisk(edit func(disk []byte)) []byte {
+	disk := make([]byte, testDiskSectors*testSectorSize)
+	mbrTestTable(disk, 0, testMBREntry{0x07, 2, 8}, testMBREntry{0x83, 10, 16}, testMBREntry{cnst.MBRTypeExtended, 30, 30})
+	// each EBR links to the next relative to the start of the extended partition
+	mbrTestTable(disk, 30, testMBREntry{0x0B, 1, 9}, testMBREntry{cnst.MBRTypeExtended, 10, 20})
+	mbrTestTable(disk, 40, testMBREntry{0x83, 1, 19})
+	if edit != nil {
+		edit(disk)
+	}
+	return disk
+}
+
+func TestParseMBR(t *testing.T) {
+	primary := []testPartitionWant{
+		{2, 8, structs.PartitionTableEntry{Scheme: structs.SchemeMBR, Type: 0x07}},
+		{10, 16, structs.PartitionTableEntry{Scheme: structs.SchemeMBR, Type: 0x83}},
+	}
+	first := testPartitionWant{31, 9, structs.PartitionTableEntry{Scheme: structs.SchemeMBR, Type: 0x0B, Logical: true}}
+	second := testPartitionWant{41, 19, structs.PartitionTableEntry{Scheme: structs.SchemeMBR, Type: 0x83, Logical: true}}
+
+	tests := []struct {
+		name string
+		edit func(disk []byte)
+		want []testPartitionWant
+	}{
+		{name: "primary and logical partitions", want: append(primary[:2:2], first, second)},
+		{
+			name: "EBR linking back to itself",
+			edit: func(disk []byte) {
+				mbrTestTable(disk, 30, testMBREntry{0x0B, 1, 9}, testMBREntry{cnst.MBRTypeExtended, 0, 20})
+			},
+			want: append(primary[:2:2], first),
+		},
+		{
+			name: "EBR without a boot signature",
+			edit: func(disk []byte) { disk[40*testSectorSize+bootSectorSigIndex] = 0 },
+			want: append(primary[:2:2], first),
+		},
+		{
+			name: "partition past the end of the disk",
+			edit: func(disk []byte) {
+				mbrTestTable(disk, 0, testMBREntry{0x07, 2, 8}, testMBREntry{0x83, 10, testDiskSectors})
+			},
+			want: primary[:1],
+		},
+		{
+			name: "protective MBR",
+			edit: func(disk []byte) { mbrTestTable(disk, 0, testMBREntry{cnst.MBRTypeProtective, 1, testDiskSectors - 1}) },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			disk := mbrTestDisk(test.edit)
+			testComparePartitions(t, GetPartitions(int64(len(disk)), bytes.NewReader(disk)), test.want, testSectorSize)
+		})
+	}
+}
+
+func FuzzParseMBR(f *testing.F) {
+	f.Add(mbrTestDisk(nil))
+	f.Fuzz(func(t *testing.T, disk []byte) {
+		testWithinDisk(t, parseMBR(int64(len(disk)), bytes.NewReader(disk)), int64(len(disk)))
+	})
+}
@@ -0,0 +1,452 @@
+package parser
+
+import (
+	"cmp"
+	"encoding/binary"
+	"indicer/lib/cnst"
+	"indicer/lib/structs"
+	"io"
+	"path"
+	"slices"
+	"unicode/utf16"
+)
+
+const (
+	ntfsSignature        = "NTFS    "
+	ntfsRecordSignature  = "FILE"
+	ntfsRootRecord       = 5
+	ntfsMFTRecord        = 0
+	ntfsBitmapRecord     = 6
+	ntfsFixupStride      = 512
+	ntfsRecordInUse      = 0x0001
+	ntfsRecordDir        = 0x0002
+	ntfsAttrFileName     = 0x30
+	ntfsAttrData         = 0x80
+	ntfsAttrEnd          = 0xFFFFFFFF
+	ntfsFlagCompressed   = 0x0001
+	ntfsFlagEncrypted    = 0x4000
+	ntfsFlagSparse       = 0x8000
+	ntfsNamespaceDOS     = 2
+	ntfsRecordNumberMask = 0x0000FFFFFFFFFFFF
+	ntfsMaxPathDepth     = 256
+	ntfsOrphanDir        = "$OrphanFiles"
+	ntfsReadRecords      = 1024
+	ntfsMaxClusterSize   = 2 * cnst.MB
+	// ntfsResidentHeaderSize is where the value of a resident attribute can start at the earliest
+	ntfsResidentHeaderSize = 24
+)
+
+// ntfsVolume is an NTFS file system found at start of the evidence, all offsets are absolute
+type ntfsVolume struct {
+	r            io.ReaderAt
+	start        int64
+	clusterSize  int64
+	clusterCount int64
+	recordSize   int64
+	mftOffset    int64
+}
+
+// ntfsFile collects what the MFT says about a file, its base record and extension records merged
+type ntfsFile struct {
+	name      string
+	parent    uint64
+	namespace byte
+	flags     uint16
+	// data is the unnamed $DATA attribute, split over extension records when it has many runs
+	data     []ntfsData
+	resident *run
+}
+
+type ntfsData struct {
+	startVCN    uint64
+	size        int64
+	initialized int64
+	flags       uint16
+	runs        []run
+	sparse      bool
+}
+
+func isNTFS(r io.ReaderAt, start int64) bool {
+	_, err := openNTFS(r, start)
+	return err == nil
+}
+
+// ListNTFS returns the files of the NTFS volume at partition named by their full path,
+// their hashes are filled in by the ingest pass. Only files whose data is stored as is
+// are listed, compressed, encrypted and sparse files are left out. File slack, the clusters
+// $Bitmap marks as free and volume slack are listed after the files
+func ListNTFS(r io.ReaderAt, partition structs.PartitionFile) ([]*structs.IngestRange, error) {
+	volume, err := openNTFS(r, partition.Start)
+	if err != nil {
+		return nil, cnst.ErrIncompatibleFileSystem
+	}
+
+	files, err := volume.readMFT()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*structs.IngestRange
+	for number, file := range files {
+		if file.flags&ntfsRecordInUse == 0 || file.flags&ntfsRecordDir != 0 || file.name == "" {
+			continue
+		}
+		runs, size, ok := file.runs()
+		if !ok || size == 0 {
+			continue
+		}
+		entries = append(entries, newIngestRange(ntfsPath(files, number), runs, size))
+	}
+	slices.SortFunc(entries, func(a, b *structs.IngestRange) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
+
+	free, err := volume.freeRuns(files)
+	if err != nil {
+		return nil, err
+	}
+	end := volume.start + volume.clusterCount*volume.clusterSize
+	return append(entries, spaceRanges(entries, free, volume.start, volume.clusterSize, end, partition)...), nil
+}
+
+// freeRuns returns the runs of the clusters that are clear in $Bitmap, none when its data cannot be read as is
+func (v *ntfsVolume) freeRuns(files map[uint64]*ntfsFile) ([]run, error) {
+	file, ok := files[ntfsBitmapRecord]
+	if !ok {
+		return nil, nil
+	}
+	runs, size, ok := file.runs()
+	// a bitmap much larger than the volume needs is not trusted
+	if !ok || len(runs) == 0 || size > v.clusterCount/8+v.clusterSize {
+		return nil, nil
+	}
+	// nor is one that runs past the end of the evidence
+	last := runs[len(runs)-1]
+	_, err := v.r.ReadAt(make([]byte, 1), last.start+last.size-1)
+	if err != nil {
+		return nil, nil
+	}
+
+	bitmap := make([]byte, size)
+	var offset int64
+	for _, bitmapRun := range runs {
+		_, err := v.r.ReadAt(bitmap[offset:offset+bitmapRun.size], bitmapRun.start)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		offset += bitmapRun.size
+	}
+	return freeRuns(bitmap, uint64(v.clusterCount), v.start, v.clusterSize), nil
+}
+
+func openNTFS(r io.ReaderAt, start int64) (*ntfsVolume, error) {
+	boot := make([]byte, cnst.SectorSize)
+	_, err := r.ReadAt(boot, start)
+	if err != nil {
+		return nil, err
+	}
+	if string(boot[3:11]) != ntfsSignature || boot[bootSectorSigIndex] != 0x55 || boot[bootSectorSigIndex+1] != 0xAA {
+		return nil, cnst.ErrIncompatibleFileSystem
+	}
+
+	sectorSize := int64(binary.LittleEndian.Uint16(boot[0x0B:]))
+	if sectorSize < 256 || sectorSize > 4096 || sectorSize&(sectorSize-1) != 0 {
+		return nil, cnst.ErrIncompatibleFileSystem
+	}
+	clusterSize := sectorSize * int64(boot[0x0D])
+	// cluster sizes over 64K are stored as a negative power of two
+	if boot[0x0D] > 0x80 {
+		clusterSize = int64(1) << (256 - int(boot[0x0D]))
+	}
+
+	recordSize := int64(int8(boot[0x40])) * clusterSize
+	if int8(boot[0x40]) < 0 {
+		recordSize = int64(1) << -int(int8(boot[0x40]))
+	}
+	if clusterSize <= 0 || clusterSize > ntfsMaxClusterSize || recordSize < ntfsFixupStride || recordSize > 64*cnst.KB {
+		return nil, cnst.ErrIncompatibleFileSystem
+	}
+
+	return &ntfsVolume{
+		r:            r,
+		start:        start,
+		clusterSize:  clusterSize,
+		clusterCount: int64(binary.LittleEndian.Uint64(boot[0x28:])) * sectorSize / clusterSize,
+		recordSize:   recordSize,
+		mftOffset:    start + int64(binary.LittleEndian.Uint64(boot[0x30:]))*clusterSize,
+	}, nil
+}
+
+// readMFT reads every record of the MFT, whose own runs are found in its first record
+func (v *ntfsVolume) readMFT() (map[uint64]*ntfsFile, error) {
+	record := make([]byte, v.recordSize)
+	_, err := v.r.ReadAt(record, v.mftOffset)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[uint64]*ntfsFile)
+	err = v.parseRecord(record, ntfsMFTRecord, v.mftOffset, files)
+	if err != nil {
+		return nil, err
+	}
+	mft, ok := files[ntfsMFTRecord]
+	if !ok {
+		return nil, cnst.ErrIncompatibleFileSystem
+	}
+	mftRuns, mftSize, ok := mft.runs()
+	if !ok {
+		return nil, cnst.ErrIncompatibleFileSystem
+	}
+
+	number := uint64(0)
+	buffer := make([]byte, ntfsReadRecords*v.recordSize)
+	for _, mftRun := range mftRuns {
+		for offset := int64(0); offset+v.recordSize <= mftRun.size && int64(number)*v.recordSize < mftSize; {
+			chunk := buffer[:min(int64(len(buffer)), (mftRun.size-offset)/v.recordSize*v.recordSize)]
+			n, err := v.r.ReadAt(chunk, mftRun.start+offset)
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			// an MFT running past the end of the evidence is read as far as it goes
+			if err == io.EOF {
+				chunk = chunk[:int64(n)/v.recordSize*v.recordSize]
+				mftSize = min(mftSize, int64(number)*v.recordSize+int64(len(chunk)))
+			}
+			for recordOffset := int64(0); recordOffset < int64(len(chunk)); recordOffset += v.recordSize {
+				if number != ntfsMFTRecord {
+					err = v.parseRecord(chunk[recordOffset:recordOffset+v.recordSize], number, mftRun.start+offset+recordOffset, files)
+					if err != nil {
+						return nil, err
+					}
+				}
+				number++
+			}
+			offset += int64(len(chunk))
+		}
+	}
+	return files, nil
+}
+
+// parseRecord applies the update sequence fixups of a record and adds its attributes
+// to the file it belongs to, diskOffset is where the record is stored
+func (v *ntfsVolume) parseRecord(record []byte, number uint64, diskOffset int64, files map[uint64]*ntfsFile) error {
+	if string(record[:4]) != ntfsRecordSignature || !applyFixups(record) {
+		return nil
+	}
+
+	flags := binary.LittleEndian.Uint16(record[0x16:])
+	base := binary.LittleEndian.Uint64(record[0x20:]) & ntfsRecordNumberMask
+	owner := number
+	if base != 0 {
+		owner = base
+	}
+	file, ok := files[owner]
+	if !ok {
+		file = &ntfsFile{}
+		files[owner] = file
+	}
+	if base == 0 {
+		file.flags = flags
+	}
+
+	for offset := int(binary.LittleEndian.Uint16(record[0x14:])); offset+16 <= len(record); {
+		atype := binary.LittleEndian.Uint32(record[offset:])
+		length := int(binary.LittleEndian.Uint32(record[offset+4:]))
+		if atype == ntfsAttrEnd || length < 16 || offset+length > len(record) {
+			break
+		}
+		attr := record[offset : offset+length]
+		nonResident := attr[8] != 0
+		nameLength := attr[9]
+
+		switch {
+		case atype == ntfsAttrFileName && !nonResident:
+			file.addName(attr)
+		case atype == ntfsAttrData && nameLength == 0 && !nonResident:
+			file.resident = residentRun(attr, diskOffset, int64(offset))
+		case atype == ntfsAttrData && nameLength == 0:
+			data, ok := v.parseNonResident(attr)
+			if ok {
+				file.data = append(file.data, data)
+			}
+		}
+		offset += length
+	}
+	return nil
+}
+
+// applyFixups puts back the last two bytes of every sector of a record, which were swapped
+// for the update sequence number when it was written. A torn record fails the check
+func applyFixups(record []byte) bool {
+	usaOffset := int(binary.LittleEndian.Uint16(record[4:]))
+	usaCount := int(binary.LittleEndian.Uint16(record[6:]))
+	if usaCount == 0 || usaOffset+usaCount*2 > len(record) || (usaCount-1)*ntfsFixupStride > len(record) {
+		return false
+	}
+
+	usn := record[usaOffset : usaOffset+2]
+	for index := 1; index < usaCount; index++ {
+		end := index*ntfsFixupStride - 2
+		if record[end] != usn[0] || record[end+1] != usn[1] {
+			return false
+		}
+		copy(record[end:end+2], record[usaOffset+index*2:usaOffset+index*2+2])
+	}
+	return true
+}
+
+func (f *ntfsFile) addName(attr []byte) {
+	if len(attr) < ntfsResidentHeaderSize {
+		return
+	}
+	valueOffset := int(binary.LittleEndian.Uint16(attr[20:]))
+	if valueOffset+66 > len(attr) {
+		return
+	}
+	value := attr[valueOffset:]
+	nameLength := int(value[64])
+	namespace := value[65]
+	if len(value) < 66+nameLength*2 {
+		return
+	}
+	// the short DOS name is only used when a file has no other
+	if f.name != "" && (namespace == ntfsNamespaceDOS || f.namespace != ntfsNamespaceDOS) {
+		return
+	}
+
+	chars := make([]uint16, nameLength)
+	for index := range chars {
+		chars[index] = binary.LittleEndian.Uint16(value[66+index*2:])
+	}
+	f.name = string(utf16.Decode(chars))
+	f.namespace = namespace
+	f.parent = binary.LittleEndian.Uint64(value[:8]) & ntfsRecordNumberMask
+}
+
+// residentRun locates resident data inside the record stored at recordOffset, data
+// crossing a fixup is not stored as is on disk and gets no run
+func residentRun(attr []byte, recordOffset, attrOffset int64) *run {
+	if len(attr) < ntfsResidentHeaderSize {
+		return nil
+	}
+	size := int64(binary.LittleEndian.Uint32(attr[16:]))
+	valueOffset := int64(binary.LittleEndian.Uint16(attr[20:]))
+	if valueOffset+size > int64(len(attr)) {
+		return nil
+	}
+
+	start := recordOffset + attrOffset + valueOffset
+	for fixup := recordOffset + ntfsFixupStride - 2; fixup < start+size; fixup += ntfsFixupStride {
+		if fixup+2 > start {
+			return nil
+		}
+	}
+	return &run{start: start, size: size}
+}
+
+func (v *ntfsVolume) parseNonResident(attr []byte) (ntfsData, bool) {
+	if len(attr) < 64 {
+		return ntfsData{}, false
+	}
+	data := ntfsData{
+		startVCN:    binary.LittleEndian.Uint64(attr[16:]),
+		flags:       binary.LittleEndian.Uint16(attr[12:]),
+		size:        int64(binary.LittleEndian.Uint64(attr[48:])),
+		initialized: int64(binary.LittleEndian.Uint64(attr[56:])),
+	}
+
+	var lcn int64
+	for offset := int(binary.LittleEndian.Uint16(attr[32:])); offset < len(attr) && attr[offset] != 0; {
+		lengthSize := int(attr[offset] & 0x0F)
+		offsetSize := int(attr[offset] >> 4)
+		if lengthSize == 0 || lengthSize > 8 || offsetSize > 8 || offset+1+lengthSize+offsetSize > len(attr) {
+			return data, false
+		}
+		clusters := readVarInt(attr[offset+1:offset+1+lengthSize], false)
+		if offsetSize == 0 {
+			data.sparse = true
+		} else {
+			lcn += readVarInt(attr[offset+1+lengthSize:offset+1+lengthSize+offsetSize], true)
+			data.runs = append(data.runs, run{start: v.start + lcn*v.clusterSize, size: clusters * v.clusterSize})
+		}
+		offset += 1 + lengthSize + offsetSize
+	}
+	return data, true
+}
+
+// readVarInt reads the little endian integers of a run list, offsets are signed
+func readVarInt(raw []byte, signed bool) int64 {
+	var value int64
+	for index := len(raw) - 1; index >= 0; index-- {
+		value = value<<8 | int64(raw[index])
+	}
+	if signed && len(raw) < 8 && raw[len(raw)-1]&0x80 != 0 {
+		value -= int64(1) << (8 * len(raw))
+	}
+	return value
+}
+
+// runs returns the runs of the unnamed data stream in file order cut to its size, merging
+// runs that follow each other on disk. ok is false when the data is not stored as is
+func (f *ntfsFile) runs() ([]run, int64, bool) {
+	if f.resident != nil {
+		return []run{*f.resident}, f.resident.size, true
+	}
+	if len(f.data) == 0 {
+		return nil, 0, false
+	}
+
+	slices.SortFunc(f.data, func(a, b ntfsData) int {
+		return cmp.Compare(a.startVCN, b.startVCN)
+	})
+	first := f.data[0]
+	if first.startVCN != 0 || first.size < 0 || first.initialized < first.size {
+		return nil, 0, false
+	}
+
+	var runs []run
+	remaining := first.size
+	for _, data := range f.data {
+		if data.sparse || data.flags&(ntfsFlagCompressed|ntfsFlagEncrypted|ntfsFlagSparse) != 0 {
+			return nil, 0, false
+		}
+		for _, dataRun := range data.runs {
+			if remaining <= 0 {
+				break
+			}
+			if dataRun.size <= 0 || dataRun.start < 0 {
+				return nil, 0, false
+			}
+			dataRun.size = min(dataRun.size, remaining)
+			remaining -= dataRun.size
+			runs = appendRun(runs, dataRun)
+		}
+	}
+	if remaining > 0 {
+		return nil, 0, false
+	}
+	return runs, first.size, true
+}
+
+// ntfsPath builds the full path of a file from the parents in its file names,
+// files whose parents cannot be followed to the root are put under $OrphanFiles
+func ntfsPath(files map[uint64]*ntfsFile, number uint64) string {
+	var parts []string
+	current := number
+	for depth := 0; depth < ntfsMaxPathDepth; depth++ {
+		file, ok := files[current]
+		if !ok || file.name == "" || file.flags&ntfsRecordInUse == 0 {
+			break
+		}
+		parts = append(parts, file.name)
+		if file.parent == ntfsRootRecord {
+			slices.Reverse(parts)
+			return "/" + path.Join(parts...)
+		}
+		if file.parent == current {
+			break
+		}
+		current = file.parent
+	}
+	return "/" + path.Join(ntfsOrphanDir, files[number].name)
+}
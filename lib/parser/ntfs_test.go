@@ -0,0 +1,218 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"indicer/lib/structs"
+	"testing"
+	"unicode/utf16"
+)
+
+const (
+	testNTFSCluster    = 512
+	testNTFSRecordSize = 1024
+	testNTFSClusters   = 64
+	testNTFSMFTCluster = 4
+	testNTFSRecords    = 9
+	testNTFSDataLCN    = 24
+)
+
+// ntfsTestImage builds a small NTFS volume with 512 byte clusters and 1K records: the MFT,
+// the root directory, $Bitmap, /file.txt stored in a cluster of its own and /small.txt resident
+// in its record. edit can change a record before its fixups are applied
+func ntfsTestImage(edit func(number int, record []byte)) []byte {
+	image := make([]byte, testNTFSClusters*testNTFSCluster)
+	boot := image[:testNTFSCluster]
+	copy(boot[3:], ntfsSignature)
+	binary.LittleEndian.PutUint16(boot[0x0B:], testNTFSCluster)
+	boot[0x0D] = 1
+	binary.LittleEndian.PutUint64(boot[0x28:], testNTFSClusters)
+	binary.LittleEndian.PutUint64(boot[0x30:], testNTFSMFTCluster)
+	// -10 is a record of 1 << 10 bytes
+	boot[0x40] = 0xF6
+	boot[bootSectorSigIndex] = 0x55
+	boot[bootSectorSigIndex+1] = 0xAA
+
+	mftClusters := testNTFSRecords * testNTFSRecordSize / testNTFSCluster
+	bitmap := make([]byte, testNTFSClusters/8)
+	for cluster := 0; cluster < testNTFSDataLCN+1; cluster++ {
+		bitmap[cluster/8] |= 1 << (cluster % 8)
+	}
+	records := map[int][][]byte{
+		0: {ntfsTestName(ntfsRootRecord, "$MFT"), ntfsTestNonResident(testNTFSMFTCluster, mftClusters, testNTFSRecords*testNTFSRecordSize)},
+		5: {ntfsTestName(ntfsRootRecord, ".")},
+		6: {ntfsTestName(ntfsRootRecord, "$Bitmap"), ntfsTestResident(ntfsAttrData, bitmap)},
+		7: {ntfsTestName(ntfsRootRecord, "file.txt"), ntfsTestNonResident(testNTFSDataLCN, 1, 11)},
+		8: {ntfsTestName(ntfsRootRecord, "small.txt"), ntfsTestResident(ntfsAttrData, []byte("tiny"))},
+	}
+	copy(image[testNTFSDataLCN*testNTFSCluster:], "hello world")
+
+	for number, attrs := range records {
+		flags := uint16(ntfsRecordInUse)
+		if number == ntfsRootRecord {
+			flags |= ntfsRecordDir
+		}
+		record := ntfsTestRecord(flags, attrs)
+		if edit != nil {
+			edit(number, record)
+		}
+		ntfsTestFixups(record)
+		copy(image[testNTFSMFTCluster*testNTFSCluster+number*testNTFSRecordSize:], record)
+	}
+	return image
+}
+
+// ntfsTestRecord lays out a file record with its update sequence array at 0x30 and attributes from 0x38
+func ntfsTestRecord(flags uint16, attrs [][]byte) []byte {
+	record := make([]byte, testNTFSRecordSize)
+	copy(record, ntfsRecordSignature)
+	binary.LittleEndian.PutUint16(record[4:], 0x30)
+	binary.LittleEndian.PutUint16(record[6:], 1+testNTFSRecordSize/ntfsFixupStride)
+	binary.LittleEndian.PutUint16(record[0x14:], 0x38)
+	binary.LittleEndian.PutUint16(record[0x16:], flags)
+	offset := 0x38
+	for _, attr := range attrs {
+		offset += copy(record[offset:], attr)
+	}
+	binary.LittleEndian.PutUint32(record[offset:], ntfsAttrEnd)
+	return record
+}
+
+// ntfsTestFixups swaps the last two bytes of every sector for the update sequence number
+func ntfsTestFixups(record []byte) {
+	binary.LittleEndian.PutUint16(record[0x30:], 1)
+	for index := 1; index <= testNTFSRecordSize/ntfsFixupStride; index++ {
+		end := index*ntfsFixupStride - 2
+		copy(record[0x30+index*2:], record[end:end+2])
+		binary.LittleEndian.PutUint16(record[end:], 1)
+	}
+}
+
+func ntfsTestResident(atype uint32, value []byte) []byte {
+	attr := make([]byte, (ntfsResidentHeaderSize+len(value)+7)&^7)
+	binary.LittleEndian.PutUint32(attr, atype)
+	binary.LittleEndian.PutUint32(attr[4:], uint32(len(attr)))
+	binary.LittleEndian.PutUint32(attr[16:], uint32(len(value)))
+	binary.LittleEndian.PutUint16(attr[20:], ntfsResidentHeaderSize)
+	copy(attr[ntfsResidentHeaderSize:], value)
+	return attr
+}
+
+func ntfsTestName(parent uint64, name string) []byte {
+	chars := utf16.Encode([]rune(name))
+	value := make([]byte, 66+len(chars)*2)
+	binary.LittleEndian.PutUint64(value, parent)
+	value[64] = byte(len(chars))
+	value[65] = 1
+	for index, char := range chars {
+		binary.LittleEndian.PutUint16(value[66+index*2:], char)
+	}
+	return ntfsTestResident(ntfsAttrFileName, value)
+}
+
+// ntfsTestNonResident is an unnamed $DATA attribute with a single run
+func ntfsTestNonResident(lcn, clusters int, size int64) []byte {
+	attr := make([]byte, 72)
+	binary.LittleEndian.PutUint32(attr, ntfsAttrData)
+	binary.LittleEndian.PutUint32(attr[4:], uint32(len(attr)))
+	attr[8] = 1
+	binary.LittleEndian.PutUint16(attr[32:], 64)
+	binary.LittleEndian.PutUint64(attr[48:], uint64(size))
+	binary.LittleEndian.PutUint64(attr[56:], uint64(size))
+	copy(attr[64:], []byte{0x11, byte(clusters), byte(lcn)})
+	return attr
+}
+
+// ntfsTestFileNameOffset is where the value offset of the $FILE_NAME of a test record is
+const ntfsTestFileNameOffset = 0x38 + 20
+
+func TestListNTFS(t *testing.T) {
+	smallData := 0x38 + len(ntfsTestName(ntfsRootRecord, "small.txt"))
+	smallStart := int64(testNTFSMFTCluster*testNTFSCluster + 8*testNTFSRecordSize + smallData + ntfsResidentHeaderSize)
+	tests := []struct {
+		name  string
+		edit  func(number int, record []byte)
+		files map[string]run
+	}{
+		{
+			name: "valid",
+			files: map[string]run{
+				"/file.txt":  {start: testNTFSDataLCN * testNTFSCluster, size: 11},
+				"/small.txt": {start: smallStart, size: 4},
+			},
+		},
+		{
+			name: "file name value offset past the attribute",
+			edit: func(number int, record []byte) {
+				if number == 7 {
+					binary.LittleEndian.PutUint16(record[ntfsTestFileNameOffset:], 0xFFF0)
+				}
+			},
+			files: map[string]run{
+				"/small.txt": {start: smallStart, size: 4},
+			},
+		},
+		{
+			name: "resident data value offset past the attribute",
+			edit: func(number int, record []byte) {
+				if number == 8 {
+					binary.LittleEndian.PutUint16(record[smallData+20:], 0xFFF0)
+				}
+			},
+			files: map[string]run{
+				"/file.txt": {start: testNTFSDataLCN * testNTFSCluster, size: 11},
+			},
+		},
+		{
+			name: "attribute too short for a resident header",
+			edit: func(number int, record []byte) {
+				if number == 7 {
+					binary.LittleEndian.PutUint32(record[0x38+4:], 16)
+				}
+			},
+			files: map[string]run{
+				"/small.txt": {start: smallStart, size: 4},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			image := ntfsTestImage(test.edit)
+			entries, err := ListNTFS(bytes.NewReader(image), structs.PartitionFile{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			found := make(map[string]run)
+			for _, entry := range entries {
+				if entry.Space == "" && entry.Name[1] != '$' {
+					found[entry.Name] = run{start: entry.Start, size: entry.Size}
+				}
+			}
+			if len(found) != len(test.files) {
+				t.Fatalf("got files %v, want %v", found, test.files)
+			}
+			for name, want := range test.files {
+				if found[name] != want {
+					t.Errorf("%s: got %+v, want %+v", name, found[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestListNTFSNotNTFS(t *testing.T) {
+	image := ntfsTestImage(nil)
+	copy(image[3:], "EXFAT   ")
+	_, err := ListNTFS(bytes.NewReader(image), structs.PartitionFile{})
+	if err == nil {
+		t.Fatal("a volume without the NTFS signature was listed")
+	}
+}
+
+func FuzzListNTFS(f *testing.F) {
+	f.Add(ntfsTestImage(nil))
+	f.Fuzz(func(t *testing.T, image []byte) {
+		ListNTFS(bytes.NewReader(image), structs.PartitionFile{})
+	})
+}
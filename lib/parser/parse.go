@@ -1,14 +1,173 @@
 package parser
 
 import (
+	"fmt"
+	"indicer/lib/cnst"
 	"indicer/lib/structs"
-	"os"
+	"io"
 )
 
-func GetPartitions(size int64, fhandle *os.File) []structs.PartitionFile {
-	plist := parseMBR(size, fhandle)
+func GetPartitions(size int64, r io.ReaderAt) []structs.PartitionFile {
+	// a GPT disk starts with a protective MBR, so the GPT is looked for first
+	plist := parseGPT(size, r)
 	if len(plist) > 0 {
 		return plist
 	}
-	return parsEXFAT(fhandle, size)
+	// the boot sector of a file system spanning the whole evidence file can pass for an MBR
+	plist = parseVolume(r, size)
+	if len(plist) > 0 {
+		return plist
+	}
+	return parseMBR(size, r)
+}
+
+// ListFiles returns the files of the file system found at partition
+func ListFiles(r io.ReaderAt, partition structs.PartitionFile) ([]*structs.IngestRange, error) {
+	fs, ok := detectFileSystem(r, partition.Start)
+	if !ok {
+		return nil, cnst.ErrIncompatibleFileSystem
+	}
+	return fs.list(r, partition)
+}
+
+// fileSystem is a file system DUES can index, detect checks for it at an offset of the evidence
+type fileSystem struct {
+	detect func(r io.ReaderAt, start int64) bool
+	list   func(r io.ReaderAt, partition structs.PartitionFile) ([]*structs.IngestRange, error)
+}
+
+var fileSystems = []fileSystem{
+	{detect: isEXFAT, list: ListEXFAT},
+	{detect: isNTFS, list: ListNTFS},
+	{detect: isFAT, list: ListFAT},
+	{detect: isEXT4, list: ListEXT4},
+}
+
+func detectFileSystem(r io.ReaderAt, start int64) (fileSystem, bool) {
+	for _, fs := range fileSystems {
+		if fs.detect(r, start) {
+			return fs, true
+		}
+	}
+	return fileSystem{}, false
+}
+
+// parseVolume treats an evidence file that starts with a known file system as a single partition
+func parseVolume(r io.ReaderAt, size int64) []structs.PartitionFile {
+	if _, ok := detectFileSystem(r, 0); !ok {
+		return nil
+	}
+	var partition structs.PartitionFile
+	partition.Start = 0
+	partition.Size = size
+	return []structs.PartitionFile{partition}
+}
+
+// run is a byte range of the evidence file holding part of a file
+type run struct {
+	start int64
+	size  int64
+}
+
+// appendRun adds next to the runs of a file, merging it into the last run when it follows it on disk
+func appendRun(runs []run, next run) []run {
+	if len(runs) > 0 && runs[len(runs)-1].start+runs[len(runs)-1].size == next.start {
+		runs[len(runs)-1].size += next.size
+		return runs
+	}
+	return append(runs, next)
+}
+
+// newIngestRange lists a file stored in runs, the runs are only kept as extents when there is more than one
+func newIngestRange(name string, runs []run, size int64) *structs.IngestRange {
+	irange := &structs.IngestRange{Name: name, Start: runs[0].start, Size: size}
+	if len(runs) > 1 {
+		irange.Extents = make([]structs.Extent, len(runs))
+		for index, dataRun := range runs {
+			irange.Extents[index] = structs.Extent{Start: dataRun.start, Size: dataRun.size}
+		}
+	}
+	return irange
+}
+
+// slackRange lists the bytes between the end of a file and the end of the cluster it ends in,
+// nil when the file fills it. Data kept inside metadata does not end in a cluster of its own
+// and has no slack
+func slackRange(file *structs.IngestRange, clusterBase, clusterSize int64) *structs.IngestRange {
+	runs := file.Runs()
+	last := runs[len(runs)-1]
+	if (last.Start-clusterBase)%clusterSize != 0 {
+		return nil
+	}
+	size := (clusterSize - last.Size%clusterSize) % clusterSize
+	if size == 0 {
+		return nil
+	}
+	return &structs.IngestRange{Name: fmt.Sprintf(cnst.SlackName, file.Name), Start: last.Start + last.Size, Size: size, Space: cnst.SpaceSlack}
+}
+
+// unallocatedRange lists the free clusters of a volume as one range, nil when there are none
+func unallocatedRange(runs []run) *structs.IngestRange {
+	if len(runs) == 0 {
+		return nil
+	}
+	var size int64
+	for _, freeRun := range runs {
+		size += freeRun.size
+	}
+	irange := newIngestRange(cnst.SpaceUnallocated, runs, size)
+	irange.Space = cnst.SpaceUnallocated
+	return irange
+}
+
+// volumeSlackRange lists the bytes of partition past end, where its file system stops, nil when there are none
+func volumeSlackRange(end int64, partition structs.PartitionFile) *structs.IngestRange {
+	size := partition.Start + partition.Size - end
+	if size <= 0 {
+		return nil
+	}
+	return &structs.IngestRange{Name: cnst.SpaceVolumeSlack, Start: end, Size: size, Space: cnst.SpaceVolumeSlack}
+}
+
+// freeRuns turns an allocation bitmap into the runs of its clear bits, bit n stands for
+// the cluster at start + n*clusterSize. Only the first count bits are read
+func freeRuns(bitmap []byte, count uint64, start, clusterSize int64) []run {
+	var runs []run
+	count = min(count, uint64(len(bitmap))*8)
+	for bit := uint64(0); bit < count; {
+		// whole bytes are skipped while they are all used or all free
+		if bit%8 == 0 && bit+8 <= count && (bitmap[bit/8] == 0xFF || bitmap[bit/8] == 0) {
+			if bitmap[bit/8] == 0 {
+				runs = appendRun(runs, run{start: start + int64(bit)*clusterSize, size: 8 * clusterSize})
+			}
+			bit += 8
+			continue
+		}
+		if bitmap[bit/8]&(1<<(bit%8)) == 0 {
+			runs = appendRun(runs, run{start: start + int64(bit)*clusterSize, size: clusterSize})
+		}
+		bit++
+	}
+	return runs
+}
+
+// spaceRanges lists the slack of every live file of a volume, its unallocated clusters and
+// its volume slack. Clusters are clusterSize bytes counted from clusterBase, the file system ends at end
+func spaceRanges(files []*structs.IngestRange, free []run, clusterBase, clusterSize, end int64, partition structs.PartitionFile) []*structs.IngestRange {
+	var ranges []*structs.IngestRange
+	for _, file := range files {
+		if file.Deleted {
+			continue
+		}
+		if slack := slackRange(file, clusterBase, clusterSize); slack != nil {
+			ranges = append(ranges, slack)
+		}
+	}
+	if unallocated := unallocatedRange(free); unallocated != nil {
+		ranges = append(ranges, unallocated)
+	}
+	if slack := volumeSlackRange(end, partition); slack != nil {
+		ranges = append(ranges, slack)
+	}
+	return ranges
 }
@@ -111,16 +111,30 @@ func searchAllFiles(query string, fid []byte, db *badger.DB, errChan chan error)
 	errChan <- searchChonks(string(fid), query, meta, db)
 }
 
+// chonkSpan is the part of the chonk at index that holds bytes of a run of a file
+type chonkSpan struct {
+	index int64
+	start int64
+	end   int64
+}
+
 func searchChonks(fidStr, query string, meta structs.FileMeta, db *badger.DB) error {
-	var dbstart int64
-	if meta.Start > 0 {
-		dbstart = util.GetDBStartOffset(meta.Start)
+	// the chonks of every run are searched as one sequence, so matches across runs are found too
+	var spans []chonkSpan
+	for _, run := range meta.Runs() {
+		end := run.Start + run.Size
+		indices, err := dbio.GetRelationIndices(meta.EviHash, run.Start, end, db)
+		if err != nil {
+			return err
+		}
+		for _, index := range indices {
+			spans = append(spans, chonkSpan{index: index, start: run.Start, end: end})
+		}
 	}
-	end := meta.Start + meta.Size
 
 	echan := make(chan error)
 	var active int
-	for sindex := dbstart; sindex < end; sindex += cnst.ChonkSize {
+	for i, span := range spans {
 		for active > cnst.GetMaxThreadCount() {
 			active--
 			err := <-echan
@@ -128,7 +142,11 @@ func searchChonks(fidStr, query string, meta structs.FileMeta, db *badger.DB) er
 				return err
 			}
 		}
-		go searchChonk(sindex, dbstart, end, fidStr, query, meta, db, echan)
+		var next *chonkSpan
+		if i+1 < len(spans) {
+			next = &spans[i+1]
+		}
+		go searchChonk(span, next, fidStr, query, meta, db, echan)
 		active++
 	}
 
@@ -143,38 +161,40 @@ func searchChonks(fidStr, query string, meta structs.FileMeta, db *badger.DB) er
 	return nil
 }
 
-func searchChonk(sindex, dbstart, end int64, fid, query string, meta structs.FileMeta, db *badger.DB, echan chan error) {
-	s1key, state1, err := getChonkState(sindex, dbstart, end, meta, db)
+func searchChonk(span chonkSpan, next *chonkSpan, fid, query string, meta structs.FileMeta, db *badger.DB, echan chan error) {
+	s1key, state1, err := getChonkState(span, meta, db)
 	if err != nil {
 		echan <- err
 		return
 	}
 
-	count, ok := cmap.Get(s1key)
+	// a chonk can be searched whole for one file and only in part for another,
+	// so counts are kept per part of the chonk
+	seenKey := util.AppendToBytesSlice(s1key, cnst.DataSeperator, max(span.start-span.index, 0), cnst.RangeSeperator, span.end-span.index)
+	count, ok := cmap.Get(seenKey)
 	if !ok {
 		count = subBytesChonk([]byte(query), state1)
-		cmap.Set(s1key, count)
+		cmap.Set(seenKey, count)
 	}
 	if count > 0 {
 		idmap.Set(fid, count)
 	}
 
-	nxtidx := sindex + cnst.ChonkSize
-	if nxtidx >= end {
+	if next == nil {
 		echan <- nil
 		return
 	}
 
-	_, state2, err := getChonkState(nxtidx, dbstart, end, meta, db)
+	_, state2, err := getChonkState(*next, meta, db)
 	if err != nil {
 		echan <- err
 		return
 	}
 
-	// state 1 and 2 overlap search
-	qoffset := (len(state1) - 1) - (len(query) - 2)
+	// state 1 and 2 overlap search, the runs of a fragmented file can be shorter than the query
+	qoffset := max((len(state1)-1)-(len(query)-2), 0)
 	qstate1 := state1[qoffset:]
-	qoffset = len(query) - 2
+	qoffset = min(len(query)-2, len(state2))
 	state2 = state2[:qoffset]
 
 	// at least one byte of query on either side is required for an overlap
@@ -186,14 +206,14 @@ func searchChonk(sindex, dbstart, end int64, fid, query string, meta structs.Fil
 	echan <- nil
 }
 
-func getChonkState(searchIndex, dbstart, end int64, meta structs.FileMeta, db *badger.DB) ([]byte, []byte, error) {
-	relKey := util.AppendToBytesSlice(cnst.RelationNamespace, meta.EviHash, cnst.DataSeperator, searchIndex)
+func getChonkState(span chonkSpan, meta structs.FileMeta, db *badger.DB) ([]byte, []byte, error) {
+	relKey := util.AppendToBytesSlice(cnst.RelationNamespace, meta.EviHash, cnst.DataSeperator, span.index)
 	chash, err := dbio.GetNode(relKey, db)
 	if err != nil {
 		return nil, nil, err
 	}
 	ckey := util.AppendToBytesSlice(cnst.ChonkNamespace, chash)
-	state, err := dbio.GetChonkData(searchIndex, meta.Start, meta.Size, dbstart, end, ckey, db)
+	state, err := dbio.GetChonkData(span.index, span.start, span.end, ckey, db)
 	return ckey, state, err
 }
 
@@ -233,6 +253,11 @@ func searchReport(query string, db *badger.DB) error {
 			return err
 		}
 
+		err = setIndexedFileData(id, occurance.Disk.Partition.Indexed, db)
+		if err != nil {
+			return err
+		}
+
 		if occurance.Disk.Partition.Indexed != nil {
 			if len(occurance.Disk.Partition.Indexed.IndexedFileNames) == 0 {
 				occurance.Disk.Partition.Indexed = nil
@@ -248,6 +273,10 @@ func searchReport(query string, db *badger.DB) error {
 				occurance.Disk = nil
 			}
 		}
+		err = setAcquisitionData(id, &occurance, db)
+		if err != nil {
+			return err
+		}
 
 		fileCount += len(names)
 		occuranceCount += count
@@ -264,6 +293,22 @@ func searchReport(query string, db *badger.DB) error {
 	return os.WriteFile("report.json", reportData, os.ModePerm)
 }
 
+// setIndexedFileData marks an indexed file recovered from deleted directory entries,
+// or lying in unallocated space or slack
+func setIndexedFileData(id string, indexed *structs.IndexedPart, db *badger.DB) error {
+	if !strings.HasPrefix(id, cnst.IdxFileNamespace) {
+		return nil
+	}
+	ifile, err := dbio.GetIndexedFile([]byte(id), db)
+	if err != nil {
+		return err
+	}
+	indexed.Deleted = ifile.Deleted
+	indexed.RecoveryConfidence = ifile.RecoveryConfidence
+	indexed.Space = ifile.Space
+	return nil
+}
+
 func setOccuranceData(artefactHash string, names map[string]struct{}, smap map[string][]string, o *structs.OccuranceData, db *badger.DB) error {
 	var idx int
 	sameOccurance := false
@@ -373,6 +418,36 @@ func setDiskImageData(same bool, artefactHash string, nameSplit []string, smap m
 	return err
 }
 
+// setAcquisitionData attaches the acquisition metadata of the evidence file an occurance belongs to
+func setAcquisitionData(id string, o *structs.OccuranceData, db *badger.DB) error {
+	if strings.HasPrefix(id, cnst.EviFileNamespace) {
+		acq, err := getAcquisition([]byte(id), db)
+		o.Acquisition = acq
+		return err
+	}
+	if o.Disk == nil || o.Disk.DiskImageHash == "" {
+		return nil
+	}
+
+	ehash, err := base64.StdEncoding.DecodeString(o.Disk.DiskImageHash)
+	if err != nil {
+		return err
+	}
+	o.Disk.Acquisition, err = getAcquisition(util.GetEvidenceFileID(ehash), db)
+	return err
+}
+
+func getAcquisition(eid []byte, db *badger.DB) (*structs.Acquisition, error) {
+	evidenceFile, err := dbio.GetEvidenceFile(eid, db)
+	if err != nil {
+		return nil, err
+	}
+	if evidenceFile.Acquisition.IsZero() {
+		return nil, nil
+	}
+	return &evidenceFile.Acquisition, nil
+}
+
 func getFileNames(namespace, fhashStr string, db *badger.DB) ([]string, error) {
 	fhash, err := base64.StdEncoding.DecodeString(fhashStr)
 	if err != nil {
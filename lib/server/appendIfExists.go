@@ -33,6 +33,7 @@ func (g *GrpcService) AppendIfExists(ctx context.Context, req *pb.AppendIfExists
 	eviFile.FilePath = req.FilePath
 	eviFile.ChunkMap = chunkMap
 	eviFile.FileSize = efile.Size
+	eviFile.Acquisition = service.AcquisitionToPB(efile.Acquisition)
 
 	fileHash, err := base64.StdEncoding.DecodeString(req.FileHash)
 	if err != nil {
@@ -2,6 +2,9 @@ package server
 
 import (
 	"context"
+	"indicer/lib/audit"
+	"indicer/lib/cnst"
+	"indicer/lib/structs"
 	"indicer/pb"
 	"io"
 
@@ -24,7 +27,9 @@ func (c *ConnectService) AppendIfExists(
 	ctx context.Context,
 	req *connect.Request[pb.AppendIfExistsReq],
 ) (*connect.Response[pb.AppendIfExistsRes], error) {
+	entry := beginRPC(req.Spec(), req.Peer(), req.Msg.FilePath)
 	res, err := c.svc.AppendIfExists(ctx, req.Msg)
+	err = audit.Record(cnst.DB, entry, err, req.Msg.FileHash)
 	if err != nil {
 		return nil, err
 	}
@@ -41,8 +46,15 @@ func (c *ConnectService) StreamFile(
 		ctx:          ctx,
 	}
 
+	entry := beginRPC(stream.Spec(), stream.Peer(), "")
+
 	// Call the gRPC service method using the adapter
 	err := c.svc.StreamFile(adapter)
+	if adapter.response != nil && adapter.response.EviFile != nil {
+		entry.Input = adapter.response.EviFile.FilePath
+		entry.Hashes = append(entry.Hashes, adapter.response.EviFile.FileId)
+	}
+	err = audit.Record(cnst.DB, entry, err)
 	if err != nil {
 		return nil, err
 	}
@@ -55,7 +67,9 @@ func (c *ConnectService) GetEviFiles(
 	ctx context.Context,
 	req *connect.Request[pb.GetEviFilesReq],
 ) (*connect.Response[pb.GetEviFilesRes], error) {
+	entry := beginRPC(req.Spec(), req.Peer(), "")
 	res, err := c.svc.GetEviFiles(ctx, req.Msg)
+	err = audit.Record(cnst.DB, entry, err)
 	if err != nil {
 		return nil, err
 	}
@@ -66,7 +80,9 @@ func (c *ConnectService) GetPartiFiles(
 	ctx context.Context,
 	req *connect.Request[pb.GetPartiFilesReq],
 ) (*connect.Response[pb.GetPartiFilesRes], error) {
+	entry := beginRPC(req.Spec(), req.Peer(), req.Msg.EviFileId)
 	res, err := c.svc.GetPartiFiles(ctx, req.Msg)
+	err = audit.Record(cnst.DB, entry, err)
 	if err != nil {
 		return nil, err
 	}
@@ -77,7 +93,9 @@ func (c *ConnectService) GetIdxFiles(
 	ctx context.Context,
 	req *connect.Request[pb.GetIdxFilesReq],
 ) (*connect.Response[pb.GetIdxFilesRes], error) {
+	entry := beginRPC(req.Spec(), req.Peer(), req.Msg.PartiFileId)
 	res, err := c.svc.GetIdxFiles(ctx, req.Msg)
+	err = audit.Record(cnst.DB, entry, err)
 	if err != nil {
 		return nil, err
 	}
@@ -88,13 +106,23 @@ func (c *ConnectService) Search(
 	ctx context.Context,
 	req *connect.Request[pb.SearchReq],
 ) (*connect.Response[pb.SearchRes], error) {
+	entry := beginRPC(req.Spec(), req.Peer(), req.Msg.Keyword)
 	res, err := c.svc.Search(ctx, req.Msg)
+	err = audit.Record(cnst.DB, entry, err)
 	if err != nil {
 		return nil, err
 	}
 	return connect.NewResponse(res), nil
 }
 
+// beginRPC starts an audit entry for an rpc, the operator is whoever runs the
+// server and the peer is the client address
+func beginRPC(spec connect.Spec, peer connect.Peer, input string) structs.AuditEntry {
+	entry := audit.Begin(cnst.AuditRPCPrefix+spec.Procedure, input)
+	entry.Peer = peer.Addr
+	return entry
+}
+
 // connectStreamAdapter adapts Connect's ClientStream to gRPC's ClientStreamingServer
 type connectStreamAdapter struct {
 	clientStream *connect.ClientStream[pb.StreamFileReq]
@@ -7,12 +7,9 @@ import (
 	"indicer/lib/service"
 	"indicer/lib/util"
 	"indicer/pb"
-	"io"
 	"log"
-	"os"
 	"path/filepath"
 
-	"github.com/google/uuid"
 	"google.golang.org/grpc"
 )
 
@@ -31,14 +28,15 @@ func (g *GrpcService) StreamFile(stream grpc.ClientStreamingServer[pb.StreamFile
 		return cnst.ErrHashNotFound
 	}
 
-	fpath, err := uploadFile(stream)
+	fileHash, err := base64.StdEncoding.DecodeString(meta.FileHash)
 	if err != nil {
 		return err
 	}
-	err = service.StoreStreamedFile(fpath)
+	_, err = service.StoreStreamedFile(&streamReader{stream: stream}, filepath.Base(meta.FilePath), fileHash, meta.Acquisition)
 	if err != nil {
 		return err
 	}
+
 	efile, err := service.AddEvidenceMetadata(meta)
 	if err != nil {
 		return err
@@ -56,66 +54,32 @@ func (g *GrpcService) StreamFile(stream grpc.ClientStreamingServer[pb.StreamFile
 	eviFile.FilePath = meta.FilePath
 	eviFile.ChunkMap = chunkMap
 	eviFile.FileSize = efile.Size
+	eviFile.Acquisition = service.AcquisitionToPB(efile.Acquisition)
 
-	fileHash, err := base64.StdEncoding.DecodeString(meta.FileHash)
-	if err != nil {
-		return err
-	}
 	eid := util.AppendToBytesSlice(cnst.EviFileNamespace, fileHash)
 	fileId := base64.StdEncoding.EncodeToString(eid)
 	eviFile.FileId = fileId
 
-	err = os.Remove(fpath)
-	if err != nil {
-		log.Printf("Warning: could not remove temp file %s: %v\n", fpath, err)
-	}
-
 	res.EviFile = &eviFile
 	return stream.SendAndClose(&res)
 }
 
-func uploadFile(stream grpc.ClientStreamingServer[pb.StreamFileReq, pb.StreamFileRes]) (string, error) {
-	fh, err := getFileHandle()
-	if err != nil {
-		return "", err
-	}
-
-	for {
-		req, err := stream.Recv()
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return "", err
-		}
+// streamReader reads the file chunks of a StreamFile upload as one stream,
+// the first message carrying the file metadata must already have been received
+type streamReader struct {
+	stream grpc.ClientStreamingServer[pb.StreamFileReq, pb.StreamFileRes]
+	chunk  []byte
+}
 
-		chunk := req.GetFile()
-		_, err = fh.Write(chunk)
+func (s *streamReader) Read(p []byte) (int, error) {
+	for len(s.chunk) == 0 {
+		req, err := s.stream.Recv()
 		if err != nil {
-			return "", err
+			return 0, err
 		}
+		s.chunk = req.GetFile()
 	}
-
-	fname := fh.Name()
-	err = fh.Close()
-	if err != nil {
-		return "", nil
-	}
-
-	log.Printf("Uploaded file to %s\n", fname)
-	return fname, nil
-}
-func getFileHandle() (*os.File, error) {
-	fid, err := uuid.NewV7()
-	if err != nil {
-		return nil, err
-	}
-
-	fpath := filepath.Join(cnst.UploadsDir, fid.String())
-	fpath, err = filepath.Abs(fpath)
-	if err != nil {
-		return nil, err
-	}
-
-	return os.Create(fpath)
+	n := copy(p, s.chunk)
+	s.chunk = s.chunk[n:]
+	return n, nil
 }
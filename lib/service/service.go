@@ -35,14 +35,14 @@ func getEvidenceFile(filePath, fileHashStr string, db *badger.DB) (structs.Evide
 }
 
 func getChonkMap(meta structs.FileMeta, db *badger.DB) (map[string]int64, error) {
-	var dbstart int64
-	if meta.Start > 0 {
-		dbstart = util.GetDBStartOffset(meta.Start)
-	}
 	end := meta.Start + meta.Size
+	indices, err := dbio.GetRelationIndices(meta.EviHash, meta.Start, end, db)
+	if err != nil {
+		return nil, err
+	}
 
 	chunkMap := make(map[string]int64)
-	for restoreIndex := dbstart; restoreIndex < end; restoreIndex += cnst.ChonkSize {
+	for _, restoreIndex := range indices {
 		relKey := util.AppendToBytesSlice(cnst.RelationNamespace, meta.EviHash, cnst.DataSeperator, restoreIndex)
 		chash, err := dbio.GetNode(relKey, db)
 		if err != nil {
@@ -50,7 +50,7 @@ func getChonkMap(meta structs.FileMeta, db *badger.DB) (map[string]int64, error)
 		}
 
 		ckey := util.AppendToBytesSlice(cnst.ChonkNamespace, chash)
-		csize, err := dbio.GetChonkSize(restoreIndex, meta.Start, meta.Size, dbstart, end, ckey, db)
+		csize, err := dbio.GetChonkSize(restoreIndex, meta.Start, end, ckey, db)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"indicer/lib/structs"
+	"indicer/pb"
+	"time"
+)
+
+func AcquisitionToPB(acq structs.Acquisition) *pb.Acquisition {
+	if acq.IsZero() {
+		return nil
+	}
+
+	var pbacq pb.Acquisition
+	pbacq.CaseNumber = acq.CaseNumber
+	pbacq.ExhibitId = acq.ExhibitID
+	pbacq.Examiner = acq.Examiner
+	pbacq.DeviceSerial = acq.DeviceSerial
+	pbacq.Notes = acq.Notes
+	pbacq.Tags = acq.Tags
+	if !acq.AcquiredAt.IsZero() {
+		pbacq.AcquiredAt = acq.AcquiredAt.Format(time.RFC3339)
+	}
+	return &pbacq
+}
+
+func acquisitionFromPB(pbacq *pb.Acquisition) (structs.Acquisition, error) {
+	var acq structs.Acquisition
+	if pbacq == nil {
+		return acq, nil
+	}
+
+	acq.CaseNumber = pbacq.CaseNumber
+	acq.ExhibitID = pbacq.ExhibitId
+	acq.Examiner = pbacq.Examiner
+	acq.DeviceSerial = pbacq.DeviceSerial
+	acq.Notes = pbacq.Notes
+	if len(pbacq.Tags) > 0 {
+		acq.Tags = pbacq.Tags
+	}
+	if pbacq.AcquiredAt == "" {
+		return acq, nil
+	}
+
+	acquiredAt, err := time.Parse(time.RFC3339, pbacq.AcquiredAt)
+	acq.AcquiredAt = acquiredAt.UTC()
+	return acq, err
+}
@@ -8,11 +8,15 @@ import (
 	"indicer/lib/structs"
 	"indicer/lib/util"
 	"indicer/pb"
+	"io"
 )
 
-func StoreStreamedFile(fpath string) error {
-	key := util.HashPassword("")
-	return cli.StoreFile(int(cnst.DefaultChonkSize), fpath, key, false, false, cnst.DB)
+func StoreStreamedFile(r io.Reader, name string, expected []byte, pbacq *pb.Acquisition) ([]byte, error) {
+	acq, err := acquisitionFromPB(pbacq)
+	if err != nil {
+		return nil, err
+	}
+	return cli.StoreStream(r, name, expected, false, acq, cnst.DB)
 }
 
 func AddEvidenceMetadata(meta *pb.StreamFileMeta) (structs.EvidenceFile, error) {
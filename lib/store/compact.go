@@ -0,0 +1,431 @@
+package store
+
+import (
+	"cmp"
+	"fmt"
+	"indicer/lib/cnst"
+	"indicer/lib/dbio"
+	"indicer/lib/fio"
+	"indicer/lib/structs"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/dustin/go-humanize"
+)
+
+// containerChonk is a chonk inside a container, its location is either kept in the
+// database under key or in a block file entry
+type containerChonk struct {
+	key       []byte
+	blockFile string
+	entry     *fio.BlockEntry
+	location  structs.ChonkLocation
+}
+
+type containerUsage struct {
+	live      []*containerChonk
+	dead      []*containerChonk
+	liveBytes int64
+	dropped   bool
+}
+
+// collector knows where every chonk is kept and whether anything still references it,
+// it is shared by compact and gc
+type collector struct {
+	db           *badger.DB
+	live         map[[64]byte]struct{}
+	containers   map[string]*containerUsage // resolved container path -> chonks found in it
+	blockFiles   map[string][]*fio.BlockEntry
+	dropped      map[*fio.BlockEntry]struct{}
+	dirty        map[string]struct{} // block files that lost or moved entries
+	deadBlobKeys [][]byte
+	deadBlobs    []string
+	deadKeys     [][]byte
+	deadEntries  int
+	moved        []*containerChonk
+	rewritten    int
+	removed      int
+}
+
+// Compact rewrites every container whose live chonks take up less than threshold of
+// its size, live chonks are copied to new containers and the rest is dropped.
+// Containers without a single live chonk are deleted. Badger metadata and block
+// files are only switched over once the new containers are closed, and the old
+// containers are only deleted after that, so at any point of an interrupted compact
+// every chonk is reachable from either its old or its new copy.
+func Compact(db *badger.DB, threshold float64) error {
+	if threshold <= 0 || threshold > 1 {
+		return cnst.ErrInvalidThreshold
+	}
+
+	before, err := blobsSize(db.Opts().Dir)
+	if err != nil {
+		return err
+	}
+	c, err := newCollector(db)
+	if err != nil {
+		return err
+	}
+	err = c.reclaim(threshold, false)
+	if err != nil {
+		return err
+	}
+
+	after, err := blobsSize(db.Opts().Dir)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nContainers deleted: %d, rewritten: %d\n", c.removed, c.rewritten)
+	fmt.Printf("Reclaimed: %s\n", humanize.Bytes(uint64(max(before-after, 0))))
+	return nil
+}
+
+func newCollector(db *badger.DB) (*collector, error) {
+	c := &collector{
+		db:         db,
+		containers: map[string]*containerUsage{},
+		blockFiles: map[string][]*fio.BlockEntry{},
+		dropped:    map[*fio.BlockEntry]struct{}{},
+		dirty:      map[string]struct{}{},
+	}
+
+	var err error
+	fmt.Println("Finding referenced chunks....")
+	c.live, err = liveChonks(db)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Println("Sweeping chunk metadata....")
+	err = c.sweepChonkKeys()
+	if err != nil {
+		return nil, err
+	}
+	err = c.sweepBlockIndex()
+	return c, err
+}
+
+// liveChonks collects the hashes of all chonks that still have a reverse relation,
+// delete removes emptied reverse relations so their presence alone is enough
+func liveChonks(db *badger.DB) (map[[64]byte]struct{}, error) {
+	live := map[[64]byte]struct{}{}
+	err := db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte(cnst.ReverseRelationNamespace)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			key := it.Item().Key()
+			if len(key) < len(prefix)+fio.HashLength {
+				continue
+			}
+			var chash [64]byte
+			copy(chash[:], key[len(prefix):])
+			live[chash] = struct{}{}
+		}
+		return nil
+	})
+	return live, err
+}
+
+func (c *collector) isLive(chash []byte) bool {
+	var key [64]byte
+	copy(key[:], chash)
+	_, ok := c.live[key]
+	return ok
+}
+
+func (c *collector) usage(cpath string) *containerUsage {
+	cpath = fio.ResolveContainerPath(cpath)
+	u, ok := c.containers[cpath]
+	if !ok {
+		u = &containerUsage{}
+		c.containers[cpath] = u
+	}
+	return u
+}
+
+func (c *collector) addChonk(chonk *containerChonk, live bool) {
+	u := c.usage(chonk.location.ContainerPath)
+	if live {
+		u.live = append(u.live, chonk)
+		u.liveBytes += chonk.location.Size
+	} else {
+		u.dead = append(u.dead, chonk)
+	}
+}
+
+func (c *collector) sweepChonkKeys() error {
+	ckeys, err := listObjectIDs(cnst.ChonkNamespace, c.db)
+	if err != nil {
+		return err
+	}
+
+	for _, ckey := range ckeys {
+		location, err := dbio.GetChonkLocation(ckey, c.db)
+		if err != nil {
+			return err
+		}
+
+		live := c.isLive(ckey[len(cnst.ChonkNamespace):])
+		if location.ContainerPath != "" {
+			c.addChonk(&containerChonk{key: ckey, location: location}, live)
+			continue
+		}
+		if !live {
+			c.deadBlobKeys = append(c.deadBlobKeys, ckey)
+			c.deadBlobs = append(c.deadBlobs, location.BlobPath)
+		}
+	}
+	return nil
+}
+
+func (c *collector) sweepBlockIndex() error {
+	blockFiles, err := fio.BlockIndexFiles(c.db.Opts().Dir)
+	if err != nil {
+		return err
+	}
+
+	for _, blockFile := range blockFiles {
+		var entries []*fio.BlockEntry
+		err = fio.WalkBlockFile(blockFile, func(chunkHash []byte, cpath string, offset, size int64) error {
+			entry := &fio.BlockEntry{
+				ChunkHash:     slices.Clone(chunkHash),
+				ContainerPath: cpath,
+				Offset:        offset,
+				Size:          size,
+			}
+			entries = append(entries, entry)
+
+			c.addChonk(&containerChonk{
+				blockFile: blockFile,
+				entry:     entry,
+				location: structs.ChonkLocation{
+					ContainerPath: cpath,
+					Offset:        offset,
+					Size:          size,
+				},
+			}, c.isLive(chunkHash))
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", blockFile, err)
+		}
+		c.blockFiles[blockFile] = entries
+	}
+	return nil
+}
+
+// reclaim rewrites or removes the containers picked by planContainers, allDead also
+// drops dead chonks living outside of those containers and their blob files
+func (c *collector) reclaim(threshold float64, allDead bool) error {
+	rewrite, remove, err := c.planContainers(threshold)
+	if err != nil {
+		return err
+	}
+
+	enableContainerReadCache()
+	defer fio.DisableContainerReadCache()
+
+	if len(rewrite) > 0 {
+		fmt.Printf("Rewriting %d containers....\n", len(rewrite))
+	}
+	err = c.rewriteContainers(rewrite)
+	if err != nil {
+		return err
+	}
+
+	for _, cpath := range append(rewrite, remove...) {
+		c.dropDead(c.containers[cpath])
+	}
+	if allDead {
+		for _, u := range c.containers {
+			c.dropDead(u)
+		}
+		c.deadKeys = append(c.deadKeys, c.deadBlobKeys...)
+	}
+
+	err = c.commitMetadata()
+	if err != nil {
+		return err
+	}
+
+	// nothing points at these anymore
+	for _, cpath := range append(rewrite, remove...) {
+		err = os.Remove(cpath)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if allDead {
+		for _, blob := range c.deadBlobs {
+			err = os.Remove(blob)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	c.rewritten = len(rewrite)
+	c.removed = len(remove)
+	return nil
+}
+
+// planContainers picks the containers to rewrite and the ones that can simply go,
+// compressed containers nothing refers to at all are left behind by interrupted
+// stores and compactions and are always removed
+func (c *collector) planContainers(threshold float64) ([]string, []string, error) {
+	onDisk, err := filepath.Glob(filepath.Join(c.db.Opts().Dir, cnst.BLOBSDIR, "*"+cnst.BLOBZSTEXT))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, cpath := range onDisk {
+		c.usage(cpath)
+	}
+
+	var rewrite, remove []string
+	for cpath, u := range c.containers {
+		if _, err := os.Stat(cpath); os.IsNotExist(err) {
+			// dangling references are for verify to report, there is nothing to reclaim
+			continue
+		}
+		if len(u.live) == 0 {
+			remove = append(remove, cpath)
+			continue
+		}
+
+		size, err := fio.ContainerSize(cpath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w, run verify before reclaiming space", cpath, err)
+		}
+		if float64(u.liveBytes) < threshold*float64(size) {
+			rewrite = append(rewrite, cpath)
+		}
+	}
+	slices.Sort(rewrite)
+	slices.Sort(remove)
+	return rewrite, remove, nil
+}
+
+// rewriteContainers copies the live chonks of each container into new containers
+// as they are stored, nothing is decrypted or recompressed on the way
+func (c *collector) rewriteContainers(rewrite []string) (err error) {
+	if len(rewrite) == 0 {
+		return nil
+	}
+
+	containerMgr := fio.NewContainerManager(c.db.Opts().Dir)
+	defer func() {
+		if closeErr := containerMgr.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	for _, cpath := range rewrite {
+		live := c.containers[cpath].live
+		slices.SortFunc(live, func(a, b *containerChonk) int {
+			return cmp.Compare(a.location.Offset, b.location.Offset)
+		})
+
+		for _, chonk := range live {
+			encoded, err := fio.ReadEncodedChunk(chonk.location.ContainerPath, chonk.location.Offset, chonk.location.Size)
+			if err != nil {
+				return fmt.Errorf("%s: %w", cpath, err)
+			}
+			npath, noffset, nsize, err := containerMgr.WriteEncodedChunk(encoded)
+			if err != nil {
+				return err
+			}
+			chonk.location = structs.ChonkLocation{ContainerPath: npath, Offset: noffset, Size: nsize}
+			c.moved = append(c.moved, chonk)
+		}
+	}
+	return nil
+}
+
+func (c *collector) dropDead(u *containerUsage) {
+	if u.dropped {
+		return
+	}
+	u.dropped = true
+
+	for _, chonk := range u.dead {
+		if chonk.entry == nil {
+			c.deadKeys = append(c.deadKeys, chonk.key)
+			continue
+		}
+		c.dropped[chonk.entry] = struct{}{}
+		c.dirty[chonk.blockFile] = struct{}{}
+		c.deadEntries++
+	}
+}
+
+// commitMetadata points moved chonks at their new containers and drops dead ones
+func (c *collector) commitMetadata() error {
+	batch := c.db.NewWriteBatch()
+	for _, chonk := range c.moved {
+		if chonk.entry == nil {
+			err := dbio.SetBatchChonkLocation(chonk.key, chonk.location.ContainerPath, chonk.location.Offset, chonk.location.Size, batch)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		c.dirty[chonk.blockFile] = struct{}{}
+		chonk.entry.ContainerPath = chonk.location.ContainerPath
+		chonk.entry.Offset = chonk.location.Offset
+		chonk.entry.Size = chonk.location.Size
+	}
+	for _, ckey := range c.deadKeys {
+		err := batch.Delete(ckey)
+		if err != nil {
+			return err
+		}
+	}
+	err := batch.Flush()
+	if err != nil {
+		return err
+	}
+
+	for blockFile := range c.dirty {
+		var entries []fio.BlockEntry
+		for _, entry := range c.blockFiles[blockFile] {
+			if _, ok := c.dropped[entry]; !ok {
+				entries = append(entries, *entry)
+			}
+		}
+		err = fio.WriteBlockFile(blockFile, entries)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func blobsSize(dbpath string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(filepath.Join(dbpath, cnst.BLOBSDIR), func(path string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
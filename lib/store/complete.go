@@ -0,0 +1,111 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"indicer/lib/cnst"
+	"indicer/lib/dbio"
+	"indicer/lib/fio"
+	"indicer/lib/structs"
+	"indicer/lib/util"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/schollz/progressbar/v3"
+	"golang.org/x/crypto/sha3"
+)
+
+// CompleteEvidenceFile is the last phase of a store, the evidence file is only marked
+// Completed once it has been reassembled from the database and its hash matches
+func CompleteEvidenceFile(infile structs.InputFile) error {
+	err := verifyEvidenceData(infile.GetHash(), infile.GetStartIndex(), infile.GetSize(), infile.GetDB())
+	if err != nil {
+		return err
+	}
+
+	eviNode, err := dbio.GetEvidenceFile(infile.GetID(), infile.GetDB())
+	if err != nil {
+		return err
+	}
+	eviNode.Completed = true
+	eviNode.CompletedAt = time.Now().UTC()
+	eviNode.ToolVersion = cnst.ToolVersion
+	return dbio.SetFile(infile.GetID(), eviNode, infile.GetDB())
+}
+
+// verifyEvidenceData walks the relation keys from start to size following the actual
+// chonk lengths, so both fixed and CDC boundaries are checked for gaps
+func verifyEvidenceData(ehash []byte, start, size int64, db *badger.DB) error {
+	fmt.Println("\nVerifying stored evidence....")
+	bar := progressbar.DefaultBytes(size)
+
+	hasher := sha3.New256()
+	for index := start; index < size; {
+		relKey := util.AppendToBytesSlice(cnst.RelationNamespace, ehash, cnst.DataSeperator, index)
+		chash, err := dbio.GetNode(relKey, db)
+		if err == badger.ErrKeyNotFound {
+			return fmt.Errorf("%w: no chonk at offset %d, run store again to resume", cnst.ErrIncompleteFile, index)
+		}
+		if err != nil {
+			return err
+		}
+
+		ckey := util.AppendToBytesSlice(cnst.ChonkNamespace, chash)
+		data, err := dbio.GetChonkNode(ckey, db)
+		if err != nil {
+			return fmt.Errorf("%w: chonk at offset %d is unreadable: %v", cnst.ErrIncompleteFile, index, err)
+		}
+		if len(data) == 0 {
+			return fmt.Errorf("%w: chonk at offset %d is empty", cnst.ErrIncompleteFile, index)
+		}
+		dhash, err := util.GetChonkHash(data, sha3.New512())
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(dhash, chash) {
+			return fmt.Errorf("%w: chonk at offset %d does not match its hash", cnst.ErrIncompleteFile, index)
+		}
+
+		hasher.Write(data)
+		index += int64(len(data))
+		bar.Add(len(data))
+	}
+
+	if !bytes.Equal(hasher.Sum(nil), ehash) {
+		return fmt.Errorf("%w: reassembled data does not match the evidence hash", cnst.ErrIncompleteFile)
+	}
+
+	bar.Finish()
+	return bar.Close()
+}
+
+func hasRelations(fhash []byte, db *badger.DB) (bool, error) {
+	prefix := util.AppendToBytesSlice(cnst.RelationNamespace, fhash, cnst.DataSeperator)
+	var found bool
+	err := db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		it.Seek(prefix)
+		found = it.ValidForPrefix(prefix)
+		return nil
+	})
+	return found, err
+}
+
+// isChonkStored reports whether both the relation key and the chonk it points to exist
+func isChonkStored(fhash []byte, index int64, blockMgr *fio.BlockManager, db *badger.DB) (bool, error) {
+	relKey := util.AppendToBytesSlice(cnst.RelationNamespace, fhash, cnst.DataSeperator, index)
+	chash, err := dbio.GetNode(relKey, db)
+	if err == badger.ErrKeyNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	ckey := util.AppendToBytesSlice(cnst.ChonkNamespace, chash)
+	return dbio.ChonkExists(ckey, blockMgr, db)
+}
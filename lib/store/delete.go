@@ -0,0 +1,254 @@
+package store
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"indicer/lib/cnst"
+	"indicer/lib/dbio"
+	"indicer/lib/digest"
+	"indicer/lib/util"
+	"strconv"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Delete removes an evidence file together with the partition and indexed objects
+// derived from it, chonks are left in place for GC to reclaim. The evidence file is
+// marked incomplete first and removed last, so an interrupted delete can simply be
+// run again.
+func Delete(fhash string, db *badger.DB) error {
+	// a whole disk partition shares its hash with the evidence file, so look for the
+	// evidence file first instead of guessing
+	ehash, err := base64.StdEncoding.DecodeString(fhash)
+	if err != nil {
+		return err
+	}
+	fid := util.GetEvidenceFileID(ehash)
+
+	eviFile, err := dbio.GetEvidenceFile(fid, db)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		if _, gerr := dbio.GuessFileType(fhash, db); gerr == nil {
+			return cnst.ErrNotEvidenceFile
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if eviFile.Completed {
+		eviFile.Completed = false
+		err = dbio.SetFile(fid, eviFile, db)
+		if err != nil {
+			return err
+		}
+	}
+
+	batch, err := util.InitBatch(db)
+	if err != nil {
+		return err
+	}
+
+	// names of derived objects start with the encoded hash of the evidence file
+	namePrefix := base64.StdEncoding.EncodeToString(ehash) + cnst.DataSeperator
+	var removed, kept int
+	seen := map[string]struct{}{}
+	for phash := range eviFile.InternalObjects {
+		pid, err := getObjectID(cnst.PartiFileNamespace, phash)
+		if err != nil {
+			return err
+		}
+		pfile, err := dbio.GetPartitionFile(pid, db)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		for ihash := range pfile.InternalObjects {
+			if _, ok := seen[ihash]; ok {
+				continue
+			}
+			seen[ihash] = struct{}{}
+
+			iid, err := getObjectID(cnst.IdxFileNamespace, ihash)
+			if err != nil {
+				return err
+			}
+			gone, err := dropIndexedFileNames(iid, namePrefix, db, batch)
+			if err != nil {
+				return err
+			}
+			if gone {
+				removed++
+			} else {
+				kept++
+			}
+		}
+
+		dropNames(pfile.Names, namePrefix)
+		if len(pfile.Names) == 0 {
+			err = dropDigestIndex(pid, pfile.Digests, db, batch)
+			if err != nil {
+				return err
+			}
+			err = batch.Delete(pid)
+			removed++
+		} else {
+			err = dbio.SetBatchFile(pid, pfile, batch)
+			kept++
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	relations, err := deleteRelations(ehash, db, batch)
+	if err != nil {
+		return err
+	}
+	err = batch.Flush()
+	if err != nil {
+		return err
+	}
+	dbio.ForgetRelationIndices(ehash)
+
+	batch, err = util.InitBatch(db)
+	if err != nil {
+		return err
+	}
+	err = dropDigestIndex(fid, eviFile.Digests, db, batch)
+	if err != nil {
+		return err
+	}
+	err = batch.Delete(fid)
+	if err != nil {
+		return err
+	}
+	err = batch.Flush()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Deleted evidence file %s\n", fhash)
+	fmt.Printf("\tDerived objects removed: %d, still used by other evidence: %d\n", removed, kept)
+	fmt.Printf("\tRelations removed: %d\n", relations)
+	fmt.Println("Run gc to reclaim the chunks that are no longer referenced")
+	return nil
+}
+
+func getObjectID(namespace, encodedHash string) ([]byte, error) {
+	hash, err := base64.StdEncoding.DecodeString(encodedHash)
+	if err != nil {
+		return nil, err
+	}
+	return util.AppendToBytesSlice(namespace, hash), nil
+}
+
+func dropNames(names map[string]struct{}, namePrefix string) {
+	for name := range names {
+		if strings.HasPrefix(name, namePrefix) {
+			delete(names, name)
+		}
+	}
+}
+
+// dropIndexedFileNames removes the names an evidence file gave to an indexed file,
+// the indexed file itself goes once nothing else names it
+func dropIndexedFileNames(iid []byte, namePrefix string, db *badger.DB, batch *badger.WriteBatch) (bool, error) {
+	ifile, err := dbio.GetIndexedFile(iid, db)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	dropNames(ifile.Names, namePrefix)
+	if len(ifile.Names) == 0 {
+		err = dropDigestIndex(iid, ifile.Digests, db, batch)
+		if err != nil {
+			return false, err
+		}
+		return true, batch.Delete(iid)
+	}
+	return false, dbio.SetIndexedFile(iid, ifile, batch)
+}
+
+// dropDigestIndex removes the digest index entries of an object that is going away,
+// unless an object in another namespace has the same content and still resolves through them
+func dropDigestIndex(fid []byte, digests digest.Digests, db *badger.DB, batch *badger.WriteBatch) error {
+	hash := bytes.SplitN(fid, []byte(cnst.NamespaceSeperator), 2)[1]
+	for _, namespace := range []string{cnst.EviFileNamespace, cnst.PartiFileNamespace, cnst.IdxFileNamespace} {
+		if bytes.HasPrefix(fid, []byte(namespace)) {
+			continue
+		}
+		err := dbio.PingNode(util.AppendToBytesSlice(namespace, hash), db)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+	}
+	return dbio.DeleteBatchDigestIndex(digests, batch)
+}
+
+// deleteRelations removes every relation key of ehash and takes ehash out of the
+// reverse relation of the chonk it pointed to, emptied reverse relations are deleted
+func deleteRelations(ehash []byte, db *badger.DB, batch *badger.WriteBatch) (int, error) {
+	prefix := util.AppendToBytesSlice(cnst.RelationNamespace, ehash, cnst.DataSeperator)
+
+	var relKeys [][]byte
+	err := db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			relKeys = append(relKeys, it.Item().KeyCopy(nil))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, relKey := range relKeys {
+		index, err := strconv.ParseInt(string(relKey[len(prefix):]), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		chash, err := dbio.GetNode(relKey, db)
+		if err != nil {
+			return 0, err
+		}
+
+		revRelKey := util.AppendToBytesSlice(cnst.ReverseRelationNamespace, chash, cnst.DataSeperator, index)
+		revRelMap, err := dbio.GetReverseRelationNode(revRelKey, db)
+		if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return 0, err
+		}
+		if err == nil {
+			delete(revRelMap, string(ehash))
+			if len(revRelMap) == 0 {
+				err = batch.Delete(revRelKey)
+			} else {
+				err = dbio.SetReverseRelationNode(revRelKey, revRelMap, batch)
+			}
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		err = batch.Delete(relKey)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(relKeys), nil
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/dustin/go-humanize"
+)
+
+// GC reclaims every chonk that no evidence file references anymore. Relations left behind
+// by stores that can no longer be picked up are dropped first. Blob files are deleted,
+// containers with no live chonks left are deleted and containers holding both are
+// compacted down to their live chonks.
+func GC(db *badger.DB) error {
+	before, err := blobsSize(db.Opts().Dir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Finding orphaned relations....")
+	orphaned, err := findOrphans(db)
+	if err != nil {
+		return err
+	}
+	err = orphaned.drop(db)
+	if err != nil {
+		return err
+	}
+
+	c, err := newCollector(db)
+	if err != nil {
+		return err
+	}
+	err = c.reclaim(1, true)
+	if err != nil {
+		return err
+	}
+
+	after, err := blobsSize(db.Opts().Dir)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nDropped the orphaned relations of %d hashes, %d interrupted streams\n", len(orphaned.owners), len(orphaned.streams))
+	fmt.Printf("Removed %d unreferenced chunks (%d blob files)\n", len(c.deadKeys)+c.deadEntries, len(c.deadBlobs))
+	fmt.Printf("Containers deleted: %d, rewritten: %d\n", c.removed, c.rewritten)
+	fmt.Printf("Reclaimed: %s\n", humanize.Bytes(uint64(max(before-after, 0))))
+	return nil
+}
@@ -0,0 +1,583 @@
+package store
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"indicer/lib/cdc"
+	"indicer/lib/cnst"
+	"indicer/lib/dbio"
+	"indicer/lib/digest"
+	"indicer/lib/fio"
+	"indicer/lib/structs"
+	"indicer/lib/util"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/dustin/go-humanize"
+	"github.com/edsrzf/mmap-go"
+	"github.com/schollz/progressbar/v3"
+	"golang.org/x/crypto/sha3"
+)
+
+// ProvisionalHash keys the relations of an evidence file until its real hash is known.
+// It only depends on where the file is and what it looks like on disk, so an interrupted
+// store of the same file picks up the relations it left behind
+func ProvisionalHash(fpath string, info os.FileInfo) ([]byte, error) {
+	abspath, err := filepath.Abs(fpath)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha3.Sum256(util.AppendToBytesSlice(cnst.ProvisionalHashPrefix, abspath, cnst.DataSeperator, info.Size(), cnst.DataSeperator, info.ModTime().UnixNano()))
+	return hash[:], nil
+}
+
+// Ingest reads infile once. Every chonk is deduplicated under the provisional hash infile
+// was created with, while the same bytes are fed to the evidence hash and to the hash of
+// every range. Fragmented ranges whose runs are out of disk order are read again afterwards.
+// Once the pass is over infile gets its real hash and the relations are moved to it
+func Ingest(infile *structs.InputFile, ranges []*structs.IngestRange) error {
+	provisional := infile.GetHash()
+	hasher := digest.New()
+
+	err := dbio.SetPendingStore(provisional, cnst.PendingFile, infile.GetDB())
+	if err != nil {
+		return err
+	}
+	if infile.GetMappedFile() == nil {
+		// evidence that cannot be mapped, such as the media inside an E01, is read front to back
+		_, err = ingestStreamData(*infile, hasher, io.NewSectionReader(infile.GetReader(), 0, infile.GetSize()), ranges)
+	} else {
+		err = ingestData(*infile, hasher, ranges)
+	}
+	if err != nil {
+		return err
+	}
+	err = hashUnorderedRanges(infile.GetReader(), ranges)
+	if err != nil {
+		return err
+	}
+
+	fhash := hasher.Sum(nil)
+	infile.UpdateInputFile(infile.GetName(), cnst.EviFileNamespace, fhash, infile.GetSize(), infile.GetStartIndex())
+	infile.SetDigests(hasher.Digests())
+	return commitRelations(provisional, fhash, infile.GetDB())
+}
+
+// IngestStream is Ingest for sources that can only be read front to back. The size of
+// infile is only known once r is drained, and there is nothing to resume from,
+// so infile should be created with a random provisional hash. When expected is set
+// and the stream hashes to anything else its relations are dropped instead of committed
+func IngestStream(infile *structs.InputFile, r io.Reader, expected []byte) error {
+	provisional := infile.GetHash()
+	hasher := digest.New()
+
+	err := dbio.SetPendingStore(provisional, cnst.PendingStream, infile.GetDB())
+	if err != nil {
+		return err
+	}
+	size, err := ingestStreamData(*infile, hasher, r, nil)
+	if err != nil {
+		return err
+	}
+
+	fhash := hasher.Sum(nil)
+	infile.UpdateInputFile(infile.GetName(), cnst.EviFileNamespace, fhash, size, infile.GetStartIndex())
+	infile.SetDigests(hasher.Digests())
+	if expected != nil && !bytes.Equal(fhash, expected) {
+		mismatch := fmt.Errorf(cnst.ErrStreamHashMismatch.Error(), base64.StdEncoding.EncodeToString(fhash), base64.StdEncoding.EncodeToString(expected))
+		return errors.Join(mismatch, moveRelations(provisional, nil, true, infile.GetDB()))
+	}
+	return commitRelations(provisional, fhash, infile.GetDB())
+}
+
+// RandomProvisionalHash keys the relations of a stream, which cannot be recognised when it comes again
+func RandomProvisionalHash() ([]byte, error) {
+	hash := make([]byte, sha3.New256().Size())
+	_, err := rand.Read(hash)
+	return hash, err
+}
+
+func ingestData(infile structs.InputFile, hasher *digest.Hasher, ranges []*structs.IngestRange) (err error) {
+	bar := progressbar.DefaultBytes(infile.GetSize())
+
+	tio, err := initThreadIO(infile)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := closeThreadIO(tio); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+	tio.MappedFile = infile.GetMappedFile()
+
+	// relation keys left behind by an interrupted store mean this is a resume
+	resume, err := hasRelations(infile.GetHash(), infile.GetDB())
+	if err != nil {
+		return err
+	}
+
+	// chonks are hashed in order on their own goroutine, dedup workers are not held back by it
+	segments := make(chan [2]int64, cnst.GetMaxThreadCount())
+	hashed := make(chan struct{})
+	go hashSegments(tio.MappedFile, segments, hasher, newRangeHasher(ranges), hashed)
+	defer func() {
+		if segments != nil {
+			close(segments)
+			<-hashed
+		}
+	}()
+
+	var active int
+	var buffsize, skipped, pending int64
+	resumeIndex := cnst.IgnoreVar
+	for storeIndex := infile.GetStartIndex(); storeIndex < infile.GetSize(); storeIndex += buffsize {
+		tio.Index = storeIndex
+		buffsize = util.GetNextChonkSize(tio.MappedFile[storeIndex:infile.GetSize()])
+		tio.ChonkEnd = tio.Index + buffsize
+		segments <- [2]int64{tio.Index, tio.ChonkEnd}
+
+		if resume {
+			stored, err := isChonkStored(infile.GetHash(), storeIndex, tio.BlockMgr, infile.GetDB())
+			if err != nil {
+				return err
+			}
+			if stored {
+				skipped += buffsize
+				bar.Add64(buffsize)
+				continue
+			}
+			if resumeIndex == cnst.IgnoreVar {
+				resumeIndex = storeIndex
+			}
+		}
+
+		go storeWorker(tio, tio.MappedFile[tio.Index:tio.ChonkEnd])
+		active++
+		pending += buffsize
+
+		if pending >= cnst.CheckpointSize {
+			err = checkpoint(&tio, active)
+			if err != nil {
+				return err
+			}
+			active, pending = 0, 0
+		} else if active > cnst.GetMaxThreadCount() {
+			workerErr := <-tio.Err
+			if workerErr != nil {
+				return workerErr
+			}
+			active--
+		}
+		bar.Add64(buffsize)
+	}
+
+	for active > 0 {
+		workerErr := <-tio.Err
+		if workerErr != nil {
+			return workerErr
+		}
+		active--
+	}
+
+	close(segments)
+	<-hashed
+	segments = nil
+
+	err = tio.Batch.Flush()
+	if err != nil {
+		return err
+	}
+	dbio.ForgetRelationIndices(infile.GetHash())
+
+	bar.Finish()
+	err = bar.Close()
+	if resume {
+		printResume(resumeIndex, skipped, infile.GetSize())
+	}
+	return err
+}
+
+// checkpoint waits for the active chonks and commits every relation stored so far, so an
+// interrupted store resumes from here rather than from the start
+func checkpoint(tio *structs.ThreadIO, active int) error {
+	for ; active > 0; active-- {
+		workerErr := <-tio.Err
+		if workerErr != nil {
+			return workerErr
+		}
+	}
+	if tio.BlockMgr != nil {
+		err := tio.BlockMgr.Flush()
+		if err != nil {
+			return err
+		}
+	}
+	err := tio.Batch.Flush()
+	if err != nil {
+		return err
+	}
+	dbio.ForgetRelationIndices(tio.FHash)
+	tio.Batch, err = util.InitBatch(tio.DB)
+	return err
+}
+
+func printResume(resumeIndex, skipped, size int64) {
+	if resumeIndex == cnst.IgnoreVar {
+		fmt.Printf("\nResumed interrupted store, all %s were already stored\n", humanize.Bytes(uint64(skipped)))
+		return
+	}
+	fmt.Printf("\nResumed interrupted store from offset %d, skipped %s of %s already stored\n",
+		resumeIndex, humanize.Bytes(uint64(skipped)), humanize.Bytes(uint64(size)))
+}
+
+// ingestStreamData cuts chonks out of a window as large as the largest chonk,
+// so boundaries come out the same as when the file is mapped
+func ingestStreamData(infile structs.InputFile, hasher *digest.Hasher, r io.Reader, ranges []*structs.IngestRange) (size int64, err error) {
+	// the size of a stream is unknown until it is drained
+	bar := progressbar.DefaultBytes(cmp.Or(infile.GetSize(), -1))
+
+	tio, err := initThreadIO(infile)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if closeErr := closeThreadIO(tio); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	resume, err := hasRelations(infile.GetHash(), infile.GetDB())
+	if err != nil {
+		return 0, err
+	}
+
+	var active int
+	var eof bool
+	var skipped, pending int64
+	resumeIndex := cnst.IgnoreVar
+	rhasher := newRangeHasher(ranges)
+	window := make([]byte, 0, cnst.ChonkSize*cdc.MaxFactor)
+	for {
+		if !eof {
+			n, readErr := io.ReadFull(r, window[len(window):cap(window)])
+			window = window[:len(window)+n]
+			if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+				eof = true
+			} else if readErr != nil {
+				return 0, readErr
+			}
+		}
+		if len(window) == 0 {
+			break
+		}
+
+		buffsize := util.GetNextChonkSize(window)
+		chonk := bytes.Clone(window[:buffsize])
+		window = window[:copy(window, window[buffsize:])]
+		hasher.Write(chonk)
+		rhasher.write(chonk, size)
+
+		tio.Index = size
+		tio.ChonkEnd = size + buffsize
+		size += buffsize
+
+		if resume {
+			stored, err := isChonkStored(infile.GetHash(), tio.Index, tio.BlockMgr, infile.GetDB())
+			if err != nil {
+				return 0, err
+			}
+			if stored {
+				skipped += buffsize
+				bar.Add64(buffsize)
+				continue
+			}
+			if resumeIndex == cnst.IgnoreVar {
+				resumeIndex = tio.Index
+			}
+		}
+
+		go storeWorker(tio, chonk)
+		active++
+		pending += buffsize
+
+		if pending >= cnst.CheckpointSize {
+			err = checkpoint(&tio, active)
+			if err != nil {
+				return 0, err
+			}
+			active, pending = 0, 0
+		} else if active > cnst.GetMaxThreadCount() {
+			workerErr := <-tio.Err
+			if workerErr != nil {
+				return 0, workerErr
+			}
+			active--
+		}
+		bar.Add64(buffsize)
+	}
+
+	for active > 0 {
+		workerErr := <-tio.Err
+		if workerErr != nil {
+			return 0, workerErr
+		}
+		active--
+	}
+
+	err = tio.Batch.Flush()
+	if err != nil {
+		return 0, err
+	}
+	dbio.ForgetRelationIndices(infile.GetHash())
+
+	bar.Finish()
+	err = bar.Close()
+	if resume {
+		printResume(resumeIndex, skipped, size)
+	}
+	return size, err
+}
+
+// initThreadIO sets up what the dedup workers of infile share, closeThreadIO must be called once they are done
+func initThreadIO(infile structs.InputFile) (structs.ThreadIO, error) {
+	var tio structs.ThreadIO
+	tio.FHash = infile.GetHash()
+	tio.DB = infile.GetDB()
+
+	// Create container manager only if container mode is enabled
+	if cnst.CONTAINERMODE {
+		tio.ContainerMgr = fio.NewContainerManager(infile.GetDB().Opts().Dir)
+
+		// Create block manager if hierarchical index is enabled
+		if cnst.HIERARCHICALINDEX {
+			tio.BlockMgr = fio.NewBlockManager(infile.GetDB().Opts().Dir, tio.ContainerMgr)
+		}
+	}
+
+	var err error
+	tio.Batch, err = util.InitBatch(infile.GetDB())
+	if err != nil {
+		return tio, errors.Join(err, closeThreadIO(tio))
+	}
+	tio.Err = make(chan error, cnst.GetMaxThreadCount())
+	return tio, nil
+}
+
+func closeThreadIO(tio structs.ThreadIO) error {
+	var err error
+	if tio.BlockMgr != nil {
+		err = tio.BlockMgr.Close()
+	}
+	if tio.ContainerMgr != nil {
+		if closeErr := tio.ContainerMgr.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func hashSegments(mappedFile mmap.MMap, segments chan [2]int64, hasher *digest.Hasher, rhasher *rangeHasher, hashed chan struct{}) {
+	for segment := range segments {
+		data := mappedFile[segment[0]:segment[1]]
+		hasher.Write(data)
+		rhasher.write(data, segment[0])
+	}
+	close(hashed)
+}
+
+type activeRange struct {
+	irange *structs.IngestRange
+	hasher *digest.Hasher
+	runs   []structs.Extent
+}
+
+// rangeHasher hashes ranges out of a stream of consecutive chonks, a range is only hashed
+// while the chonks overlap it. Fragmented ranges are hashed a run at a time, ranges whose
+// runs are not in disk order cannot be and are left for hashUnorderedRanges
+type rangeHasher struct {
+	ranges []*structs.IngestRange
+	next   int
+	active []activeRange
+}
+
+func newRangeHasher(ranges []*structs.IngestRange) *rangeHasher {
+	var sorted []*structs.IngestRange
+	for _, irange := range ranges {
+		if irange.Ordered() {
+			sorted = append(sorted, irange)
+		}
+	}
+	slices.SortFunc(sorted, func(a, b *structs.IngestRange) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
+	return &rangeHasher{ranges: sorted}
+}
+
+func (r *rangeHasher) write(data []byte, offset int64) {
+	end := offset + int64(len(data))
+	for r.next < len(r.ranges) && r.ranges[r.next].Start < end {
+		r.active = append(r.active, activeRange{r.ranges[r.next], digest.New(), r.ranges[r.next].Runs()})
+		r.next++
+	}
+
+	kept := r.active[:0]
+	for _, active := range r.active {
+		for len(active.runs) > 0 {
+			run := active.runs[0]
+			runEnd := run.Start + run.Size
+			from := max(run.Start, offset)
+			to := min(runEnd, end)
+			if from < to {
+				active.hasher.Write(data[from-offset : to-offset])
+			}
+			if runEnd > end {
+				break
+			}
+			active.runs = active.runs[1:]
+		}
+		if len(active.runs) == 0 {
+			active.irange.Hash = active.hasher.Sum(nil)
+			active.irange.Digests = active.hasher.Digests()
+			continue
+		}
+		kept = append(kept, active)
+	}
+	r.active = kept
+}
+
+// hashUnorderedRanges hashes the fragmented ranges whose runs are not in disk order,
+// their runs are read back from the evidence file in file order
+func hashUnorderedRanges(r io.ReaderAt, ranges []*structs.IngestRange) error {
+	for _, irange := range ranges {
+		if irange.Ordered() || irange.Hashed() {
+			continue
+		}
+		err := hashRange(r, irange)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commitRelations moves the relations written under provisional to fhash a window at a
+// time. Relations fhash already has are kept, and if fhash is already a completed evidence
+// file the provisional relations are only dropped, storing a file that is already in the DB is harmless
+func commitRelations(provisional, fhash []byte, db *badger.DB) error {
+	completed, err := isCompleted(fhash, db)
+	if err != nil {
+		return err
+	}
+	return moveRelations(provisional, fhash, completed, db)
+}
+
+// moveRelations moves the relations of provisional to fhash, or only drops them when drop
+// is set. The pending marker of provisional goes once no relation is left under it
+func moveRelations(provisional, fhash []byte, drop bool, db *badger.DB) error {
+	prefix := util.AppendToBytesSlice(cnst.RelationNamespace, provisional, cnst.DataSeperator)
+	for {
+		// committed keys are deleted, so every window starts again at the prefix
+		indices, chashes, err := nextRelations(prefix, db)
+		if err != nil {
+			return err
+		}
+		if len(indices) == 0 {
+			break
+		}
+
+		batch, err := util.InitBatch(db)
+		if err != nil {
+			return err
+		}
+		for i, index := range indices {
+			err = commitRelation(index, provisional, fhash, chashes[i], drop, db, batch)
+			if err != nil {
+				batch.Cancel()
+				return err
+			}
+		}
+		err = batch.Flush()
+		if err != nil {
+			return err
+		}
+	}
+
+	dbio.ForgetRelationIndices(provisional)
+	dbio.ForgetRelationIndices(fhash)
+	return dbio.DeletePendingStore(provisional, db)
+}
+
+func isCompleted(fhash []byte, db *badger.DB) (bool, error) {
+	efile, err := dbio.GetEvidenceFile(util.AppendToBytesSlice(cnst.EviFileNamespace, fhash), db)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return false, nil
+	}
+	return efile.Completed, err
+}
+
+// nextRelations reads up to cnst.RelationWindow relations under prefix
+func nextRelations(prefix []byte, db *badger.DB) ([]int64, [][]byte, error) {
+	var indices []int64
+	var chashes [][]byte
+	err := db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix) && len(indices) < cnst.RelationWindow; it.Next() {
+			index, err := strconv.ParseInt(string(it.Item().Key()[len(prefix):]), 10, 64)
+			if err != nil {
+				return err
+			}
+			data, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			indices = append(indices, index)
+			chashes = append(chashes, dbio.DecodeNode(data))
+		}
+		return nil
+	})
+	return indices, chashes, err
+}
+
+// commitRelation moves one relation from provisional to fhash, or only drops it
+func commitRelation(index int64, provisional, fhash, chash []byte, drop bool, db *badger.DB, batch *badger.WriteBatch) error {
+	if !drop {
+		err := processRel(index, fhash, chash, db, batch)
+		if err != nil {
+			return err
+		}
+	}
+
+	revRelKey := util.AppendToBytesSlice(cnst.ReverseRelationNamespace, chash, cnst.DataSeperator, index)
+	revRelMap, err := dbio.GetReverseRelationNode(revRelKey, db)
+	if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+		return err
+	}
+	if revRelMap == nil {
+		revRelMap = make(map[string]struct{})
+	}
+	delete(revRelMap, string(provisional))
+	if !drop {
+		revRelMap[string(fhash)] = struct{}{}
+	}
+	if len(revRelMap) == 0 {
+		err = batch.Delete(revRelKey)
+	} else {
+		err = dbio.SetReverseRelationNode(revRelKey, revRelMap, batch)
+	}
+	if err != nil {
+		return err
+	}
+
+	return batch.Delete(util.AppendToBytesSlice(cnst.RelationNamespace, provisional, cnst.DataSeperator, index))
+}
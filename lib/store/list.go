@@ -5,12 +5,15 @@ import (
 	"encoding/base64"
 	"fmt"
 	"indicer/lib/cnst"
+	"indicer/lib/digest"
 	"indicer/lib/structs"
 	"indicer/lib/util"
 	"strings"
+	"time"
 
 	"github.com/dgraph-io/badger/v4"
 	"github.com/dustin/go-humanize"
+	"github.com/fatih/color"
 	"github.com/vmihailenco/msgpack/v5"
 )
 
@@ -49,6 +52,11 @@ func List(db *badger.DB) error {
 			fmt.Println(base64.StdEncoding.EncodeToString(evihash))
 			fmt.Printf("\tNames: %v\n", evidata.Names)
 			fmt.Printf("\tSize: %v\n", humanize.Bytes(uint64(evidata.Size)))
+			if !evidata.CompletedAt.IsZero() {
+				fmt.Printf("\tVerified: %v (%s)\n", evidata.CompletedAt.Local().Format(time.RFC3339), evidata.ToolVersion)
+			}
+			listDigests(evidata.Digests)
+			listAcquisition(evidata.Acquisition)
 			for phash := range evidata.InternalObjects {
 				err = listPartitions(phash, txn)
 				if err != nil {
@@ -61,6 +69,51 @@ func List(db *badger.DB) error {
 	})
 }
 
+func listDigests(digests digest.Digests) {
+	if len(digests.MD5) > 0 {
+		fmt.Printf("\tMD5: %x\n", digests.MD5)
+	}
+	if len(digests.SHA1) > 0 {
+		fmt.Printf("\tSHA-1: %x\n", digests.SHA1)
+	}
+	if len(digests.SHA256) > 0 {
+		fmt.Printf("\tSHA-256: %x\n", digests.SHA256)
+	}
+}
+
+func listAcquisition(acq structs.Acquisition) {
+	if acq.CaseNumber != "" {
+		fmt.Printf("\tCase: %s\n", acq.CaseNumber)
+	}
+	if acq.ExhibitID != "" {
+		fmt.Printf("\tExhibit: %s\n", acq.ExhibitID)
+	}
+	if acq.Examiner != "" {
+		fmt.Printf("\tExaminer: %s\n", acq.Examiner)
+	}
+	if !acq.AcquiredAt.IsZero() {
+		fmt.Printf("\tAcquired: %s\n", acq.AcquiredAt.Local().Format(time.RFC3339))
+	}
+	if acq.DeviceSerial != "" {
+		fmt.Printf("\tDevice Serial: %s\n", acq.DeviceSerial)
+	}
+	if acq.Notes != "" {
+		fmt.Printf("\tNotes: %s\n", acq.Notes)
+	}
+	if len(acq.Tags) > 0 {
+		fmt.Printf("\tTags: %v\n", acq.Tags)
+	}
+	if len(acq.Digests.MD5) > 0 {
+		fmt.Printf("\tAcquisition MD5: %x\n", acq.Digests.MD5)
+	}
+	if len(acq.Digests.SHA1) > 0 {
+		fmt.Printf("\tAcquisition SHA-1: %x\n", acq.Digests.SHA1)
+	}
+	for _, name := range acq.DigestMismatch {
+		color.Red("\t⚠️  Acquisition %s does not match the stored media", name)
+	}
+}
+
 func listPartitions(phash string, txn *badger.Txn) error {
 	fmt.Printf("\tPartition: %v\n", phash)
 	decodedPhash, err := base64.StdEncoding.DecodeString(phash)
@@ -88,6 +141,7 @@ func listPartitions(phash string, txn *badger.Txn) error {
 	if err != nil {
 		return err
 	}
+	listPartitionTable(pdata.Table)
 
 	var index int
 	for ihash := range pdata.InternalObjects {
@@ -101,6 +155,31 @@ func listPartitions(phash string, txn *badger.Txn) error {
 	return nil
 }
 
+func listPartitionTable(table structs.PartitionTableEntry) {
+	if table.IsZero() {
+		return
+	}
+	fmt.Printf("\t\tScheme: %s\n", table.Scheme)
+	if table.Scheme == structs.SchemeMBR {
+		fmt.Printf("\t\tType: 0x%02X %s\n", table.Type, table.TypeName())
+		if table.Logical {
+			fmt.Println("\t\tLogical: true")
+		}
+	}
+	if table.TypeGUID != "" {
+		fmt.Printf("\t\tType GUID: %s\n", table.TypeGUID)
+	}
+	if table.GUID != "" {
+		fmt.Printf("\t\tGUID: %s\n", table.GUID)
+	}
+	if table.Label != "" {
+		fmt.Printf("\t\tLabel: %s\n", table.Label)
+	}
+	if table.Attributes != 0 {
+		fmt.Printf("\t\tAttributes: %#016x\n", table.Attributes)
+	}
+}
+
 func listIndexedFiles(index int, ihash string, txn *badger.Txn) error {
 	fmt.Printf("\t\tIndexed %d ---> %s\n", index, ihash)
 	decodedIhash, err := base64.StdEncoding.DecodeString(ihash)
@@ -137,7 +216,33 @@ func listIndexedFiles(index int, ihash string, txn *badger.Txn) error {
 		name := strings.Split(i, cnst.DataSeperator)[2]
 		idata.Names[name] = struct{}{}
 	}
-	fmt.Printf("\t\tNames: %v\n\n", idata.Names)
+	fmt.Printf("\t\tNames: %v\n", idata.Names)
+	if len(idata.Extents) > 0 {
+		fmt.Printf("\t\tExtents: %d\n", len(idata.Extents))
+	}
+	if idata.Space != "" {
+		fmt.Printf("\t\tSpace: %s\n", idata.Space)
+	}
+	if idata.Deleted {
+		fmt.Printf("\t\tDeleted: recovery confidence %.0f%%\n", idata.RecoveryConfidence*100)
+	}
+	listFileTimes(idata.Times)
+	fmt.Println()
 
 	return nil
 }
+
+func listFileTimes(times structs.FileTimes) {
+	if !times.Modified.IsZero() {
+		fmt.Printf("\t\tModified: %s\n", times.Modified.Local().Format(time.RFC3339))
+	}
+	if !times.Accessed.IsZero() {
+		fmt.Printf("\t\tAccessed: %s\n", times.Accessed.Local().Format(time.RFC3339))
+	}
+	if !times.Changed.IsZero() {
+		fmt.Printf("\t\tChanged: %s\n", times.Changed.Local().Format(time.RFC3339))
+	}
+	if !times.Created.IsZero() {
+		fmt.Printf("\t\tCreated: %s\n", times.Created.Local().Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,197 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"indicer/lib/cnst"
+	"indicer/lib/dbio"
+	"indicer/lib/util"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// orphans are the owners of relations nothing can reach anymore: hashes with neither an
+// evidence file nor the pending marker of a store that can be resumed. Interrupted streams
+// and stores that crashed between committing their relations and writing their evidence
+// file end up here
+type orphans struct {
+	// owners maps an orphaned owner to the number of relation and reverse relation keys naming it
+	owners  map[string]int
+	streams map[string]struct{}
+}
+
+func findOrphans(db *badger.DB) (orphans, error) {
+	found := orphans{owners: map[string]int{}, streams: map[string]struct{}{}}
+	pending, err := dbio.GetPendingStores(db)
+	if err != nil {
+		return found, err
+	}
+	for provisional, kind := range pending {
+		if kind == cnst.PendingStream {
+			found.streams[provisional] = struct{}{}
+		}
+	}
+
+	reachable := map[string]bool{}
+	isOrphan := func(owner string) (bool, error) {
+		ok, seen := reachable[owner]
+		if seen {
+			return !ok, nil
+		}
+		ok = pending[owner] == cnst.PendingFile
+		if !ok {
+			err := dbio.PingNode(util.AppendToBytesSlice(cnst.EviFileNamespace, owner), db)
+			if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+				return false, err
+			}
+			ok = err == nil
+		}
+		reachable[owner] = ok
+		return !ok, nil
+	}
+
+	err = walkRelationOwners(db, func(owners []string) error {
+		for _, owner := range owners {
+			orphan, err := isOrphan(owner)
+			if err != nil {
+				return err
+			}
+			if orphan {
+				found.owners[owner]++
+			}
+		}
+		return nil
+	})
+	return found, err
+}
+
+// walkRelationOwners calls fn with the owner of every relation key and the owners in every reverse relation
+func walkRelationOwners(db *badger.DB, fn func(owners []string) error) error {
+	return db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte(cnst.RelationNamespace)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			owner, ok := relationOwner(it.Item().Key())
+			if !ok {
+				continue
+			}
+			err := fn([]string{owner})
+			if err != nil {
+				return err
+			}
+		}
+
+		prefix = []byte(cnst.ReverseRelationNamespace)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			revRelMap, err := decodeReverseRelation(it.Item())
+			if err != nil {
+				return err
+			}
+			owners := make([]string, 0, len(revRelMap))
+			for owner := range revRelMap {
+				owners = append(owners, owner)
+			}
+			err = fn(owners)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// relationOwner cuts the owner hash out of a relation key, the index after the last separator is decimal
+func relationOwner(key []byte) (string, bool) {
+	rest := key[len(cnst.RelationNamespace):]
+	end := bytes.LastIndex(rest, []byte(cnst.DataSeperator))
+	if end < 0 {
+		return "", false
+	}
+	return string(rest[:end]), true
+}
+
+func decodeReverseRelation(item *badger.Item) (map[string]struct{}, error) {
+	data, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	revRelMap := map[string]struct{}{}
+	err = msgpack.Unmarshal(dbio.DecodeNode(data), &revRelMap)
+	return revRelMap, err
+}
+
+// drop removes the relation keys of every orphaned owner and takes the owners out of the
+// reverse relations, emptied reverse relations are deleted so GC sees their chonks as dead.
+// The markers of interrupted streams go as well, nothing can pick those up again
+func (o orphans) drop(db *badger.DB) error {
+	if len(o.owners) == 0 && len(o.streams) == 0 {
+		return nil
+	}
+	batch, err := util.InitBatch(db)
+	if err != nil {
+		return err
+	}
+
+	err = db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for owner := range o.owners {
+			prefix := util.AppendToBytesSlice(cnst.RelationNamespace, owner, cnst.DataSeperator)
+			for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+				err := batch.Delete(it.Item().KeyCopy(nil))
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		prefix := []byte(cnst.ReverseRelationNamespace)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			revRelMap, err := decodeReverseRelation(it.Item())
+			if err != nil {
+				return err
+			}
+			before := len(revRelMap)
+			for owner := range revRelMap {
+				if _, ok := o.owners[owner]; ok {
+					delete(revRelMap, owner)
+				}
+			}
+			switch {
+			case len(revRelMap) == before:
+				continue
+			case len(revRelMap) == 0:
+				err = batch.Delete(it.Item().KeyCopy(nil))
+			default:
+				err = dbio.SetReverseRelationNode(it.Item().KeyCopy(nil), revRelMap, batch)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		batch.Cancel()
+		return err
+	}
+
+	for provisional := range o.streams {
+		err = batch.Delete(util.AppendToBytesSlice(cnst.PendingNamespace, provisional))
+		if err != nil {
+			return err
+		}
+	}
+	for owner := range o.owners {
+		dbio.ForgetRelationIndices([]byte(owner))
+	}
+	return batch.Flush()
+}
@@ -0,0 +1,98 @@
+package store
+
+import (
+	"indicer/lib/cnst"
+	"indicer/lib/dbio"
+	"indicer/lib/digest"
+	"indicer/lib/structs"
+	"indicer/lib/util"
+	"io"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// StoredReader reads a stored evidence file back through its relations, so what was
+// stored can be parsed without the evidence at hand. The last chonk read is kept, as
+// reads tend to follow on from each other
+type StoredReader struct {
+	ehash []byte
+	size  int64
+	db    *badger.DB
+	index int64
+	chonk []byte
+}
+
+func NewStoredReader(ehash []byte, size int64, db *badger.DB) *StoredReader {
+	return &StoredReader{ehash: ehash, size: size, db: db}
+}
+
+func (r *StoredReader) ReadAt(p []byte, off int64) (int, error) {
+	var read int
+	for read < len(p) && off+int64(read) < r.size {
+		offset := off + int64(read)
+		if r.chonk == nil || offset < r.index || offset >= r.index+int64(len(r.chonk)) {
+			err := r.readChonk(offset)
+			if err != nil {
+				return read, err
+			}
+		}
+		read += copy(p[read:], r.chonk[offset-r.index:])
+	}
+	if read < len(p) {
+		return read, io.EOF
+	}
+	return read, nil
+}
+
+// readChonk loads the chonk holding offset
+func (r *StoredReader) readChonk(offset int64) error {
+	indices, err := dbio.GetRelationIndices(r.ehash, offset, offset+1, r.db)
+	if err != nil {
+		return err
+	}
+	if len(indices) == 0 {
+		return io.ErrUnexpectedEOF
+	}
+
+	relKey := util.AppendToBytesSlice(cnst.RelationNamespace, r.ehash, cnst.DataSeperator, indices[0])
+	chash, err := dbio.GetNode(relKey, r.db)
+	if err != nil {
+		return err
+	}
+	data, err := dbio.GetChonkNode(util.AppendToBytesSlice(cnst.ChonkNamespace, chash), r.db)
+	if err != nil {
+		return err
+	}
+	if indices[0]+int64(len(data)) <= offset {
+		return io.ErrUnexpectedEOF
+	}
+	r.index = indices[0]
+	r.chonk = data
+	return nil
+}
+
+// HashStoredRanges hashes ranges of the stored evidence file ehash, reading them back from its chonks
+func HashStoredRanges(ehash []byte, size int64, ranges []*structs.IngestRange, db *badger.DB) error {
+	r := NewStoredReader(ehash, size, db)
+	for _, irange := range ranges {
+		err := hashRange(r, irange)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hashRange hashes the runs of irange read from r in file order
+func hashRange(r io.ReaderAt, irange *structs.IngestRange) error {
+	hasher := digest.New()
+	for _, run := range irange.Runs() {
+		_, err := io.Copy(hasher, io.NewSectionReader(r, run.Start, run.Size))
+		if err != nil {
+			return err
+		}
+	}
+	irange.Hash = hasher.Sum(nil)
+	irange.Digests = hasher.Digests()
+	return nil
+}
@@ -49,6 +49,20 @@ func checkCompleted(ehash []byte, db *badger.DB) error {
 	return nil
 }
 
+func enableContainerReadCache() {
+	// Configure cache size based on available memory (25% of available, max 4GB)
+	if cacheSize, err := cnst.GetCacheLimit(); err == nil {
+		// GetCacheLimit returns 25% of available memory
+		maxCache := int64(4 * cnst.GB)
+		if cacheSize > maxCache {
+			cacheSize = maxCache
+		}
+		fio.SetContainerReadCacheSize(cacheSize)
+	}
+
+	fio.EnableContainerReadCache()
+}
+
 func getIndexedFileMeta(fid []byte, db *badger.DB) (structs.FileMeta, error) {
 	var meta structs.FileMeta
 
@@ -64,6 +78,7 @@ func getIndexedFileMeta(fid []byte, db *badger.DB) (structs.FileMeta, error) {
 	meta.EviHash = ehash
 	meta.Start = ifile.Start
 	meta.Size = ifile.Size
+	meta.Extents = ifile.Extents
 	return meta, nil
 }
 func getPartitionFileMeta(fid []byte, db *badger.DB) (structs.FileMeta, error) {
@@ -115,35 +130,39 @@ func getEvidenceFileMeta(fid []byte, db *badger.DB) (structs.FileMeta, error) {
 }
 
 func restoreData(meta structs.FileMeta, dst *os.File, db *badger.DB) error {
-	// Configure cache size based on available memory (25% of available, max 4GB)
-	if cacheSize, err := cnst.GetCacheLimit(); err == nil {
-		// GetCacheLimit returns 25% of available memory
-		maxCache := int64(4 * cnst.GB)
-		if cacheSize > maxCache {
-			cacheSize = maxCache
+	enableContainerReadCache()
+	defer fio.DisableContainerReadCache()
+
+	bar := progressbar.DefaultBytes(meta.Size)
+	for _, run := range meta.Runs() {
+		err := restoreRun(meta.EviHash, run, dst, bar, db)
+		if err != nil {
+			return err
 		}
-		fio.SetContainerReadCacheSize(cacheSize)
 	}
 
-	fio.EnableContainerReadCache()
-	defer fio.DisableContainerReadCache()
+	bar.Finish()
+	fmt.Println("Restored file with size: ", humanize.Bytes(uint64(meta.Size)))
+	return bar.Close()
+}
 
-	var dbstart int64
-	if meta.Start > 0 {
-		dbstart = util.GetDBStartOffset(meta.Start)
+// restoreRun writes one run of a file, a file stored in one run has just the one
+func restoreRun(ehash []byte, run structs.Extent, dst *os.File, bar *progressbar.ProgressBar, db *badger.DB) error {
+	end := run.Start + run.Size
+	indices, err := dbio.GetRelationIndices(ehash, run.Start, end, db)
+	if err != nil {
+		return err
 	}
-	end := meta.Start + meta.Size
 
-	bar := progressbar.DefaultBytes(meta.Size)
-	for restoreIndex := dbstart; restoreIndex < end; restoreIndex += cnst.ChonkSize {
-		relKey := util.AppendToBytesSlice(cnst.RelationNamespace, meta.EviHash, cnst.DataSeperator, restoreIndex)
+	for _, restoreIndex := range indices {
+		relKey := util.AppendToBytesSlice(cnst.RelationNamespace, ehash, cnst.DataSeperator, restoreIndex)
 		chash, err := dbio.GetNode(relKey, db)
 		if err != nil {
 			return err
 		}
 
 		ckey := util.AppendToBytesSlice(cnst.ChonkNamespace, chash)
-		data, err := dbio.GetChonkData(restoreIndex, meta.Start, meta.Size, dbstart, end, ckey, db)
+		data, err := dbio.GetChonkData(restoreIndex, run.Start, end, ckey, db)
 		if err != nil {
 			return err
 		}
@@ -153,10 +172,7 @@ func restoreData(meta structs.FileMeta, dst *os.File, db *badger.DB) error {
 			return err
 		}
 
-		bar.Add64(cnst.ChonkSize)
+		bar.Add(len(data))
 	}
-
-	bar.Finish()
-	fmt.Println("Restored file with size: ", humanize.Bytes(uint64(meta.Size)))
-	return bar.Close()
+	return nil
 }
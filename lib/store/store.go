@@ -10,7 +10,6 @@ import (
 	"indicer/lib/util"
 
 	"github.com/dgraph-io/badger/v4"
-	"github.com/schollz/progressbar/v3"
 	"golang.org/x/crypto/sha3"
 )
 
@@ -37,6 +36,58 @@ func EvidenceFilePreStoreCheck(infile structs.InputFile) error {
 	return dbio.SetFile(infile.GetID(), evidenceFile, infile.GetDB())
 }
 
+// SetAcquisition merges acq into the acquisition metadata stored on an evidence file
+func SetAcquisition(eid []byte, acq structs.Acquisition, db *badger.DB) error {
+	if acq.IsZero() {
+		return nil
+	}
+
+	evidenceFile, err := dbio.GetEvidenceFile(eid, db)
+	if err != nil {
+		return err
+	}
+	evidenceFile.Acquisition.Merge(acq)
+	return dbio.SetFile(eid, evidenceFile, db)
+}
+
+// AddInternalObjects adds the partitions in objects to a stored evidence file
+func AddInternalObjects(eid []byte, objects map[string]structs.InternalOffset, db *badger.DB) error {
+	if len(objects) == 0 {
+		return nil
+	}
+
+	evidenceFile, err := dbio.GetEvidenceFile(eid, db)
+	if err != nil {
+		return err
+	}
+	if evidenceFile.InternalObjects == nil {
+		evidenceFile.InternalObjects = make(map[string]structs.InternalOffset)
+	}
+	for ohash, offset := range objects {
+		evidenceFile.InternalObjects[ohash] = offset
+	}
+	return dbio.SetFile(eid, evidenceFile, db)
+}
+
+// AddPartitionInternalObjects records objects found in a partition after it was stored, such as carved files
+func AddPartitionInternalObjects(pid []byte, objects map[string]structs.InternalOffset, db *badger.DB) error {
+	if len(objects) == 0 {
+		return nil
+	}
+
+	partitionFile, err := dbio.GetPartitionFile(pid, db)
+	if err != nil {
+		return err
+	}
+	if partitionFile.InternalObjects == nil {
+		partitionFile.InternalObjects = make(map[string]structs.InternalOffset)
+	}
+	for ohash, offset := range objects {
+		partitionFile.InternalObjects[ohash] = offset
+	}
+	return dbio.SetFile(pid, partitionFile, db)
+}
+
 func storePartitionFile(infile structs.InputFile) error {
 	partitionFile, err := dbio.GetPartitionFile(infile.GetID(), infile.GetDB())
 	if errors.Is(err, badger.ErrKeyNotFound) {
@@ -46,29 +97,35 @@ func storePartitionFile(infile structs.InputFile) error {
 			infile.GetSize(),
 			infile.GetInternalObjects(),
 		)
-		return dbio.SetFile(infile.GetID(), partitionFile, infile.GetDB())
+		partitionFile.Digests = infile.GetDigests()
+		partitionFile.Table = infile.GetPartitionTable()
+		err = dbio.SetFile(infile.GetID(), partitionFile, infile.GetDB())
+		if err != nil {
+			return err
+		}
+		return dbio.SetDigestIndex(partitionFile.Digests, infile.GetHash(), infile.GetDB())
 	}
 	if err != nil && err != badger.ErrKeyNotFound {
 		return err
 	}
 
-	if _, ok := partitionFile.Names[infile.GetName()]; ok {
+	// the same partition found as a bare file system first has no table entry yet
+	_, named := partitionFile.Names[infile.GetName()]
+	untabled := partitionFile.Table.IsZero() && !infile.GetPartitionTable().IsZero()
+	if named && !untabled {
 		return nil
 	}
 
 	partitionFile.Names[infile.GetName()] = struct{}{}
+	if untabled {
+		partitionFile.Table = infile.GetPartitionTable()
+	}
 	return dbio.SetFile(infile.GetID(), partitionFile, infile.GetDB())
 }
 
 func storeEvidenceFile(infile structs.InputFile) error {
-	evidenceFile, err := evidenceFilePreflight(infile)
-	if err != nil {
-		return err
-	}
-	if evidenceFile.Completed {
-		return nil
-	}
-	return storeEvidenceData(infile)
+	_, err := evidenceFilePreflight(infile)
+	return err
 }
 func evidenceFilePreflight(infile structs.InputFile) (structs.EvidenceFile, error) {
 	evidenceFile, err := dbio.GetEvidenceFile(infile.GetID(), infile.GetDB())
@@ -79,7 +136,12 @@ func evidenceFilePreflight(infile structs.InputFile) (structs.EvidenceFile, erro
 			infile.GetSize(),
 			infile.GetInternalObjects(),
 		)
+		evidenceFile.Digests = infile.GetDigests()
 		err = dbio.SetFile(infile.GetID(), evidenceFile, infile.GetDB())
+		if err != nil {
+			return evidenceFile, err
+		}
+		err = dbio.SetDigestIndex(evidenceFile.Digests, infile.GetHash(), infile.GetDB())
 		return evidenceFile, err
 	}
 	if err != nil && err != badger.ErrKeyNotFound {
@@ -97,95 +159,7 @@ func evidenceFilePreflight(infile structs.InputFile) (structs.EvidenceFile, erro
 	err = dbio.SetFile(infile.GetID(), evidenceFile, infile.GetDB())
 	return evidenceFile, err
 }
-func storeEvidenceData(infile structs.InputFile) (err error) {
-
-	bar := progressbar.DefaultBytes(infile.GetSize())
-
-	var tio structs.ThreadIO
-	tio.FHash = infile.GetHash()
-	tio.DB = infile.GetDB()
-
-	// Create container manager only if container mode is enabled
-	if cnst.CONTAINERMODE {
-		containerMgr := fio.NewContainerManager(infile.GetDB().Opts().Dir)
-		defer func() {
-			if closeErr := containerMgr.Close(); closeErr != nil && err == nil {
-				err = closeErr
-			}
-		}()
-		tio.ContainerMgr = containerMgr
-
-		// Create block manager if hierarchical index is enabled
-		if cnst.HIERARCHICALINDEX {
-			blockMgr := fio.NewBlockManager(infile.GetDB().Opts().Dir, containerMgr)
-			defer func() {
-				if closeErr := blockMgr.Close(); closeErr != nil && err == nil {
-					err = closeErr
-				}
-			}()
-			tio.BlockMgr = blockMgr
-		} else {
-			tio.BlockMgr = nil
-		}
-	} else {
-		tio.ContainerMgr = nil
-		tio.BlockMgr = nil
-	}
-
-	tio.Batch, err = util.InitBatch(infile.GetDB())
-	if err != nil {
-		return err
-	}
-
-	tio.Err = make(chan error, cnst.GetMaxThreadCount())
-	tio.MappedFile = infile.GetMappedFile()
-
-	var active int
-	var buffsize int64
-	for storeIndex := infile.GetStartIndex(); storeIndex < infile.GetSize(); storeIndex += cnst.ChonkSize {
-		tio.Index = storeIndex
-
-		if infile.GetSize()-storeIndex <= cnst.ChonkSize {
-			buffsize = infile.GetSize() - storeIndex
-		} else {
-			buffsize = cnst.ChonkSize
-		}
-
-		tio.ChonkEnd = tio.Index + buffsize
-		go storeWorker(tio)
-		active++
-
-		if active > cnst.GetMaxThreadCount() {
-			workerErr := <-tio.Err
-			if workerErr != nil {
-				return workerErr
-			}
-			active--
-			bar.Add64(buffsize)
-		}
-	}
-
-	for active > 0 {
-		workerErr := <-tio.Err
-		if workerErr != nil {
-			return workerErr
-		}
-		active--
-		bar.Add64(cnst.ChonkSize)
-	}
-
-	err = tio.Batch.Flush()
-	if err != nil {
-		return err
-	}
-
-	bar.Add64(cnst.ChonkSize)
-	bar.Finish()
-	err = bar.Close()
-	return err
-}
-func storeWorker(tio structs.ThreadIO) {
-	lostChonk := tio.MappedFile[tio.Index:tio.ChonkEnd]
+func storeWorker(tio structs.ThreadIO, lostChonk []byte) {
 	chash, err := util.GetChonkHash(lostChonk, sha3.New512())
 	if err != nil {
 		tio.Err <- err
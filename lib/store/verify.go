@@ -0,0 +1,432 @@
+package store
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"indicer/lib/cnst"
+	"indicer/lib/dbio"
+	"indicer/lib/fio"
+	"indicer/lib/structs"
+	"indicer/lib/util"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/fatih/color"
+	"github.com/schollz/progressbar/v3"
+	"golang.org/x/crypto/sha3"
+)
+
+type verifier struct {
+	db         *badger.DB
+	report     structs.VerifyReport
+	containers map[string]int64                // resolved container path -> decompressed size, -1 if corrupt
+	chonks     map[string]*structs.VerifyIssue // chonk hash -> problem found with it, nil if it is intact
+	reportPath string
+}
+
+// Verify re-derives every evidence, partition and indexed object from the stored chonks
+// and checks containers and the block index along the way, the database is never written
+func Verify(db *badger.DB) error {
+	v := verifier{
+		db:         db,
+		containers: map[string]int64{},
+		chonks:     map[string]*structs.VerifyIssue{},
+		reportPath: filepath.Join(db.Opts().Dir, cnst.VerifyReportFile),
+	}
+	v.report.ToolVersion = cnst.ToolVersion
+	v.report.StartedAt = time.Now().UTC()
+	v.report.Issues = []structs.VerifyIssue{}
+
+	enableContainerReadCache()
+	defer fio.DisableContainerReadCache()
+
+	err := v.checkContainers()
+	if err != nil {
+		return err
+	}
+	err = v.checkBlockIndex()
+	if err != nil {
+		return err
+	}
+	err = v.checkObjects()
+	if err != nil {
+		return err
+	}
+	err = v.checkRelations()
+	if err != nil {
+		return err
+	}
+
+	v.report.FinishedAt = time.Now().UTC()
+	v.report.ExecutiveSummary = fmt.Sprintf("%d evidence files, %d partitions and %d indexed files were re-derived from %d unique chunks, %d containers and %d block index entries were checked. %d issues were found.", v.report.EvidenceFiles, v.report.PartitionFiles, v.report.IndexedFiles, v.report.Chonks, v.report.Containers, v.report.BlockEntries, len(v.report.Issues))
+
+	reportData, err := json.MarshalIndent(v.report, "", "\t")
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(v.reportPath, reportData, 0o644)
+	if err != nil {
+		return err
+	}
+
+	return v.printSummary()
+}
+
+func (v *verifier) printSummary() error {
+	fmt.Printf("\n%s\n", v.report.ExecutiveSummary)
+	fmt.Printf("Report written to %s\n\n", v.reportPath)
+	if len(v.report.Issues) == 0 {
+		color.Green("✔ database verified, no drift found")
+		return nil
+	}
+
+	kinds := map[string]int{}
+	for _, issue := range v.report.Issues {
+		kinds[issue.Kind]++
+	}
+	for kind, count := range kinds {
+		color.Red("✘ %s: %d", kind, count)
+	}
+	return fmt.Errorf("%w: %d issues, see %s", cnst.ErrVerifyFailed, len(v.report.Issues), v.reportPath)
+}
+
+func (v *verifier) addIssue(kind, object, path, detail string) {
+	v.report.Issues = append(v.report.Issues, structs.VerifyIssue{
+		Kind:   kind,
+		Object: object,
+		Path:   path,
+		Detail: detail,
+	})
+}
+
+// checkContainers decodes every compressed container end to end, a container that
+// fails to decode is recorded once here and its chonks are reported as unreadable
+func (v *verifier) checkContainers() error {
+	containers, err := filepath.Glob(filepath.Join(v.db.Opts().Dir, cnst.BLOBSDIR, "*"+cnst.BLOBZSTEXT))
+	if err != nil {
+		return err
+	}
+	if len(containers) == 0 {
+		return nil
+	}
+
+	fmt.Println("Checking containers....")
+	bar := progressbar.Default(int64(len(containers)))
+	for _, cpath := range containers {
+		size, err := fio.CheckContainer(cpath)
+		if err != nil {
+			v.addIssue(cnst.IssueCorruptContainer, "", cpath, err.Error())
+			size = -1
+		}
+		v.containers[cpath] = size
+		v.report.Containers++
+		bar.Add(1)
+	}
+	return bar.Close()
+}
+
+func (v *verifier) containerSize(cpath string) (int64, error) {
+	cpath = fio.ResolveContainerPath(cpath)
+	if size, ok := v.containers[cpath]; ok {
+		if size < 0 {
+			return size, cnst.ErrCorruptContainer
+		}
+		return size, nil
+	}
+
+	size, err := fio.ContainerSize(cpath)
+	if err != nil {
+		return size, err
+	}
+	v.containers[cpath] = size
+	return size, nil
+}
+
+func (v *verifier) checkBlockIndex() error {
+	blockFiles, err := fio.BlockIndexFiles(v.db.Opts().Dir)
+	if err != nil {
+		return err
+	}
+
+	for _, blockFile := range blockFiles {
+		err = fio.WalkBlockFile(blockFile, func(chunkHash []byte, cpath string, offset, size int64) error {
+			v.report.BlockEntries++
+
+			csize, err := v.containerSize(cpath)
+			if errors.Is(err, cnst.ErrCorruptContainer) {
+				return nil
+			}
+			if err != nil {
+				v.addIssue(cnst.IssueDanglingBlockEntry, "", blockFile, fmt.Sprintf("chunk %s: %v", base64.StdEncoding.EncodeToString(chunkHash), err))
+				return nil
+			}
+			if offset < 0 || size < 0 || offset+size > csize {
+				v.addIssue(cnst.IssueDanglingBlockEntry, "", blockFile, fmt.Sprintf("chunk %s: range %d+%d is outside of %s (%d bytes)", base64.StdEncoding.EncodeToString(chunkHash), offset, size, cpath, csize))
+			}
+			return nil
+		})
+		if err != nil {
+			v.addIssue(cnst.IssueDanglingBlockEntry, "", blockFile, fmt.Sprintf("unreadable block index: %v", err))
+		}
+	}
+	return nil
+}
+
+func (v *verifier) checkObjects() error {
+	var ids [][]byte
+	for _, namespace := range []string{cnst.EviFileNamespace, cnst.PartiFileNamespace, cnst.IdxFileNamespace} {
+		nids, err := listObjectIDs(namespace, v.db)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, nids...)
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	fmt.Println("Re-deriving stored files....")
+	bar := progressbar.Default(int64(len(ids)))
+	for _, fid := range ids {
+		err := v.checkObject(fid)
+		if err != nil {
+			return err
+		}
+		bar.Add(1)
+	}
+	return bar.Close()
+}
+
+// checkRelations reports relations no evidence file or resumable store owns, gc drops them
+func (v *verifier) checkRelations() error {
+	orphaned, err := findOrphans(v.db)
+	if err != nil {
+		return err
+	}
+	for _, owner := range slices.Sorted(maps.Keys(orphaned.owners)) {
+		detail := fmt.Sprintf("%d relation and reverse relation keys with no evidence file, run gc to drop them", orphaned.owners[owner])
+		if _, ok := orphaned.streams[owner]; ok {
+			detail = fmt.Sprintf("%d relation and reverse relation keys of an interrupted stream, run gc to drop them", orphaned.owners[owner])
+		}
+		v.addIssue(cnst.IssueOrphanedRelations, "relations "+base64.StdEncoding.EncodeToString([]byte(owner)), "", detail)
+	}
+	return nil
+}
+
+func listObjectIDs(namespace string, db *badger.DB) ([][]byte, error) {
+	var ids [][]byte
+	err := db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte(namespace)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			ids = append(ids, it.Item().KeyCopy(nil))
+		}
+		return nil
+	})
+	return ids, err
+}
+
+func (v *verifier) checkObject(fid []byte) error {
+	var fhash []byte
+	var object string
+	switch {
+	case bytes.HasPrefix(fid, []byte(cnst.EviFileNamespace)):
+		fhash = fid[len(cnst.EviFileNamespace):]
+		object = "evidence " + base64.StdEncoding.EncodeToString(fhash)
+		v.report.EvidenceFiles++
+	case bytes.HasPrefix(fid, []byte(cnst.PartiFileNamespace)):
+		fhash = fid[len(cnst.PartiFileNamespace):]
+		object = "partition " + base64.StdEncoding.EncodeToString(fhash)
+		v.report.PartitionFiles++
+	default:
+		fhash = fid[len(cnst.IdxFileNamespace):]
+		object = "indexed " + base64.StdEncoding.EncodeToString(fhash)
+		v.report.IndexedFiles++
+	}
+
+	meta, err := GetFileMeta(fid, v.db)
+	if errors.Is(err, cnst.ErrIncompleteFile) {
+		// partitions and indexed files of an incomplete evidence file are covered by its own issue
+		if bytes.HasPrefix(fid, []byte(cnst.EviFileNamespace)) {
+			v.addIssue(cnst.IssueIncompleteFile, object, "", "evidence file was never completed, run store again to resume")
+		}
+		return nil
+	}
+	if err != nil {
+		v.addIssue(cnst.IssueMissingObject, object, "", err.Error())
+		return nil
+	}
+
+	intact, sum, err := v.rederive(object, meta)
+	if err != nil {
+		return err
+	}
+	if intact && !bytes.Equal(sum, fhash) {
+		v.addIssue(cnst.IssueFileHashMismatch, object, "", fmt.Sprintf("re-derived content hashes to %s", base64.StdEncoding.EncodeToString(sum)))
+	}
+	return nil
+}
+
+// rederive hashes the byte ranges of meta from the stored chonks, it keeps going past
+// broken chonks so that every problem of the file ends up in the report
+func (v *verifier) rederive(object string, meta structs.FileMeta) (bool, []byte, error) {
+	hasher := sha3.New256()
+	intact := true
+	for _, run := range meta.Runs() {
+		runIntact, err := v.rederiveRun(object, meta.EviHash, run, hasher)
+		if err != nil {
+			return false, nil, err
+		}
+		intact = intact && runIntact
+	}
+	return intact, hasher.Sum(nil), nil
+}
+
+func (v *verifier) rederiveRun(object string, ehash []byte, run structs.Extent, hasher io.Writer) (bool, error) {
+	end := run.Start + run.Size
+	intact := true
+
+	index := run.Start
+	indices, err := dbio.GetRelationIndices(ehash, run.Start, run.Start+1, v.db)
+	if err != nil {
+		return false, err
+	}
+	if len(indices) > 0 {
+		index = indices[0]
+	}
+
+	for index < end {
+		data, issue, err := v.readChonk(ehash, index)
+		if err != nil {
+			return false, err
+		}
+		if issue != nil {
+			issue.Object = object
+			v.report.Issues = append(v.report.Issues, *issue)
+			intact = false
+
+			index, err = v.nextIndex(ehash, index, end)
+			if err != nil {
+				return false, err
+			}
+			continue
+		}
+
+		hasher.Write(dbio.TrimChonkData(data, index, run.Start, end))
+		index += int64(len(data))
+	}
+
+	return intact, nil
+}
+
+// nextIndex finds where the chonk after a broken one starts, its length can't be trusted
+func (v *verifier) nextIndex(ehash []byte, index, end int64) (int64, error) {
+	if !cnst.CDCMODE {
+		return index + cnst.ChonkSize, nil
+	}
+
+	indices, err := dbio.GetRelationIndices(ehash, index, end, v.db)
+	if err != nil {
+		return -1, err
+	}
+	for _, next := range indices {
+		if next > index {
+			return next, nil
+		}
+	}
+	return end, nil
+}
+
+func (v *verifier) readChonk(ehash []byte, index int64) ([]byte, *structs.VerifyIssue, error) {
+	relKey := util.AppendToBytesSlice(cnst.RelationNamespace, ehash, cnst.DataSeperator, index)
+	chash, err := dbio.GetNode(relKey, v.db)
+	if err == badger.ErrKeyNotFound {
+		return nil, &structs.VerifyIssue{Kind: cnst.IssueMissingChonk, Detail: fmt.Sprintf("offset %d: no relation key", index)}, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	issue, seen := v.chonks[string(chash)]
+	if seen && issue != nil {
+		return nil, withOffset(issue, index), nil
+	}
+
+	data, issue, err := v.checkChonk(chash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !seen {
+		v.chonks[string(chash)] = issue
+		v.report.Chonks++
+	}
+	if issue != nil {
+		return nil, withOffset(issue, index), nil
+	}
+	return data, nil, nil
+}
+
+func withOffset(issue *structs.VerifyIssue, index int64) *structs.VerifyIssue {
+	located := *issue
+	located.Detail = fmt.Sprintf("offset %d: %s", index, issue.Detail)
+	return &located
+}
+
+// checkChonk reads a chonk back from wherever it is kept and compares it with its hash
+func (v *verifier) checkChonk(chash []byte) ([]byte, *structs.VerifyIssue, error) {
+	chonk := "chunk " + base64.StdEncoding.EncodeToString(chash)
+	ckey := util.AppendToBytesSlice(cnst.ChonkNamespace, chash)
+
+	location, err := dbio.GetChonkLocation(ckey, v.db)
+	if err != nil {
+		return nil, &structs.VerifyIssue{Kind: cnst.IssueMissingChonk, Detail: fmt.Sprintf("%s: %v", chonk, err)}, nil
+	}
+
+	if location.ContainerPath == "" {
+		_, err = os.Stat(location.BlobPath)
+		if err != nil {
+			return nil, &structs.VerifyIssue{Kind: cnst.IssueMissingBlob, Path: location.BlobPath, Detail: fmt.Sprintf("%s: %v", chonk, err)}, nil
+		}
+	} else {
+		csize, err := v.containerSize(location.ContainerPath)
+		if errors.Is(err, cnst.ErrCorruptContainer) {
+			return nil, &structs.VerifyIssue{Kind: cnst.IssueUnreadableChonk, Path: location.ContainerPath, Detail: fmt.Sprintf("%s: stored in a corrupt container", chonk)}, nil
+		}
+		if err != nil {
+			return nil, &structs.VerifyIssue{Kind: cnst.IssueDanglingContainer, Path: location.ContainerPath, Detail: fmt.Sprintf("%s: %v", chonk, err)}, nil
+		}
+		if location.Offset < 0 || location.Size < 0 || location.Offset+location.Size > csize {
+			return nil, &structs.VerifyIssue{Kind: cnst.IssueDanglingContainer, Path: location.ContainerPath, Detail: fmt.Sprintf("%s: range %d+%d is outside of the container (%d bytes)", chonk, location.Offset, location.Size, csize)}, nil
+		}
+	}
+
+	data, err := dbio.GetChonkNode(ckey, v.db)
+	if err != nil {
+		return nil, &structs.VerifyIssue{Kind: cnst.IssueUnreadableChonk, Detail: fmt.Sprintf("%s: %v", chonk, err)}, nil
+	}
+	if len(data) == 0 {
+		return nil, &structs.VerifyIssue{Kind: cnst.IssueUnreadableChonk, Detail: fmt.Sprintf("%s: decoded to no data", chonk)}, nil
+	}
+
+	dhash, err := util.GetChonkHash(data, sha3.New512())
+	if err != nil {
+		return nil, nil, err
+	}
+	if !bytes.Equal(dhash, chash) {
+		return nil, &structs.VerifyIssue{Kind: cnst.IssueChonkHashMismatch, Detail: fmt.Sprintf("%s: stored data hashes to %s", chonk, base64.StdEncoding.EncodeToString(dhash))}, nil
+	}
+	return data, nil, nil
+}
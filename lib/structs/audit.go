@@ -0,0 +1,21 @@
+package structs
+
+import "time"
+
+// AuditEntry is one link of the chain of custody, Hash covers every other field
+// including PrevHash so rewriting or dropping an entry breaks every later link
+type AuditEntry struct {
+	Seq         uint64    `msgpack:"seq" json:"seq"`
+	Operation   string    `msgpack:"operation" json:"operation"`
+	Operator    string    `msgpack:"operator" json:"operator"`
+	Host        string    `msgpack:"host" json:"host"`
+	Peer        string    `msgpack:"peer,omitempty" json:"peer,omitempty"`
+	StartedAt   time.Time `msgpack:"started_at" json:"started_at"`
+	FinishedAt  time.Time `msgpack:"finished_at" json:"finished_at"`
+	Input       string    `msgpack:"input" json:"input"`
+	Hashes      []string  `msgpack:"hashes" json:"hashes"`
+	Outcome     string    `msgpack:"outcome" json:"outcome"`
+	ToolVersion string    `msgpack:"tool_version" json:"tool_version"`
+	PrevHash    []byte    `msgpack:"prev_hash" json:"prev_hash"`
+	Hash        []byte    `msgpack:"hash" json:"hash"`
+}
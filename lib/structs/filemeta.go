@@ -4,4 +4,20 @@ type FileMeta struct {
 	Start   int64
 	Size    int64
 	EviHash []byte
+	// Extents are set for fragmented indexed files only
+	Extents []Extent
+}
+
+// Runs returns the byte ranges of the evidence file that make up the file, in file order
+func (m FileMeta) Runs() []Extent {
+	return runs(m.Start, m.Size, m.Extents)
+}
+
+// ChonkLocation is where the encoded payload of a chonk lives, either a blob file
+// of its own or a byte range of a container
+type ChonkLocation struct {
+	BlobPath      string
+	ContainerPath string
+	Offset        int64
+	Size          int64
 }
@@ -1,12 +1,86 @@
 package structs
 
+import (
+	"indicer/lib/digest"
+	"time"
+)
+
 type baseFile struct {
-	Names map[string]struct{} `msgpack:"names"`
-	Size  int64               `msgpack:"size"`
+	Names   map[string]struct{} `msgpack:"names"`
+	Size    int64               `msgpack:"size"`
+	Digests digest.Digests      `msgpack:"digests"`
 }
 type IndexedFile struct {
 	baseFile
+	Start int64     `msgpack:"start"`
+	Times FileTimes `msgpack:"times"`
+	// Extents are the runs of a fragmented file in file order, they are left empty
+	// for a file stored in one run from Start
+	Extents []Extent `msgpack:"extents"`
+	// Deleted is only set while every name of the file comes from a deleted directory
+	// entry, RecoveryConfidence is the best recovery among those entries
+	Deleted            bool    `msgpack:"deleted"`
+	RecoveryConfidence float64 `msgpack:"recovery_confidence"`
+	// Space is only set while every name of the file is unallocated space or slack of that kind
+	Space string `msgpack:"space"`
+}
+
+// MergeSpace folds the space another sighting of the same content lies in into f,
+// it reports whether the space changed
+func (f *IndexedFile) MergeSpace(space string) bool {
+	if f.Space == "" || f.Space == space {
+		return false
+	}
+	f.Space = ""
+	return true
+}
+
+// MergeRecovery folds another sighting of the same content into the deletion state of f,
+// it reports whether the state changed
+func (f *IndexedFile) MergeRecovery(deleted bool, confidence float64) bool {
+	if !f.Deleted {
+		return false
+	}
+	if !deleted {
+		f.Deleted = false
+		f.RecoveryConfidence = 0
+		return true
+	}
+	if confidence > f.RecoveryConfidence {
+		f.RecoveryConfidence = confidence
+		return true
+	}
+	return false
+}
+
+// Runs returns where the bytes of the file are in the evidence file, in file order
+func (f IndexedFile) Runs() []Extent {
+	return runs(f.Start, f.Size, f.Extents)
+}
+
+// Extent is a byte range of the evidence file holding part of a fragmented file
+type Extent struct {
 	Start int64 `msgpack:"start"`
+	Size  int64 `msgpack:"size"`
+}
+
+func runs(start, size int64, extents []Extent) []Extent {
+	if len(extents) > 0 {
+		return extents
+	}
+	return []Extent{{Start: start, Size: size}}
+}
+
+// FileTimes are the timestamps a file system keeps for an indexed file, the ones it does not keep are zero
+type FileTimes struct {
+	Modified time.Time `msgpack:"modified"`
+	Accessed time.Time `msgpack:"accessed"`
+	Changed  time.Time `msgpack:"changed"`
+	Created  time.Time `msgpack:"created"`
+}
+
+func (t FileTimes) IsZero() bool {
+	return t.Modified.IsZero() && t.Accessed.IsZero() && t.Changed.IsZero() && t.Created.IsZero()
 }
 
 func NewIndexedFile(name string, start, size int64) IndexedFile {
@@ -15,12 +89,73 @@ func NewIndexedFile(name string, start, size int64) IndexedFile {
 }
 
 type InternalOffset struct {
-	Start int64
-	End   int64
+	Start   int64
+	End     int64
+	Extents []Extent
+}
+
+// Contains reports whether offset falls inside the object, End and the end of each extent are inclusive
+func (o InternalOffset) Contains(offset int64) bool {
+	if len(o.Extents) == 0 {
+		return offset >= o.Start && offset <= o.End
+	}
+	for _, extent := range o.Extents {
+		if offset >= extent.Start && offset < extent.Start+extent.Size {
+			return true
+		}
+	}
+	return false
 }
+
 type PartitionFile struct {
 	IndexedFile
 	InternalObjects map[string]InternalOffset `msgpack:"internal_objects"`
+	Table           PartitionTableEntry       `msgpack:"table"`
+}
+
+const (
+	SchemeGPT = "gpt"
+	SchemeMBR = "mbr"
+)
+
+// PartitionTableEntry is what the partition table says about a partition,
+// it is empty for a file system that takes up the whole evidence file
+type PartitionTableEntry struct {
+	Scheme string `msgpack:"scheme"`
+	// Type and Logical are only set for MBR partitions, Logical ones are found in an extended partition
+	Type       byte   `msgpack:"type"`
+	Logical    bool   `msgpack:"logical"`
+	TypeGUID   string `msgpack:"type_guid"`
+	GUID       string `msgpack:"guid"`
+	Label      string `msgpack:"label"`
+	Attributes uint64 `msgpack:"attributes"`
+}
+
+func (p PartitionTableEntry) IsZero() bool {
+	return p.Scheme == ""
+}
+
+var mbrTypeNames = map[byte]string{
+	0x01: "FAT12",
+	0x04: "FAT16 <32M",
+	0x06: "FAT16",
+	0x07: "NTFS/exFAT",
+	0x0B: "FAT32 CHS",
+	0x0C: "FAT32 LBA",
+	0x0E: "FAT16 LBA",
+	0x27: "Windows recovery",
+	0x82: "Linux swap",
+	0x83: "Linux",
+	0x8E: "Linux LVM",
+	0xA5: "FreeBSD",
+	0xAF: "HFS/HFS+",
+	0xEF: "EFI system",
+	0xFD: "Linux RAID",
+}
+
+// TypeName names the MBR type byte of the partition, empty for unknown types
+func (p PartitionTableEntry) TypeName() string {
+	return mbrTypeNames[p.Type]
 }
 
 func NewPartitionFile(name string, start, size int64, indexedFiles map[string]InternalOffset) PartitionFile {
@@ -30,8 +165,72 @@ func NewPartitionFile(name string, start, size int64, indexedFiles map[string]In
 
 type EvidenceFile struct {
 	PartitionFile
-	EvidenceType string `msgpack:"evidence_type"`
-	Completed    bool   `msgpack:"completed"`
+	EvidenceType string      `msgpack:"evidence_type"`
+	Completed    bool        `msgpack:"completed"`
+	CompletedAt  time.Time   `msgpack:"completed_at"`
+	ToolVersion  string      `msgpack:"tool_version"`
+	Acquisition  Acquisition `msgpack:"acquisition"`
+}
+
+// Acquisition ties an evidence file back to the case paperwork it was received with
+type Acquisition struct {
+	CaseNumber   string            `msgpack:"case_number" json:"case_number,omitempty"`
+	ExhibitID    string            `msgpack:"exhibit_id" json:"exhibit_id,omitempty"`
+	Examiner     string            `msgpack:"examiner" json:"examiner,omitempty"`
+	AcquiredAt   time.Time         `msgpack:"acquired_at" json:"acquired_at,omitzero"`
+	DeviceSerial string            `msgpack:"device_serial" json:"device_serial,omitempty"`
+	Notes        string            `msgpack:"notes" json:"notes,omitempty"`
+	Tags         map[string]string `msgpack:"tags" json:"tags,omitempty"`
+	// Digests are the ones the acquisition tool recorded, such as those embedded in an E01
+	Digests digest.Digests `msgpack:"digests" json:"digests,omitzero"`
+	// DigestMismatch names the recorded digests the stored media does not hash to
+	DigestMismatch []string `msgpack:"digest_mismatch" json:"digest_mismatch,omitempty"`
+}
+
+func (a Acquisition) IsZero() bool {
+	return a.CaseNumber == "" && a.ExhibitID == "" && a.Examiner == "" && a.AcquiredAt.IsZero() &&
+		a.DeviceSerial == "" && a.Notes == "" && len(a.Tags) == 0 && a.Digests.IsZero()
+}
+
+// Merge fills in the fields set in other, an evidence file stored again under
+// another case keeps what it already had unless the new store overrides it
+func (a *Acquisition) Merge(other Acquisition) {
+	if other.CaseNumber != "" {
+		a.CaseNumber = other.CaseNumber
+	}
+	if other.ExhibitID != "" {
+		a.ExhibitID = other.ExhibitID
+	}
+	if other.Examiner != "" {
+		a.Examiner = other.Examiner
+	}
+	if !other.AcquiredAt.IsZero() {
+		a.AcquiredAt = other.AcquiredAt
+	}
+	if other.DeviceSerial != "" {
+		a.DeviceSerial = other.DeviceSerial
+	}
+	if other.Notes != "" {
+		a.Notes = other.Notes
+	}
+	if len(other.Tags) > 0 && a.Tags == nil {
+		a.Tags = make(map[string]string, len(other.Tags))
+	}
+	for k, v := range other.Tags {
+		a.Tags[k] = v
+	}
+	if len(other.Digests.MD5) > 0 {
+		a.Digests.MD5 = other.Digests.MD5
+	}
+	if len(other.Digests.SHA1) > 0 {
+		a.Digests.SHA1 = other.Digests.SHA1
+	}
+	if len(other.Digests.SHA256) > 0 {
+		a.Digests.SHA256 = other.Digests.SHA256
+	}
+	if !other.Digests.IsZero() {
+		a.DigestMismatch = other.DigestMismatch
+	}
 }
 
 func NewEvidenceFile(name string, start, size int64, partitions map[string]InternalOffset) EvidenceFile {
@@ -0,0 +1,43 @@
+package structs
+
+import "indicer/lib/digest"
+
+// IngestRange is a byte range of an evidence file, partition or indexed file,
+// whose hash is computed while the evidence file is being chunked
+type IngestRange struct {
+	Name    string
+	Start   int64
+	Size    int64
+	Hash    []byte
+	Digests digest.Digests
+	Times   FileTimes
+	// Extents are the runs of a fragmented file in file order, Start is where the first one begins
+	Extents []Extent
+	// Deleted is set for a file recovered from a deleted directory entry, RecoveryConfidence
+	// is how likely its bytes are still the ones it was deleted with, from 0 to 1
+	Deleted            bool
+	RecoveryConfidence float64
+	// Space is set for a synthetic range covering unallocated space or slack instead of a file
+	Space string
+}
+
+// Runs returns the byte ranges of the evidence file that make up the range, in file order
+func (r IngestRange) Runs() []Extent {
+	return runs(r.Start, r.Size, r.Extents)
+}
+
+// Ordered reports whether the runs of the range follow each other on disk in file order,
+// only then can they be hashed while the evidence file is read front to back
+func (r IngestRange) Ordered() bool {
+	for index := 1; index < len(r.Extents); index++ {
+		if r.Extents[index].Start < r.Extents[index-1].Start+r.Extents[index-1].Size {
+			return false
+		}
+	}
+	return true
+}
+
+// Hashed reports whether the ingest pass covered the whole range
+func (r IngestRange) Hashed() bool {
+	return r.Hash != nil
+}
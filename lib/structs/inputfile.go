@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"encoding/base64"
 	"indicer/lib/cnst"
+	"indicer/lib/digest"
 	"indicer/lib/util"
+	"io"
 	"os"
 	"strings"
 
@@ -15,6 +17,7 @@ import (
 type InputFile struct {
 	fileHandle      *os.File
 	mappedFile      mmap.MMap
+	reader          io.ReaderAt
 	size            int64
 	id              []byte
 	name            string
@@ -22,6 +25,8 @@ type InputFile struct {
 	db              *badger.DB
 	batch           *badger.WriteBatch
 	internalObjects map[string]InternalOffset
+	digests         digest.Digests
+	table           PartitionTableEntry
 }
 
 func NewInputFile(
@@ -52,6 +57,22 @@ func (i InputFile) GetHandle() *os.File {
 func (i InputFile) GetMappedFile() mmap.MMap {
 	return i.mappedFile
 }
+
+// GetReader returns what the file is read through, the mapped file unless a reader was set
+func (i InputFile) GetReader() io.ReaderAt {
+	if i.reader != nil {
+		return i.reader
+	}
+	if i.mappedFile != nil {
+		return bytes.NewReader(i.mappedFile)
+	}
+	return i.fileHandle
+}
+
+// SetReader is used for evidence that is not mapped as is, such as the media inside an E01
+func (i *InputFile) SetReader(reader io.ReaderAt) {
+	i.reader = reader
+}
 func (i InputFile) GetID() []byte {
 	return i.id
 }
@@ -80,6 +101,18 @@ func (i InputFile) GetEncodedHash() ([]byte, error) {
 func (i InputFile) GetInternalObjects() map[string]InternalOffset {
 	return i.internalObjects
 }
+func (i InputFile) GetDigests() digest.Digests {
+	return i.digests
+}
+func (i *InputFile) SetDigests(digests digest.Digests) {
+	i.digests = digests
+}
+func (i InputFile) GetPartitionTable() PartitionTableEntry {
+	return i.table
+}
+func (i *InputFile) SetPartitionTable(table PartitionTableEntry) {
+	i.table = table
+}
 func (i InputFile) GetEviFileHash() []byte {
 	if strings.HasPrefix(i.name, cnst.EviFileNamespace) {
 		return i.GetHash()
@@ -92,10 +125,10 @@ func (i InputFile) GetNamespace() []byte {
 	return append(fileType, []byte(cnst.NamespaceSeperator)...)
 }
 
-func (i *InputFile) UpdateInternalObjects(start, size int64, objectHash []byte) {
+func (i *InputFile) UpdateInternalObjects(start, size int64, objectHash []byte, extents ...Extent) {
 	objHashStr := base64.StdEncoding.EncodeToString(objectHash)
 	end := (start + size) - 1
-	i.internalObjects[objHashStr] = InternalOffset{start, end}
+	i.internalObjects[objHashStr] = InternalOffset{start, end, extents}
 }
 
 func (i *InputFile) UpdateInputFile(name, namespace string, hash []byte, size, start int64) {
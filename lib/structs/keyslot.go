@@ -0,0 +1,13 @@
+package structs
+
+// KeySlot holds the database master key sealed with a key derived from the password,
+// rotating the password only reseals the master key so stored chonks stay untouched
+type KeySlot struct {
+	Version   int    `json:"version"`
+	KDF       string `json:"kdf"`
+	Salt      []byte `json:"salt"`
+	Time      uint32 `json:"time"`
+	Memory    uint32 `json:"memory"`
+	Threads   uint8  `json:"threads"`
+	SealedKey []byte `json:"sealed_key"`
+}
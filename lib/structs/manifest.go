@@ -0,0 +1,16 @@
+package structs
+
+import "time"
+
+// Manifest records how a database was created, chonk relation offsets
+// and blob encoding depend on these settings
+type Manifest struct {
+	Version           int       `json:"version"`
+	ToolVersion       string    `json:"tool_version"`
+	CreatedAt         time.Time `json:"created_at"`
+	ChonkSize         int64     `json:"chonk_size"`
+	ContainerMode     bool      `json:"container_mode"`
+	HierarchicalIndex bool      `json:"hierarchical_index"`
+	QuickMode         bool      `json:"quick_mode"`
+	CDCMode           bool      `json:"cdc_mode"`
+}
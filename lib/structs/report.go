@@ -1,5 +1,7 @@
 package structs
 
+import "time"
+
 type SearchReport struct {
 	Query            string          `json:"query"`
 	ExecutiveSummary string          `json:"executive_summary"`
@@ -7,16 +9,18 @@ type SearchReport struct {
 }
 
 type OccuranceData struct {
-	ArtefactHash string     `json:"artefact"`
-	Count        int        `json:"count"`
-	FileNames    []string   `json:"files,omitempty"`
-	Matches      []string   `json:"matches"`
-	Disk         *DiskImage `json:"disk,omitempty"`
+	ArtefactHash string       `json:"artefact"`
+	Count        int          `json:"count"`
+	FileNames    []string     `json:"files,omitempty"`
+	Matches      []string     `json:"matches"`
+	Acquisition  *Acquisition `json:"acquisition,omitempty"`
+	Disk         *DiskImage   `json:"disk,omitempty"`
 }
 
 type DiskImage struct {
 	DiskImageHash  string         `json:"disk_image_hash,omitempty"`
 	DiskImageNames []string       `json:"disk_image_names,omitempty"`
+	Acquisition    *Acquisition   `json:"acquisition,omitempty"`
 	Partition      *PartitionPart `json:"partition,omitempty"`
 }
 
@@ -27,8 +31,11 @@ type PartitionPart struct {
 }
 
 type IndexedPart struct {
-	IndexedFileHash  string   `json:"indexed_file_hash,omitempty"`
-	IndexedFileNames []string `json:"indexed_file_names,omitempty"`
+	IndexedFileHash    string   `json:"indexed_file_hash,omitempty"`
+	IndexedFileNames   []string `json:"indexed_file_names,omitempty"`
+	Deleted            bool     `json:"deleted,omitempty"`
+	RecoveryConfidence float64  `json:"recovery_confidence,omitempty"`
+	Space              string   `json:"space,omitempty"`
 }
 
 func NewDiskImage() *DiskImage {
@@ -42,3 +49,24 @@ func NewDiskImage() *DiskImage {
 		},
 	}
 }
+
+type VerifyReport struct {
+	ToolVersion      string        `json:"tool_version"`
+	StartedAt        time.Time     `json:"started_at"`
+	FinishedAt       time.Time     `json:"finished_at"`
+	ExecutiveSummary string        `json:"executive_summary"`
+	EvidenceFiles    int           `json:"evidence_files"`
+	PartitionFiles   int           `json:"partition_files"`
+	IndexedFiles     int           `json:"indexed_files"`
+	Chonks           int           `json:"chonks"`
+	Containers       int           `json:"containers"`
+	BlockEntries     int           `json:"block_entries"`
+	Issues           []VerifyIssue `json:"issues"`
+}
+
+type VerifyIssue struct {
+	Kind   string `json:"kind"`
+	Object string `json:"object,omitempty"`
+	Path   string `json:"path,omitempty"`
+	Detail string `json:"detail"`
+}
@@ -4,12 +4,16 @@ import (
 	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/md5"
 	"crypto/rand"
+	"crypto/sha1"
 	"crypto/sha256"
 	"encoding/base32"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"hash"
+	"indicer/lib/cdc"
 	"indicer/lib/cnst"
 	"io"
 	"os"
@@ -22,6 +26,7 @@ import (
 	"github.com/aoiflux/libxfat"
 	"github.com/cheggaaa/pb/v3"
 	"github.com/dgraph-io/badger/v4"
+	"golang.org/x/crypto/argon2"
 )
 
 func GetDBPath() (string, error) {
@@ -84,21 +89,6 @@ func GetFileHash(fileHandle *os.File, hasher hash.Hash) ([]byte, error) {
 	return hash, err
 }
 
-func GetLogicalFileHash(fileHandle *os.File, hasher hash.Hash, start, size int64, showBar bool) ([]byte, error) {
-	_, err := fileHandle.Seek(start, io.SeekStart)
-	if err != nil {
-		return nil, err
-	}
-
-	hash, err := getHash(fileHandle, hasher, size, showBar)
-	if err != nil {
-		return nil, err
-	}
-
-	_, err = fileHandle.Seek(0, io.SeekStart)
-	return hash, err
-}
-
 func getHash(fileHandle *os.File, hasher hash.Hash, size int64, showBar bool) ([]byte, error) {
 	var startTime time.Time
 	if showBar {
@@ -158,11 +148,17 @@ func AppendToBytesSlice(args ...interface{}) []byte {
 	return buffer.Bytes()
 }
 
-func HashPassword(password string) []byte {
-	hash := sha256.Sum256([]byte(password))
+// HashPassword is the unsalted key older releases used directly as the database key,
+// it is only kept to unlock databases created before key slots
+func HashPassword(password []byte) []byte {
+	hash := sha256.Sum256(password)
 	return hash[:]
 }
 
+func DeriveKey(password, salt []byte, time, memory uint32, threads uint8) []byte {
+	return argon2.IDKey(password, salt, time, memory, threads, cnst.KeySize)
+}
+
 func PartialMatchConfidence(s1, s2 []byte) float64 {
 	minLength := len(s1)
 	if len(s2) < minLength {
@@ -186,6 +182,18 @@ func GetDBStartOffset(startIndex int64) int64 {
 	return (startIndex / cnst.ChonkSize) * cnst.ChonkSize
 }
 
+// GetNextChonkSize returns the size of the chonk that starts at data[0]
+// fixed size in the default mode, content-defined in CDC mode
+func GetNextChonkSize(data []byte) int64 {
+	if cnst.CDCMODE {
+		return cdc.New(cnst.ChonkSize).Cut(data)
+	}
+	if int64(len(data)) <= cnst.ChonkSize {
+		return int64(len(data))
+	}
+	return cnst.ChonkSize
+}
+
 func GetDBEndOffset(endIndex int64) int64 {
 	if endIndex == 0 {
 		return 0
@@ -201,6 +209,25 @@ func GetEvidenceFileHash(fname string) ([]byte, error) {
 	}
 	return eviFileHash, err
 }
+
+// DecodeHash accepts hashes in the base64 DUES prints them in as well as the hex
+// acquisition tools print MD5, SHA-1 and SHA-256 digests in
+func DecodeHash(encoded string) ([]byte, error) {
+	decoded, err := hex.DecodeString(encoded)
+	if err == nil {
+		switch len(decoded) {
+		case md5.Size, sha1.Size, sha256.Size:
+			return decoded, nil
+		}
+	}
+
+	decoded, err = base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, cnst.ErrUnknownHashFormat
+	}
+	return decoded, nil
+}
+
 func GetEvidenceFileID(eviFileHash []byte) []byte {
 	return append([]byte(cnst.EviFileNamespace), eviFileHash...)
 }
@@ -214,31 +241,39 @@ func GetRandomName(length int) string {
 	return base32.StdEncoding.EncodeToString(randomBytes)[:length]
 }
 
+// SealAES encrypts plaintext with a fresh random nonce, the result is laid out as
+// SealVersion | nonce | ciphertext
 func SealAES(key, plaintext []byte) ([]byte, error) {
-	block, err := aes.NewCipher(key)
+	gcm, err := newGCM(key)
 	if err != nil {
 		return nil, err
 	}
 
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
+	sealed := make([]byte, 1+gcm.NonceSize(), 1+gcm.NonceSize()+len(plaintext)+gcm.Overhead())
+	sealed[0] = cnst.SealVersion
+	nonce := sealed[1:]
+	if _, err = rand.Read(nonce); err != nil {
 		return nil, err
 	}
 
-	nonce := sha256.Sum256(key)
-	ciphertext := gcm.Seal(nil, nonce[:gcm.NonceSize()], plaintext, nil)
-
-	return ciphertext, nil
+	return gcm.Seal(sealed, nonce, plaintext, nil), nil
 }
+
+// UnsealAES decrypts data sealed by SealAES as well as data sealed by older releases,
+// which carry no header and used a nonce derived from the key. A legacy ciphertext
+// can start with the version byte by chance, so a failed open falls back to it.
 func UnsealAES(key, ciphertext []byte) ([]byte, error) {
-	block, err := aes.NewCipher(key)
+	gcm, err := newGCM(key)
 	if err != nil {
 		return nil, err
 	}
 
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, err
+	headerSize := 1 + gcm.NonceSize()
+	if len(ciphertext) >= headerSize+gcm.Overhead() && ciphertext[0] == cnst.SealVersion {
+		plaintext, err := gcm.Open(nil, ciphertext[1:headerSize], ciphertext[headerSize:], nil)
+		if err == nil {
+			return plaintext, nil
+		}
 	}
 
 	nonce := sha256.Sum256(key)
@@ -250,6 +285,14 @@ func UnsealAES(key, ciphertext []byte) ([]byte, error) {
 	return plaintext, nil
 }
 
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
 func InitBatch(db *badger.DB) (*badger.WriteBatch, error) {
 	batch := db.NewWriteBatch()
 	count, err := cnst.GetMaxBatchCount()
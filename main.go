@@ -5,7 +5,6 @@ import (
 	"indicer/api"
 	"indicer/cli"
 	"indicer/lib/cnst"
-	"indicer/lib/util"
 	"os"
 
 	"github.com/alecthomas/kingpin/v2"
@@ -25,7 +24,7 @@ func init() {
 
 func main() {
 	app := kingpin.New("DUES", "Deduplicated Unified Evidence Store")
-	app.Version("DUES v0.36")
+	app.Version(cnst.ToolVersion)
 	dbpath := app.Flag(cnst.FlagDBPath, "Custom path for DUES database").Short(cnst.FlagDBPathShort).String()
 	pwd := app.Flag(cnst.FlagPassword, "Password for the DUES database").Short(cnst.FlagPasswordShort).String()
 	chonkSize := app.Flag(cnst.FlagChonkSize, "Custom chunk size(KB) to be used for dedup").Short(cnst.FlagChonkSizeShort).Default("256").Int()
@@ -33,11 +32,23 @@ func main() {
 	QUICKOPT := app.Flag(cnst.FlagFastMode, "Quick mode, forgoes encryption, intra-chunk & overall db compression in favour of higher throughput").Short(cnst.FlagFastModeShort).Default("false").Bool()
 	containerMode := app.Flag(cnst.FlagContainerMode, "Use container-based storage (packs multiple chunks into 1GB containers)").Short(cnst.FlagContainerModeShort).Default("false").Bool()
 	hierarchicalIndex := app.Flag(cnst.FlagHierarchicalIndex, "Use hierarchical block index (groups 1000 chunks per block, requires container mode)").Short(cnst.FlagHierarchicalShort).Default("false").Bool()
+	cdcMode := app.Flag(cnst.FlagCDCMode, "Use content-defined chunking (FastCDC), chunk size becomes the average chunk size").Short(cnst.FlagCDCModeShort).Default("false").Bool()
+	adopt := app.Flag(cnst.FlagAdopt, "Record the given chunk size and modes as the settings of a database created before manifests").Default("false").Bool()
 
 	cmdstore := app.Command(cnst.CmdStore, "Store file in database")
-	evipath := cmdstore.Arg(cnst.OperandFile, "Path of file that must be saved").Required().String()
-	syncIndex := cmdstore.Flag(cnst.FlagSyncIndex, "Run file indexer synchronously, this will block dedup").Short(cnst.FlagSyncIndexShort).Default("false").Bool()
+	evipath := cmdstore.Arg(cnst.OperandFile, "Path of file that must be saved, - reads it from stdin").Required().String()
+	// files are indexed from the ingest pass now, the flag is only kept so existing scripts still parse
+	cmdstore.Flag(cnst.FlagSyncIndex, "Ignored, files are indexed during the ingest pass").Short(cnst.FlagSyncIndexShort).Hidden().Bool()
 	noIndex := cmdstore.Flag(cnst.FlagNoIndex, "Don't run indexer").Short(cnst.FlagNoIndexShort).Default("false").Bool()
+	deleted := cmdstore.Flag(cnst.FlagDeleted, "Also index recoverable deleted files").Default("false").Bool()
+	caseNumber := cmdstore.Flag(cnst.FlagCaseNumber, "Case number the evidence belongs to").String()
+	exhibitID := cmdstore.Flag(cnst.FlagExhibitID, "Exhibit ID of the evidence").String()
+	examiner := cmdstore.Flag(cnst.FlagExaminer, "Examiner who acquired the evidence").String()
+	acquiredAt := cmdstore.Flag(cnst.FlagAcquiredAt, "Acquisition date, YYYY-MM-DD or RFC 3339").String()
+	deviceSerial := cmdstore.Flag(cnst.FlagDeviceSerial, "Serial number of the source device").String()
+	notes := cmdstore.Flag(cnst.FlagNotes, "Free text acquisition notes").String()
+	tags := cmdstore.Flag(cnst.FlagTag, "Arbitrary key=value tag, can be repeated").StringMap()
+	streamName := cmdstore.Flag(cnst.FlagStreamName, "Name to store evidence read from stdin under").Default(cnst.DefaultStreamName).String()
 
 	cmdrestore := app.Command(cnst.CmdRestore, "Restore file from database")
 	rpath := cmdrestore.Flag(cnst.FlagRestoreFilePath, "Path for restoring the file").Short(cnst.FlagRestoreFilePathShort).Default("restored").String()
@@ -45,6 +56,21 @@ func main() {
 
 	cmdlist := app.Command(cnst.CmdList, "List all the saved files in the database")
 
+	cmdverify := app.Command(cnst.CmdVerify, "Re-derive every stored file from its chunks and check the database for drift")
+
+	cmddelete := app.Command(cnst.CmdDelete, "Delete an evidence file along with its partitions and indexed files")
+	dhash := cmddelete.Arg(cnst.OperandHash, "Hash of the evidence file that must be deleted").String()
+	cmdgc := app.Command(cnst.CmdGC, "Reclaim chunks that are no longer referenced by any file")
+	cmdcompact := app.Command(cnst.CmdCompact, "Rewrite containers that are mostly dead chunks")
+	threshold := cmdcompact.Flag(cnst.FlagThreshold, "Rewrite containers whose live chunks take up less than this fraction of them").Short(cnst.FlagThresholdShort).Default("0.5").Float64()
+	cmdrekey := app.Command(cnst.CmdRekey, "Change the database password without re-encrypting stored evidence")
+	newpwd := cmdrekey.Flag(cnst.FlagNewPassword, "New password for the DUES database").Short(cnst.FlagNewPasswordShort).Required().String()
+
+	cmdcarve := app.Command(cnst.CmdCarve, "Carve files out of an evidence file or partition by their signatures")
+	chash := cmdcarve.Arg(cnst.OperandHash, "Hash of the evidence file or partition to carve").String()
+
+	cmdaudit := app.Command(cnst.CmdAudit, "Print the chain of custody audit log and verify its hash chain")
+
 	cmdnear := app.Command(cnst.CmdNear, "Get NeAr file objects")
 	cmdin := cmdnear.Command(cnst.SubCmdIn, "Finds NeAr objects & generates GReAt graph for file INside of the database")
 	deep := cmdin.Flag(cnst.FlagDeep, "Enable/Disable partial chunk match").Short(cnst.FlagDeepShort).Default("false").Bool()
@@ -66,6 +92,8 @@ func main() {
 	cnst.QUICKOPT = *QUICKOPT
 	cnst.CONTAINERMODE = *containerMode
 	cnst.HIERARCHICALINDEX = *hierarchicalIndex
+	cnst.CDCMODE = *cdcMode
+	cnst.ADOPTOPT = *adopt
 
 	// Hierarchical index requires container mode
 	if cnst.HIERARCHICALINDEX && !cnst.CONTAINERMODE {
@@ -73,7 +101,7 @@ func main() {
 		cnst.CONTAINERMODE = true
 	}
 
-	key := util.HashPassword(*pwd)
+	password := []byte(*pwd)
 
 	if cnst.MEMOPT {
 		color.Green("🍃 running in LOW RESOURCE mode 🍃")
@@ -89,24 +117,48 @@ func main() {
 	if cnst.HIERARCHICALINDEX {
 		color.Magenta("🏛 hierarchical index enabled (2-level lookup) 🏛")
 	}
+	if cnst.CDCMODE {
+		color.Blue("✂️  content-defined chunking enabled ✂️")
+	}
 
 	switch parsed {
 	case cmdstore.FullCommand():
-		err = cli.StoreData(*chonkSize, *dbpath, *evipath, key, *syncIndex, *noIndex)
+		acq, aerr := cli.ParseAcquisition(*caseNumber, *exhibitID, *examiner, *acquiredAt, *deviceSerial, *notes, *tags)
+		handle(aerr)
+		// kingpin hands a lone - over as an empty argument
+		if *evipath == "" {
+			*evipath = cnst.StdinPath
+		}
+		cnst.DELETEDOPT = *deleted
+		err = cli.StoreData(*chonkSize, *dbpath, *evipath, *streamName, password, *noIndex, acq)
 	case cmdrestore.FullCommand():
-		err = cli.RestoreData(*chonkSize, *dbpath, *rhash, *rpath, key)
+		err = cli.RestoreData(*chonkSize, *dbpath, *rhash, *rpath, password)
 	case cmdlist.FullCommand():
-		err = cli.ListData(*chonkSize, *dbpath, key)
+		err = cli.ListData(*chonkSize, *dbpath, password)
+	case cmdverify.FullCommand():
+		err = cli.VerifyData(*chonkSize, *dbpath, password)
+	case cmddelete.FullCommand():
+		err = cli.DeleteData(*chonkSize, *dbpath, *dhash, password)
+	case cmdgc.FullCommand():
+		err = cli.GCData(*chonkSize, *dbpath, password)
+	case cmdcompact.FullCommand():
+		err = cli.CompactData(*chonkSize, *dbpath, *threshold, password)
+	case cmdrekey.FullCommand():
+		err = cli.RekeyData(*chonkSize, *dbpath, password, []byte(*newpwd))
+	case cmdcarve.FullCommand():
+		err = cli.CarveData(*chonkSize, *dbpath, *chash, password)
+	case cmdaudit.FullCommand():
+		err = cli.AuditData(*chonkSize, *dbpath, password)
 	case cmdin.FullCommand():
-		err = cli.NearInData(*deep, *chonkSize, *dbpath, *inhash, key)
+		err = cli.NearInData(*deep, *chonkSize, *dbpath, *inhash, password)
 	case cmdout.FullCommand():
-		err = cli.NearOutData(*chonkSize, *dbpath, *outpath, key)
+		err = cli.NearOutData(*chonkSize, *dbpath, *outpath, password)
 	case cmdsearch.FullCommand():
-		err = cli.SearchCmd(*chonkSize, *query, *dbpath, key)
+		err = cli.SearchCmd(*chonkSize, *query, *dbpath, password)
 	case cmdreset.FullCommand():
-		err = cli.ResetData(*dbpath)
+		err = cli.ResetData(*chonkSize, *dbpath, password)
 	case apiserver.FullCommand():
-		err = api.Server(*chonkSize, *dbpath, key)
+		err = api.Server(*chonkSize, *dbpath, password)
 	}
 
 	handle(err)
@@ -27,6 +27,7 @@ type BaseFile struct {
 	FileId        string                 `protobuf:"bytes,2,opt,name=file_id,json=fileId,proto3" json:"file_id,omitempty"`
 	FileSize      int64                  `protobuf:"varint,3,opt,name=file_size,json=fileSize,proto3" json:"file_size,omitempty"`
 	ChunkMap      map[string]int64       `protobuf:"bytes,4,rep,name=chunk_map,json=chunkMap,proto3" json:"chunk_map,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	Acquisition   *Acquisition           `protobuf:"bytes,5,opt,name=acquisition,proto3" json:"acquisition,omitempty"` // only set on evidence files
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -89,6 +90,105 @@ func (x *BaseFile) GetChunkMap() map[string]int64 {
 	return nil
 }
 
+func (x *BaseFile) GetAcquisition() *Acquisition {
+	if x != nil {
+		return x.Acquisition
+	}
+	return nil
+}
+
+type Acquisition struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	CaseNumber    string                 `protobuf:"bytes,1,opt,name=case_number,json=caseNumber,proto3" json:"case_number,omitempty"`
+	ExhibitId     string                 `protobuf:"bytes,2,opt,name=exhibit_id,json=exhibitId,proto3" json:"exhibit_id,omitempty"`
+	Examiner      string                 `protobuf:"bytes,3,opt,name=examiner,proto3" json:"examiner,omitempty"`
+	AcquiredAt    string                 `protobuf:"bytes,4,opt,name=acquired_at,json=acquiredAt,proto3" json:"acquired_at,omitempty"` // RFC 3339
+	DeviceSerial  string                 `protobuf:"bytes,5,opt,name=device_serial,json=deviceSerial,proto3" json:"device_serial,omitempty"`
+	Notes         string                 `protobuf:"bytes,6,opt,name=notes,proto3" json:"notes,omitempty"`
+	Tags          map[string]string      `protobuf:"bytes,7,rep,name=tags,proto3" json:"tags,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Acquisition) Reset() {
+	*x = Acquisition{}
+	mi := &file_dues_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Acquisition) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Acquisition) ProtoMessage() {}
+
+func (x *Acquisition) ProtoReflect() protoreflect.Message {
+	mi := &file_dues_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Acquisition.ProtoReflect.Descriptor instead.
+func (*Acquisition) Descriptor() ([]byte, []int) {
+	return file_dues_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Acquisition) GetCaseNumber() string {
+	if x != nil {
+		return x.CaseNumber
+	}
+	return ""
+}
+
+func (x *Acquisition) GetExhibitId() string {
+	if x != nil {
+		return x.ExhibitId
+	}
+	return ""
+}
+
+func (x *Acquisition) GetExaminer() string {
+	if x != nil {
+		return x.Examiner
+	}
+	return ""
+}
+
+func (x *Acquisition) GetAcquiredAt() string {
+	if x != nil {
+		return x.AcquiredAt
+	}
+	return ""
+}
+
+func (x *Acquisition) GetDeviceSerial() string {
+	if x != nil {
+		return x.DeviceSerial
+	}
+	return ""
+}
+
+func (x *Acquisition) GetNotes() string {
+	if x != nil {
+		return x.Notes
+	}
+	return ""
+}
+
+func (x *Acquisition) GetTags() map[string]string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
 type AppendIfExistsReq struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	FileHash      string                 `protobuf:"bytes,1,opt,name=file_hash,json=fileHash,proto3" json:"file_hash,omitempty"`
@@ -99,7 +199,7 @@ type AppendIfExistsReq struct {
 
 func (x *AppendIfExistsReq) Reset() {
 	*x = AppendIfExistsReq{}
-	mi := &file_dues_proto_msgTypes[1]
+	mi := &file_dues_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -111,7 +211,7 @@ func (x *AppendIfExistsReq) String() string {
 func (*AppendIfExistsReq) ProtoMessage() {}
 
 func (x *AppendIfExistsReq) ProtoReflect() protoreflect.Message {
-	mi := &file_dues_proto_msgTypes[1]
+	mi := &file_dues_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -124,7 +224,7 @@ func (x *AppendIfExistsReq) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AppendIfExistsReq.ProtoReflect.Descriptor instead.
 func (*AppendIfExistsReq) Descriptor() ([]byte, []int) {
-	return file_dues_proto_rawDescGZIP(), []int{1}
+	return file_dues_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *AppendIfExistsReq) GetFileHash() string {
@@ -153,7 +253,7 @@ type AppendIfExistsRes struct {
 
 func (x *AppendIfExistsRes) Reset() {
 	*x = AppendIfExistsRes{}
-	mi := &file_dues_proto_msgTypes[2]
+	mi := &file_dues_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -165,7 +265,7 @@ func (x *AppendIfExistsRes) String() string {
 func (*AppendIfExistsRes) ProtoMessage() {}
 
 func (x *AppendIfExistsRes) ProtoReflect() protoreflect.Message {
-	mi := &file_dues_proto_msgTypes[2]
+	mi := &file_dues_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -178,7 +278,7 @@ func (x *AppendIfExistsRes) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AppendIfExistsRes.ProtoReflect.Descriptor instead.
 func (*AppendIfExistsRes) Descriptor() ([]byte, []int) {
-	return file_dues_proto_rawDescGZIP(), []int{2}
+	return file_dues_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *AppendIfExistsRes) GetExists() bool {
@@ -214,13 +314,14 @@ type StreamFileMeta struct {
 	FilePath      string                 `protobuf:"bytes,1,opt,name=file_path,json=filePath,proto3" json:"file_path,omitempty"`
 	FileType      string                 `protobuf:"bytes,2,opt,name=file_type,json=fileType,proto3" json:"file_type,omitempty"` // disk image type (windows_exfat, linux_ext4, something else)
 	FileHash      string                 `protobuf:"bytes,3,opt,name=file_hash,json=fileHash,proto3" json:"file_hash,omitempty"`
+	Acquisition   *Acquisition           `protobuf:"bytes,4,opt,name=acquisition,proto3" json:"acquisition,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *StreamFileMeta) Reset() {
 	*x = StreamFileMeta{}
-	mi := &file_dues_proto_msgTypes[3]
+	mi := &file_dues_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -232,7 +333,7 @@ func (x *StreamFileMeta) String() string {
 func (*StreamFileMeta) ProtoMessage() {}
 
 func (x *StreamFileMeta) ProtoReflect() protoreflect.Message {
-	mi := &file_dues_proto_msgTypes[3]
+	mi := &file_dues_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -245,7 +346,7 @@ func (x *StreamFileMeta) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StreamFileMeta.ProtoReflect.Descriptor instead.
 func (*StreamFileMeta) Descriptor() ([]byte, []int) {
-	return file_dues_proto_rawDescGZIP(), []int{3}
+	return file_dues_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *StreamFileMeta) GetFilePath() string {
@@ -269,6 +370,13 @@ func (x *StreamFileMeta) GetFileHash() string {
 	return ""
 }
 
+func (x *StreamFileMeta) GetAcquisition() *Acquisition {
+	if x != nil {
+		return x.Acquisition
+	}
+	return nil
+}
+
 type StreamFileReq struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// Types that are valid to be assigned to Payload:
@@ -282,7 +390,7 @@ type StreamFileReq struct {
 
 func (x *StreamFileReq) Reset() {
 	*x = StreamFileReq{}
-	mi := &file_dues_proto_msgTypes[4]
+	mi := &file_dues_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -294,7 +402,7 @@ func (x *StreamFileReq) String() string {
 func (*StreamFileReq) ProtoMessage() {}
 
 func (x *StreamFileReq) ProtoReflect() protoreflect.Message {
-	mi := &file_dues_proto_msgTypes[4]
+	mi := &file_dues_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -307,7 +415,7 @@ func (x *StreamFileReq) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StreamFileReq.ProtoReflect.Descriptor instead.
 func (*StreamFileReq) Descriptor() ([]byte, []int) {
-	return file_dues_proto_rawDescGZIP(), []int{4}
+	return file_dues_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *StreamFileReq) GetPayload() isStreamFileReq_Payload {
@@ -362,7 +470,7 @@ type StreamFileRes struct {
 
 func (x *StreamFileRes) Reset() {
 	*x = StreamFileRes{}
-	mi := &file_dues_proto_msgTypes[5]
+	mi := &file_dues_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -374,7 +482,7 @@ func (x *StreamFileRes) String() string {
 func (*StreamFileRes) ProtoMessage() {}
 
 func (x *StreamFileRes) ProtoReflect() protoreflect.Message {
-	mi := &file_dues_proto_msgTypes[5]
+	mi := &file_dues_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -387,7 +495,7 @@ func (x *StreamFileRes) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StreamFileRes.ProtoReflect.Descriptor instead.
 func (*StreamFileRes) Descriptor() ([]byte, []int) {
-	return file_dues_proto_rawDescGZIP(), []int{5}
+	return file_dues_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *StreamFileRes) GetDone() bool {
@@ -419,7 +527,7 @@ type GetEviFilesReq struct {
 
 func (x *GetEviFilesReq) Reset() {
 	*x = GetEviFilesReq{}
-	mi := &file_dues_proto_msgTypes[6]
+	mi := &file_dues_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -431,7 +539,7 @@ func (x *GetEviFilesReq) String() string {
 func (*GetEviFilesReq) ProtoMessage() {}
 
 func (x *GetEviFilesReq) ProtoReflect() protoreflect.Message {
-	mi := &file_dues_proto_msgTypes[6]
+	mi := &file_dues_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -444,7 +552,7 @@ func (x *GetEviFilesReq) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetEviFilesReq.ProtoReflect.Descriptor instead.
 func (*GetEviFilesReq) Descriptor() ([]byte, []int) {
-	return file_dues_proto_rawDescGZIP(), []int{6}
+	return file_dues_proto_rawDescGZIP(), []int{7}
 }
 
 type GetEviFilesRes struct {
@@ -458,7 +566,7 @@ type GetEviFilesRes struct {
 
 func (x *GetEviFilesRes) Reset() {
 	*x = GetEviFilesRes{}
-	mi := &file_dues_proto_msgTypes[7]
+	mi := &file_dues_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -470,7 +578,7 @@ func (x *GetEviFilesRes) String() string {
 func (*GetEviFilesRes) ProtoMessage() {}
 
 func (x *GetEviFilesRes) ProtoReflect() protoreflect.Message {
-	mi := &file_dues_proto_msgTypes[7]
+	mi := &file_dues_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -483,7 +591,7 @@ func (x *GetEviFilesRes) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetEviFilesRes.ProtoReflect.Descriptor instead.
 func (*GetEviFilesRes) Descriptor() ([]byte, []int) {
-	return file_dues_proto_rawDescGZIP(), []int{7}
+	return file_dues_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *GetEviFilesRes) GetDone() bool {
@@ -516,7 +624,7 @@ type GetPartiFilesReq struct {
 
 func (x *GetPartiFilesReq) Reset() {
 	*x = GetPartiFilesReq{}
-	mi := &file_dues_proto_msgTypes[8]
+	mi := &file_dues_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -528,7 +636,7 @@ func (x *GetPartiFilesReq) String() string {
 func (*GetPartiFilesReq) ProtoMessage() {}
 
 func (x *GetPartiFilesReq) ProtoReflect() protoreflect.Message {
-	mi := &file_dues_proto_msgTypes[8]
+	mi := &file_dues_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -541,7 +649,7 @@ func (x *GetPartiFilesReq) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetPartiFilesReq.ProtoReflect.Descriptor instead.
 func (*GetPartiFilesReq) Descriptor() ([]byte, []int) {
-	return file_dues_proto_rawDescGZIP(), []int{8}
+	return file_dues_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *GetPartiFilesReq) GetEviFileId() string {
@@ -562,7 +670,7 @@ type GetPartiFilesRes struct {
 
 func (x *GetPartiFilesRes) Reset() {
 	*x = GetPartiFilesRes{}
-	mi := &file_dues_proto_msgTypes[9]
+	mi := &file_dues_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -574,7 +682,7 @@ func (x *GetPartiFilesRes) String() string {
 func (*GetPartiFilesRes) ProtoMessage() {}
 
 func (x *GetPartiFilesRes) ProtoReflect() protoreflect.Message {
-	mi := &file_dues_proto_msgTypes[9]
+	mi := &file_dues_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -587,7 +695,7 @@ func (x *GetPartiFilesRes) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetPartiFilesRes.ProtoReflect.Descriptor instead.
 func (*GetPartiFilesRes) Descriptor() ([]byte, []int) {
-	return file_dues_proto_rawDescGZIP(), []int{9}
+	return file_dues_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *GetPartiFilesRes) GetDone() bool {
@@ -620,7 +728,7 @@ type GetIdxFilesReq struct {
 
 func (x *GetIdxFilesReq) Reset() {
 	*x = GetIdxFilesReq{}
-	mi := &file_dues_proto_msgTypes[10]
+	mi := &file_dues_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -632,7 +740,7 @@ func (x *GetIdxFilesReq) String() string {
 func (*GetIdxFilesReq) ProtoMessage() {}
 
 func (x *GetIdxFilesReq) ProtoReflect() protoreflect.Message {
-	mi := &file_dues_proto_msgTypes[10]
+	mi := &file_dues_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -645,7 +753,7 @@ func (x *GetIdxFilesReq) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetIdxFilesReq.ProtoReflect.Descriptor instead.
 func (*GetIdxFilesReq) Descriptor() ([]byte, []int) {
-	return file_dues_proto_rawDescGZIP(), []int{10}
+	return file_dues_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *GetIdxFilesReq) GetPartiFileId() string {
@@ -666,7 +774,7 @@ type GetIdxFilesRes struct {
 
 func (x *GetIdxFilesRes) Reset() {
 	*x = GetIdxFilesRes{}
-	mi := &file_dues_proto_msgTypes[11]
+	mi := &file_dues_proto_msgTypes[12]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -678,7 +786,7 @@ func (x *GetIdxFilesRes) String() string {
 func (*GetIdxFilesRes) ProtoMessage() {}
 
 func (x *GetIdxFilesRes) ProtoReflect() protoreflect.Message {
-	mi := &file_dues_proto_msgTypes[11]
+	mi := &file_dues_proto_msgTypes[12]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -691,7 +799,7 @@ func (x *GetIdxFilesRes) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetIdxFilesRes.ProtoReflect.Descriptor instead.
 func (*GetIdxFilesRes) Descriptor() ([]byte, []int) {
-	return file_dues_proto_rawDescGZIP(), []int{11}
+	return file_dues_proto_rawDescGZIP(), []int{12}
 }
 
 func (x *GetIdxFilesRes) GetDone() bool {
@@ -724,7 +832,7 @@ type SearchReq struct {
 
 func (x *SearchReq) Reset() {
 	*x = SearchReq{}
-	mi := &file_dues_proto_msgTypes[12]
+	mi := &file_dues_proto_msgTypes[13]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -736,7 +844,7 @@ func (x *SearchReq) String() string {
 func (*SearchReq) ProtoMessage() {}
 
 func (x *SearchReq) ProtoReflect() protoreflect.Message {
-	mi := &file_dues_proto_msgTypes[12]
+	mi := &file_dues_proto_msgTypes[13]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -749,7 +857,7 @@ func (x *SearchReq) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SearchReq.ProtoReflect.Descriptor instead.
 func (*SearchReq) Descriptor() ([]byte, []int) {
-	return file_dues_proto_rawDescGZIP(), []int{12}
+	return file_dues_proto_rawDescGZIP(), []int{13}
 }
 
 func (x *SearchReq) GetKeyword() string {
@@ -770,7 +878,7 @@ type SearchRes struct {
 
 func (x *SearchRes) Reset() {
 	*x = SearchRes{}
-	mi := &file_dues_proto_msgTypes[13]
+	mi := &file_dues_proto_msgTypes[14]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -782,7 +890,7 @@ func (x *SearchRes) String() string {
 func (*SearchRes) ProtoMessage() {}
 
 func (x *SearchRes) ProtoReflect() protoreflect.Message {
-	mi := &file_dues_proto_msgTypes[13]
+	mi := &file_dues_proto_msgTypes[14]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -795,7 +903,7 @@ func (x *SearchRes) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SearchRes.ProtoReflect.Descriptor instead.
 func (*SearchRes) Descriptor() ([]byte, []int) {
-	return file_dues_proto_rawDescGZIP(), []int{13}
+	return file_dues_proto_rawDescGZIP(), []int{14}
 }
 
 func (x *SearchRes) GetErr() string {
@@ -824,15 +932,30 @@ var File_dues_proto protoreflect.FileDescriptor
 const file_dues_proto_rawDesc = "" +
 	"\n" +
 	"\n" +
-	"dues.proto\x12\x04dues\"\xd5\x01\n" +
+	"dues.proto\x12\x04dues\"\x8a\x02\n" +
 	"\bBaseFile\x12\x1b\n" +
 	"\tfile_path\x18\x01 \x01(\tR\bfilePath\x12\x17\n" +
 	"\afile_id\x18\x02 \x01(\tR\x06fileId\x12\x1b\n" +
 	"\tfile_size\x18\x03 \x01(\x03R\bfileSize\x129\n" +
-	"\tchunk_map\x18\x04 \x03(\v2\x1c.dues.BaseFile.ChunkMapEntryR\bchunkMap\x1a;\n" +
+	"\tchunk_map\x18\x04 \x03(\v2\x1c.dues.BaseFile.ChunkMapEntryR\bchunkMap\x123\n" +
+	"\vacquisition\x18\x05 \x01(\v2\x11.dues.AcquisitionR\vacquisition\x1a;\n" +
 	"\rChunkMapEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\"M\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\"\xaf\x02\n" +
+	"\vAcquisition\x12\x1f\n" +
+	"\vcase_number\x18\x01 \x01(\tR\n" +
+	"caseNumber\x12\x1d\n" +
+	"\n" +
+	"exhibit_id\x18\x02 \x01(\tR\texhibitId\x12\x1a\n" +
+	"\bexaminer\x18\x03 \x01(\tR\bexaminer\x12\x1f\n" +
+	"\vacquired_at\x18\x04 \x01(\tR\n" +
+	"acquiredAt\x12#\n" +
+	"\rdevice_serial\x18\x05 \x01(\tR\fdeviceSerial\x12\x14\n" +
+	"\x05notes\x18\x06 \x01(\tR\x05notes\x12/\n" +
+	"\x04tags\x18\a \x03(\v2\x1b.dues.Acquisition.TagsEntryR\x04tags\x1a7\n" +
+	"\tTagsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"M\n" +
 	"\x11AppendIfExistsReq\x12\x1b\n" +
 	"\tfile_hash\x18\x01 \x01(\tR\bfileHash\x12\x1b\n" +
 	"\tfile_path\x18\x02 \x01(\tR\bfilePath\"\x84\x01\n" +
@@ -840,11 +963,12 @@ const file_dues_proto_rawDesc = "" +
 	"\x06exists\x18\x01 \x01(\bR\x06exists\x12\x1a\n" +
 	"\bappended\x18\x02 \x01(\bR\bappended\x12)\n" +
 	"\bevi_file\x18\x03 \x01(\v2\x0e.dues.BaseFileR\aeviFile\x12\x10\n" +
-	"\x03err\x18\x04 \x01(\tR\x03err\"g\n" +
+	"\x03err\x18\x04 \x01(\tR\x03err\"\x9c\x01\n" +
 	"\x0eStreamFileMeta\x12\x1b\n" +
 	"\tfile_path\x18\x01 \x01(\tR\bfilePath\x12\x1b\n" +
 	"\tfile_type\x18\x02 \x01(\tR\bfileType\x12\x1b\n" +
-	"\tfile_hash\x18\x03 \x01(\tR\bfileHash\"e\n" +
+	"\tfile_hash\x18\x03 \x01(\tR\bfileHash\x123\n" +
+	"\vacquisition\x18\x04 \x01(\v2\x11.dues.AcquisitionR\vacquisition\"e\n" +
 	"\rStreamFileReq\x12\x14\n" +
 	"\x04file\x18\x01 \x01(\fH\x00R\x04file\x123\n" +
 	"\tfile_meta\x18\x02 \x01(\v2\x14.dues.StreamFileMetaH\x00R\bfileMetaB\t\n" +
@@ -901,51 +1025,56 @@ func file_dues_proto_rawDescGZIP() []byte {
 	return file_dues_proto_rawDescData
 }
 
-var file_dues_proto_msgTypes = make([]protoimpl.MessageInfo, 16)
+var file_dues_proto_msgTypes = make([]protoimpl.MessageInfo, 18)
 var file_dues_proto_goTypes = []any{
 	(*BaseFile)(nil),          // 0: dues.BaseFile
-	(*AppendIfExistsReq)(nil), // 1: dues.AppendIfExistsReq
-	(*AppendIfExistsRes)(nil), // 2: dues.AppendIfExistsRes
-	(*StreamFileMeta)(nil),    // 3: dues.StreamFileMeta
-	(*StreamFileReq)(nil),     // 4: dues.StreamFileReq
-	(*StreamFileRes)(nil),     // 5: dues.StreamFileRes
-	(*GetEviFilesReq)(nil),    // 6: dues.GetEviFilesReq
-	(*GetEviFilesRes)(nil),    // 7: dues.GetEviFilesRes
-	(*GetPartiFilesReq)(nil),  // 8: dues.GetPartiFilesReq
-	(*GetPartiFilesRes)(nil),  // 9: dues.GetPartiFilesRes
-	(*GetIdxFilesReq)(nil),    // 10: dues.GetIdxFilesReq
-	(*GetIdxFilesRes)(nil),    // 11: dues.GetIdxFilesRes
-	(*SearchReq)(nil),         // 12: dues.SearchReq
-	(*SearchRes)(nil),         // 13: dues.SearchRes
-	nil,                       // 14: dues.BaseFile.ChunkMapEntry
-	nil,                       // 15: dues.SearchRes.KeywordCountMapEntry
+	(*Acquisition)(nil),       // 1: dues.Acquisition
+	(*AppendIfExistsReq)(nil), // 2: dues.AppendIfExistsReq
+	(*AppendIfExistsRes)(nil), // 3: dues.AppendIfExistsRes
+	(*StreamFileMeta)(nil),    // 4: dues.StreamFileMeta
+	(*StreamFileReq)(nil),     // 5: dues.StreamFileReq
+	(*StreamFileRes)(nil),     // 6: dues.StreamFileRes
+	(*GetEviFilesReq)(nil),    // 7: dues.GetEviFilesReq
+	(*GetEviFilesRes)(nil),    // 8: dues.GetEviFilesRes
+	(*GetPartiFilesReq)(nil),  // 9: dues.GetPartiFilesReq
+	(*GetPartiFilesRes)(nil),  // 10: dues.GetPartiFilesRes
+	(*GetIdxFilesReq)(nil),    // 11: dues.GetIdxFilesReq
+	(*GetIdxFilesRes)(nil),    // 12: dues.GetIdxFilesRes
+	(*SearchReq)(nil),         // 13: dues.SearchReq
+	(*SearchRes)(nil),         // 14: dues.SearchRes
+	nil,                       // 15: dues.BaseFile.ChunkMapEntry
+	nil,                       // 16: dues.Acquisition.TagsEntry
+	nil,                       // 17: dues.SearchRes.KeywordCountMapEntry
 }
 var file_dues_proto_depIdxs = []int32{
-	14, // 0: dues.BaseFile.chunk_map:type_name -> dues.BaseFile.ChunkMapEntry
-	0,  // 1: dues.AppendIfExistsRes.evi_file:type_name -> dues.BaseFile
-	3,  // 2: dues.StreamFileReq.file_meta:type_name -> dues.StreamFileMeta
-	0,  // 3: dues.StreamFileRes.evi_file:type_name -> dues.BaseFile
-	0,  // 4: dues.GetEviFilesRes.evi_file:type_name -> dues.BaseFile
-	0,  // 5: dues.GetPartiFilesRes.partition_file:type_name -> dues.BaseFile
-	0,  // 6: dues.GetIdxFilesRes.indexed_file:type_name -> dues.BaseFile
-	15, // 7: dues.SearchRes.keyword_count_map:type_name -> dues.SearchRes.KeywordCountMapEntry
-	1,  // 8: dues.DuesService.AppendIfExists:input_type -> dues.AppendIfExistsReq
-	4,  // 9: dues.DuesService.StreamFile:input_type -> dues.StreamFileReq
-	6,  // 10: dues.DuesService.GetEviFiles:input_type -> dues.GetEviFilesReq
-	8,  // 11: dues.DuesService.GetPartiFiles:input_type -> dues.GetPartiFilesReq
-	10, // 12: dues.DuesService.GetIdxFiles:input_type -> dues.GetIdxFilesReq
-	12, // 13: dues.DuesService.Search:input_type -> dues.SearchReq
-	2,  // 14: dues.DuesService.AppendIfExists:output_type -> dues.AppendIfExistsRes
-	5,  // 15: dues.DuesService.StreamFile:output_type -> dues.StreamFileRes
-	7,  // 16: dues.DuesService.GetEviFiles:output_type -> dues.GetEviFilesRes
-	9,  // 17: dues.DuesService.GetPartiFiles:output_type -> dues.GetPartiFilesRes
-	11, // 18: dues.DuesService.GetIdxFiles:output_type -> dues.GetIdxFilesRes
-	13, // 19: dues.DuesService.Search:output_type -> dues.SearchRes
-	14, // [14:20] is the sub-list for method output_type
-	8,  // [8:14] is the sub-list for method input_type
-	8,  // [8:8] is the sub-list for extension type_name
-	8,  // [8:8] is the sub-list for extension extendee
-	0,  // [0:8] is the sub-list for field type_name
+	15, // 0: dues.BaseFile.chunk_map:type_name -> dues.BaseFile.ChunkMapEntry
+	1,  // 1: dues.BaseFile.acquisition:type_name -> dues.Acquisition
+	16, // 2: dues.Acquisition.tags:type_name -> dues.Acquisition.TagsEntry
+	0,  // 3: dues.AppendIfExistsRes.evi_file:type_name -> dues.BaseFile
+	1,  // 4: dues.StreamFileMeta.acquisition:type_name -> dues.Acquisition
+	4,  // 5: dues.StreamFileReq.file_meta:type_name -> dues.StreamFileMeta
+	0,  // 6: dues.StreamFileRes.evi_file:type_name -> dues.BaseFile
+	0,  // 7: dues.GetEviFilesRes.evi_file:type_name -> dues.BaseFile
+	0,  // 8: dues.GetPartiFilesRes.partition_file:type_name -> dues.BaseFile
+	0,  // 9: dues.GetIdxFilesRes.indexed_file:type_name -> dues.BaseFile
+	17, // 10: dues.SearchRes.keyword_count_map:type_name -> dues.SearchRes.KeywordCountMapEntry
+	2,  // 11: dues.DuesService.AppendIfExists:input_type -> dues.AppendIfExistsReq
+	5,  // 12: dues.DuesService.StreamFile:input_type -> dues.StreamFileReq
+	7,  // 13: dues.DuesService.GetEviFiles:input_type -> dues.GetEviFilesReq
+	9,  // 14: dues.DuesService.GetPartiFiles:input_type -> dues.GetPartiFilesReq
+	11, // 15: dues.DuesService.GetIdxFiles:input_type -> dues.GetIdxFilesReq
+	13, // 16: dues.DuesService.Search:input_type -> dues.SearchReq
+	3,  // 17: dues.DuesService.AppendIfExists:output_type -> dues.AppendIfExistsRes
+	6,  // 18: dues.DuesService.StreamFile:output_type -> dues.StreamFileRes
+	8,  // 19: dues.DuesService.GetEviFiles:output_type -> dues.GetEviFilesRes
+	10, // 20: dues.DuesService.GetPartiFiles:output_type -> dues.GetPartiFilesRes
+	12, // 21: dues.DuesService.GetIdxFiles:output_type -> dues.GetIdxFilesRes
+	14, // 22: dues.DuesService.Search:output_type -> dues.SearchRes
+	17, // [17:23] is the sub-list for method output_type
+	11, // [11:17] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
 }
 
 func init() { file_dues_proto_init() }
@@ -953,7 +1082,7 @@ func file_dues_proto_init() {
 	if File_dues_proto != nil {
 		return
 	}
-	file_dues_proto_msgTypes[4].OneofWrappers = []any{
+	file_dues_proto_msgTypes[5].OneofWrappers = []any{
 		(*StreamFileReq_File)(nil),
 		(*StreamFileReq_FileMeta)(nil),
 	}
@@ -963,7 +1092,7 @@ func file_dues_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_dues_proto_rawDesc), len(file_dues_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   16,
+			NumMessages:   18,
 			NumExtensions: 0,
 			NumServices:   1,
 		},